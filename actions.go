@@ -90,6 +90,8 @@ func (spec *ActionSpec) InsertDefaults(target map[string]interface{}) (map[strin
 }
 
 // ReadActions builds an Actions spec from a charm's actions.yaml.
+// Problems that can be traced to a location in the YAML are
+// reported with a *ParseError.
 func ReadActionsYaml(r io.Reader) (*Actions, error) {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -100,17 +102,18 @@ func ReadActionsYaml(r io.Reader) (*Actions, error) {
 		ActionSpecs: map[string]ActionSpec{},
 	}
 
+	src := newYAMLSource(data)
 	var unmarshaledActions map[string]map[string]interface{}
 	if err := yaml.Unmarshal(data, &unmarshaledActions); err != nil {
-		return nil, err
+		return nil, src.yamlError(err)
 	}
 
 	for name, actionSpec := range unmarshaledActions {
 		if valid := actionNameRule.MatchString(name); !valid {
-			return nil, fmt.Errorf("bad action name %s", name)
+			return nil, src.pathErrorf(name, "bad action name %s", name)
 		}
 		if reserved, reason := reservedName(name); reserved {
-			return nil, fmt.Errorf(
+			return nil, src.pathErrorf(name,
 				"cannot use action name %s: %s",
 				name, reason,
 			)
@@ -125,12 +128,13 @@ func ReadActionsYaml(r io.Reader) (*Actions, error) {
 		}
 
 		for key, value := range actionSpec {
+			path := name + "." + key
 			switch key {
 			case "description":
 				// These fields must be strings.
 				typed, ok := value.(string)
 				if !ok {
-					return nil, errors.Errorf("value for schema key %q must be a string", key)
+					return nil, src.pathError(path, errors.Errorf("value for schema key %q must be a string", key))
 				}
 				thisActionSchema[key] = typed
 				desc = typed
@@ -138,13 +142,13 @@ func ReadActionsYaml(r io.Reader) (*Actions, error) {
 				// These fields must be strings.
 				typed, ok := value.(string)
 				if !ok {
-					return nil, errors.Errorf("value for schema key %q must be a string", key)
+					return nil, src.pathError(path, errors.Errorf("value for schema key %q must be a string", key))
 				}
 				thisActionSchema[key] = typed
 			case "required":
 				typed, ok := value.([]interface{})
 				if !ok {
-					return nil, errors.Errorf("value for schema key %q must be a YAML list", key)
+					return nil, src.pathError(path, errors.Errorf("value for schema key %q must be a YAML list", key))
 				}
 				thisActionSchema[key] = typed
 			case "params":
@@ -152,20 +156,20 @@ func ReadActionsYaml(r io.Reader) (*Actions, error) {
 				// cause problems with BSON serialization later.
 				cleansedParams, err := cleanse(value)
 				if err != nil {
-					return nil, err
+					return nil, src.pathError(path, err)
 				}
 
 				// JSON-Schema must be a map
 				typed, ok := cleansedParams.(map[string]interface{})
 				if !ok {
-					return nil, errors.New("params failed to parse as a map")
+					return nil, src.pathError(path, errors.New("params failed to parse as a map"))
 				}
 				thisActionSchema["properties"] = typed
 			default:
 				// In case this has nested maps, we must clean them out.
 				typed, err := cleanse(value)
 				if err != nil {
-					return nil, err
+					return nil, src.pathError(path, err)
 				}
 				thisActionSchema[key] = typed
 			}
@@ -176,7 +180,7 @@ func ReadActionsYaml(r io.Reader) (*Actions, error) {
 		schemaLoader := gjs.NewGoLoader(thisActionSchema)
 		_, err := gjs.NewSchema(schemaLoader)
 		if err != nil {
-			return nil, errors.Annotatef(err, "invalid params schema for action schema %s", name)
+			return nil, src.pathError(name, errors.Annotatef(err, "invalid params schema for action schema %s", name))
 		}
 
 		// Now assign the resulting schema to the final entry for the result.
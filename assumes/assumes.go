@@ -0,0 +1,340 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package assumes implements the expressions held in the "assumes"
+// section of a charm's metadata, which describe the features that a
+// charm requires of the controller it is deployed with.
+//
+// The section holds a list of expressions, all of which must be
+// satisfied. Each expression names a feature, optionally with a
+// version constraint, or holds a nested list of expressions under
+// an "any-of" or "all-of" key:
+//
+//	assumes:
+//	    - juju >= 2.9
+//	    - any-of:
+//	        - k8s-api
+//	        - all-of:
+//	            - lxd
+//	            - lxd-profile
+package assumes
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/version"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ExpressionType identifies the kind of an Expression.
+type ExpressionType string
+
+const (
+	// ExpressionFeature is the type of a FeatureExpression.
+	ExpressionFeature ExpressionType = "feature"
+
+	// ExpressionAllOf is the type of a CompositeExpression
+	// that requires all of its sub-expressions to hold.
+	ExpressionAllOf ExpressionType = "all-of"
+
+	// ExpressionAnyOf is the type of a CompositeExpression
+	// that requires at least one of its sub-expressions to hold.
+	ExpressionAnyOf ExpressionType = "any-of"
+)
+
+// Expression is implemented by the nodes of an expression tree.
+type Expression interface {
+	// Type returns the kind of the expression.
+	Type() ExpressionType
+}
+
+// VersionConstraint describes how the version of a
+// feature is compared with the version in an expression.
+type VersionConstraint string
+
+const (
+	// VersionAtLeast requires the feature's version to be
+	// greater than or equal to the expression's version.
+	VersionAtLeast VersionConstraint = ">="
+
+	// VersionBelow requires the feature's version to be
+	// less than the expression's version.
+	VersionBelow VersionConstraint = "<"
+)
+
+// FeatureExpression requires a named feature to be available,
+// optionally at a particular version.
+type FeatureExpression struct {
+	// Name holds the name of the feature.
+	Name string
+
+	// Constraint holds the version constraint on the
+	// feature. It is empty if the feature is not versioned.
+	Constraint VersionConstraint
+
+	// Version holds the version that the feature's
+	// version is compared with.
+	Version version.Number
+}
+
+// Type implements Expression.
+func (FeatureExpression) Type() ExpressionType {
+	return ExpressionFeature
+}
+
+// String returns the expression in the form used in metadata.
+func (e FeatureExpression) String() string {
+	if e.Constraint == "" {
+		return e.Name
+	}
+	return fmt.Sprintf("%s %s %s", e.Name, e.Constraint, e.Version)
+}
+
+// CompositeExpression combines a list of sub-expressions.
+type CompositeExpression struct {
+	// ExprType is either ExpressionAllOf or ExpressionAnyOf.
+	ExprType ExpressionType
+
+	// SubExpressions holds the combined expressions.
+	SubExpressions []Expression
+}
+
+// Type implements Expression.
+func (e CompositeExpression) Type() ExpressionType {
+	return e.ExprType
+}
+
+// ExpressionTree holds the expressions from the "assumes"
+// section of a charm's metadata.
+type ExpressionTree struct {
+	// Expression holds the root of the tree. As the section
+	// holds a list of expressions that must all hold, this is
+	// always a CompositeExpression of type ExpressionAllOf when
+	// the tree has been parsed.
+	Expression Expression
+}
+
+var featureRE = regexp.MustCompile(`^([a-z][a-z0-9-]*)(?:\s*(>=|<)\s*(\S+))?$`)
+
+// Parse parses the given value, which should hold the contents of
+// an "assumes" section as unmarshaled from YAML, JSON or BSON.
+// Problems are reported with a *ParseError.
+func Parse(data interface{}) (*ExpressionTree, error) {
+	exprs, err := parseList(data, "")
+	if err != nil {
+		return nil, err
+	}
+	return &ExpressionTree{
+		Expression: CompositeExpression{
+			ExprType:       ExpressionAllOf,
+			SubExpressions: exprs,
+		},
+	}, nil
+}
+
+func parseList(data interface{}, path string) ([]Expression, error) {
+	rv := reflect.ValueOf(data)
+	if data == nil || rv.Kind() != reflect.Slice {
+		return nil, parseErrorf(path, "expected list of expressions, got %s", describe(data))
+	}
+	exprs := make([]Expression, rv.Len())
+	for i := range exprs {
+		expr, err := parseExpression(rv.Index(i).Interface(), fmt.Sprintf("%s[%d]", path, i))
+		if err != nil {
+			return nil, err
+		}
+		exprs[i] = expr
+	}
+	return exprs, nil
+}
+
+func parseExpression(data interface{}, path string) (Expression, error) {
+	if s, ok := data.(string); ok {
+		return parseFeature(s, path)
+	}
+	rv := reflect.ValueOf(data)
+	if data == nil || rv.Kind() != reflect.Map {
+		return nil, parseErrorf(path, "expected feature or composite expression, got %s", describe(data))
+	}
+	if rv.Len() != 1 {
+		return nil, parseErrorf(path, `composite expression must hold exactly one "any-of" or "all-of" key`)
+	}
+	key := rv.MapKeys()[0]
+	exprType := ExpressionType(fmt.Sprint(key.Interface()))
+	if exprType != ExpressionAllOf && exprType != ExpressionAnyOf {
+		return nil, parseErrorf(path, `unexpected key %q; expected "any-of" or "all-of"`, exprType)
+	}
+	subPath := path + "." + string(exprType)
+	exprs, err := parseList(rv.MapIndex(key).Interface(), subPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(exprs) == 0 {
+		return nil, parseErrorf(subPath, "empty %q expression", exprType)
+	}
+	return CompositeExpression{
+		ExprType:       exprType,
+		SubExpressions: exprs,
+	}, nil
+}
+
+func parseFeature(s, path string) (Expression, error) {
+	m := featureRE.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil, parseErrorf(path, "invalid feature expression %q", s)
+	}
+	expr := FeatureExpression{
+		Name:       m[1],
+		Constraint: VersionConstraint(m[2]),
+	}
+	if expr.Constraint != "" {
+		v, err := parseVersion(m[3])
+		if err != nil {
+			return nil, parseErrorf(path, "invalid version in feature expression %q", s)
+		}
+		expr.Version = v
+	}
+	return expr, nil
+}
+
+// parseVersion parses a version number, allowing the
+// patch number to be omitted as in "2.9".
+func parseVersion(s string) (version.Number, error) {
+	if strings.Count(s, ".") == 1 && !strings.Contains(s, "-") {
+		s += ".0"
+	}
+	return version.Parse(s)
+}
+
+// ParseError describes a problem with part of an "assumes" section.
+type ParseError struct {
+	// Path holds the location of the problem within the
+	// section, for example "[1].any-of[0]". It is empty if
+	// the problem is with the section as a whole.
+	Path string
+
+	// Err holds the problem itself.
+	Err error
+}
+
+// Error implements error.
+func (e *ParseError) Error() string {
+	return "assumes" + e.Path + ": " + e.Err.Error()
+}
+
+func parseErrorf(path string, f string, a ...interface{}) error {
+	return &ParseError{
+		Path: path,
+		Err:  errors.Errorf(f, a...),
+	}
+}
+
+func describe(data interface{}) string {
+	if data == nil {
+		return "nothing"
+	}
+	return fmt.Sprintf("%T(%#v)", data, data)
+}
+
+// marshal returns the tree in the generic form in which it is
+// held in metadata.
+func (tree *ExpressionTree) marshal() interface{} {
+	if composite, ok := tree.Expression.(CompositeExpression); ok && composite.ExprType == ExpressionAllOf {
+		return marshalList(composite.SubExpressions)
+	}
+	// Trees that were not parsed may hold any expression at
+	// their root; wrap them in the implicit list.
+	return []interface{}{marshalExpression(tree.Expression)}
+}
+
+func marshalList(exprs []Expression) []interface{} {
+	out := make([]interface{}, len(exprs))
+	for i, expr := range exprs {
+		out[i] = marshalExpression(expr)
+	}
+	return out
+}
+
+func marshalExpression(expr Expression) interface{} {
+	switch expr := expr.(type) {
+	case FeatureExpression:
+		return expr.String()
+	case CompositeExpression:
+		return map[string]interface{}{
+			string(expr.ExprType): marshalList(expr.SubExpressions),
+		}
+	}
+	panic(fmt.Errorf("unknown expression type %T", expr))
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (tree *ExpressionTree) MarshalYAML() (interface{}, error) {
+	return tree.marshal(), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (tree *ExpressionTree) UnmarshalYAML(f func(interface{}) error) error {
+	var data interface{}
+	if err := f(&data); err != nil {
+		return err
+	}
+	parsed, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	*tree = *parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (tree *ExpressionTree) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tree.marshal())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (tree *ExpressionTree) UnmarshalJSON(b []byte) error {
+	var data interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	parsed, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	*tree = *parsed
+	return nil
+}
+
+// GetBSON implements bson.Getter.
+func (tree *ExpressionTree) GetBSON() (interface{}, error) {
+	if tree == nil {
+		return nil, nil
+	}
+	return tree.marshal(), nil
+}
+
+// bsonNullKind holds the kind of a BSON null value,
+// which the bson package does not name.
+const bsonNullKind = 0x0A
+
+// SetBSON implements bson.Setter.
+func (tree *ExpressionTree) SetBSON(raw bson.Raw) error {
+	if raw.Kind == bsonNullKind {
+		return bson.SetZero
+	}
+	var data interface{}
+	if err := raw.Unmarshal(&data); err != nil {
+		return err
+	}
+	parsed, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	*tree = *parsed
+	return nil
+}
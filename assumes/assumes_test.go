@@ -0,0 +1,219 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package assumes_test
+
+import (
+	"encoding/json"
+
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+	"gopkg.in/yaml.v2"
+
+	"gopkg.in/juju/charm.v6/assumes"
+)
+
+type AssumesSuite struct{}
+
+var _ = gc.Suite(&AssumesSuite{})
+
+func allOf(exprs ...assumes.Expression) assumes.CompositeExpression {
+	return assumes.CompositeExpression{
+		ExprType:       assumes.ExpressionAllOf,
+		SubExpressions: exprs,
+	}
+}
+
+func anyOf(exprs ...assumes.Expression) assumes.CompositeExpression {
+	return assumes.CompositeExpression{
+		ExprType:       assumes.ExpressionAnyOf,
+		SubExpressions: exprs,
+	}
+}
+
+func feature(name string) assumes.FeatureExpression {
+	return assumes.FeatureExpression{Name: name}
+}
+
+func versioned(name string, c assumes.VersionConstraint, v string) assumes.FeatureExpression {
+	return assumes.FeatureExpression{
+		Name:       name,
+		Constraint: c,
+		Version:    version.MustParse(v),
+	}
+}
+
+var parseTests = []struct {
+	about  string
+	yaml   string
+	expect assumes.Expression
+	err    string
+	path   string
+}{{
+	about:  "single feature",
+	yaml:   `[k8s-api]`,
+	expect: allOf(feature("k8s-api")),
+}, {
+	about: "versioned features",
+	yaml: `
+- juju >= 2.9
+- juju<3.0.1
+`,
+	expect: allOf(
+		versioned("juju", assumes.VersionAtLeast, "2.9.0"),
+		versioned("juju", assumes.VersionBelow, "3.0.1"),
+	),
+}, {
+	about: "nested expressions",
+	yaml: `
+- juju >= 2.9
+- any-of:
+    - k8s-api
+    - all-of:
+        - lxd
+        - lxd-profile
+`,
+	expect: allOf(
+		versioned("juju", assumes.VersionAtLeast, "2.9.0"),
+		anyOf(
+			feature("k8s-api"),
+			allOf(feature("lxd"), feature("lxd-profile")),
+		),
+	),
+}, {
+	about: "not a list",
+	yaml:  `juju`,
+	err:   `assumes: expected list of expressions, got string\("juju"\)`,
+}, {
+	about: "bad feature name",
+	yaml:  `[Juju]`,
+	err:   `assumes\[0\]: invalid feature expression "Juju"`,
+	path:  "[0]",
+}, {
+	about: "bad version",
+	yaml:  `[juju >= two]`,
+	err:   `assumes\[0\]: invalid version in feature expression "juju >= two"`,
+	path:  "[0]",
+}, {
+	about: "bad operator",
+	yaml:  `[juju > 2.9]`,
+	err:   `assumes\[0\]: invalid feature expression "juju > 2.9"`,
+	path:  "[0]",
+}, {
+	about: "unknown composite key",
+	yaml: `
+- k8s-api
+- none-of: [lxd]
+`,
+	err:  `assumes\[1\]: unexpected key "none-of"; expected "any-of" or "all-of"`,
+	path: "[1]",
+}, {
+	about: "composite with two keys",
+	yaml: `
+- any-of: [lxd]
+  all-of: [lxd]
+`,
+	err:  `assumes\[0\]: composite expression must hold exactly one "any-of" or "all-of" key`,
+	path: "[0]",
+}, {
+	about: "composite without a list",
+	yaml: `
+- any-of:
+    - all-of: lxd
+`,
+	err:  `assumes\[0\].any-of\[0\].all-of: expected list of expressions, got string\("lxd"\)`,
+	path: "[0].any-of[0].all-of",
+}, {
+	about: "empty any-of",
+	yaml: `
+- any-of: []
+`,
+	err:  `assumes\[0\].any-of: empty "any-of" expression`,
+	path: "[0].any-of",
+}, {
+	about: "empty nested all-of",
+	yaml: `
+- any-of:
+    - juju
+    - all-of: []
+`,
+	err:  `assumes\[0\].any-of\[1\].all-of: empty "all-of" expression`,
+	path: "[0].any-of[1].all-of",
+}}
+
+func (s *AssumesSuite) TestParse(c *gc.C) {
+	for i, test := range parseTests {
+		c.Logf("test %d: %s", i, test.about)
+		var data interface{}
+		err := yaml.Unmarshal([]byte(test.yaml), &data)
+		c.Assert(err, gc.IsNil)
+		tree, err := assumes.Parse(data)
+		if test.err != "" {
+			c.Assert(err, gc.ErrorMatches, test.err)
+			c.Assert(err, gc.FitsTypeOf, &assumes.ParseError{})
+			c.Assert(err.(*assumes.ParseError).Path, gc.Equals, test.path)
+			continue
+		}
+		c.Assert(err, gc.IsNil)
+		c.Assert(tree.Expression, jc.DeepEquals, test.expect)
+	}
+}
+
+var codecs = []struct {
+	Marshal   func(interface{}) ([]byte, error)
+	Unmarshal func([]byte, interface{}) error
+}{{
+	Marshal:   bson.Marshal,
+	Unmarshal: bson.Unmarshal,
+}, {
+	Marshal:   yaml.Marshal,
+	Unmarshal: yaml.Unmarshal,
+}, {
+	Marshal:   json.Marshal,
+	Unmarshal: json.Unmarshal,
+}}
+
+func (s *AssumesSuite) TestCodecRoundTrip(c *gc.C) {
+	type doc struct {
+		Assumes *assumes.ExpressionTree `bson:"assumes" yaml:"assumes" json:"assumes"`
+	}
+	input := doc{
+		Assumes: &assumes.ExpressionTree{
+			Expression: allOf(
+				versioned("juju", assumes.VersionAtLeast, "2.9.0"),
+				anyOf(
+					feature("k8s-api"),
+					allOf(feature("lxd"), versioned("lxd-profile", assumes.VersionBelow, "1.2.3")),
+				),
+			),
+		},
+	}
+	for i, codec := range codecs {
+		c.Logf("codec %d", i)
+		data, err := codec.Marshal(input)
+		c.Assert(err, gc.IsNil)
+		var output doc
+		err = codec.Unmarshal(data, &output)
+		c.Assert(err, gc.IsNil)
+		c.Assert(output, jc.DeepEquals, input, gc.Commentf("data: %q", data))
+	}
+}
+
+func (s *AssumesSuite) TestMarshalYAML(c *gc.C) {
+	tree := &assumes.ExpressionTree{
+		Expression: allOf(
+			versioned("juju", assumes.VersionAtLeast, "2.9.0"),
+			anyOf(feature("k8s-api"), feature("lxd")),
+		),
+	}
+	data, err := yaml.Marshal(tree)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, `
+- juju >= 2.9.0
+- any-of:
+  - k8s-api
+  - lxd
+`[1:])
+}
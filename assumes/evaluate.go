@@ -0,0 +1,174 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package assumes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/juju/version"
+)
+
+// Feature describes a feature that charms may assume
+// is available.
+type Feature struct {
+	// Name holds the name of the feature.
+	Name string
+
+	// Version holds the version of the feature.
+	// It is nil if the feature is not versioned.
+	Version *version.Number
+}
+
+// FeatureSet holds a set of available features, keyed by name.
+type FeatureSet map[string]Feature
+
+// NewFeatureSet returns a FeatureSet holding the given features.
+func NewFeatureSet(features ...Feature) FeatureSet {
+	fs := make(FeatureSet)
+	fs.Add(features...)
+	return fs
+}
+
+// Add adds the given features to the set, replacing any
+// features with the same names.
+func (fs FeatureSet) Add(features ...Feature) {
+	for _, f := range features {
+		fs[f.Name] = f
+	}
+}
+
+// UnsatisfiedError is returned by FeatureSet.Satisfies when
+// the features do not satisfy an expression tree.
+type UnsatisfiedError struct {
+	// Explanation holds a human-readable account of the
+	// parts of the tree that are not satisfied.
+	Explanation string
+}
+
+// Error implements error.
+func (e *UnsatisfiedError) Error() string {
+	return "charm requirements not satisfied:\n" + e.Explanation
+}
+
+// IsUnsatisfiedError reports whether err was returned
+// because an expression tree was not satisfied.
+func IsUnsatisfiedError(err error) bool {
+	_, ok := err.(*UnsatisfiedError)
+	return ok
+}
+
+// Satisfies checks whether the features in the set satisfy the
+// given expression tree. If they do not, it returns an
+// *UnsatisfiedError explaining which requirements are unmet.
+// A nil tree is always satisfied.
+func (fs FeatureSet) Satisfies(tree *ExpressionTree) error {
+	if tree == nil || tree.Expression == nil {
+		return nil
+	}
+	unmet := fs.evaluate(tree.Expression)
+	if unmet == nil {
+		return nil
+	}
+	var lines []string
+	if composite, ok := tree.Expression.(CompositeExpression); ok && composite.ExprType == ExpressionAllOf {
+		// The root list is an implicit "all-of", so
+		// there is no need to say so.
+		for _, child := range unmet.children {
+			lines = child.render(lines, 0)
+		}
+	} else {
+		lines = unmet.render(lines, 0)
+	}
+	return &UnsatisfiedError{
+		Explanation: strings.Join(lines, "\n"),
+	}
+}
+
+// unmetNode describes an expression that is not satisfied,
+// along with any of its sub-expressions that are not.
+type unmetNode struct {
+	message  string
+	children []*unmetNode
+}
+
+func (n *unmetNode) render(lines []string, depth int) []string {
+	lines = append(lines, strings.Repeat("  ", depth)+"- "+n.message)
+	for _, child := range n.children {
+		lines = child.render(lines, depth+1)
+	}
+	return lines
+}
+
+// evaluate returns nil if expr is satisfied by fs, or
+// a description of why it is not.
+func (fs FeatureSet) evaluate(expr Expression) *unmetNode {
+	switch expr := expr.(type) {
+	case FeatureExpression:
+		return fs.evaluateFeature(expr)
+	case CompositeExpression:
+		var unmet []*unmetNode
+		for _, sub := range expr.SubExpressions {
+			if n := fs.evaluate(sub); n != nil {
+				unmet = append(unmet, n)
+			}
+		}
+		switch expr.ExprType {
+		case ExpressionAllOf:
+			if len(unmet) == 0 {
+				return nil
+			}
+			return &unmetNode{
+				message:  "all of the following must be satisfied:",
+				children: unmet,
+			}
+		case ExpressionAnyOf:
+			if len(unmet) < len(expr.SubExpressions) {
+				return nil
+			}
+			return &unmetNode{
+				message:  "at least one of the following must be satisfied:",
+				children: unmet,
+			}
+		}
+	}
+	return &unmetNode{
+		message: fmt.Sprintf("unknown expression type %q", expr.Type()),
+	}
+}
+
+func (fs FeatureSet) evaluateFeature(expr FeatureExpression) *unmetNode {
+	f, ok := fs[expr.Name]
+	if !ok {
+		return &unmetNode{
+			message: fmt.Sprintf("feature %q is not available", expr.Name),
+		}
+	}
+	if expr.Constraint == "" {
+		return nil
+	}
+	if f.Version == nil {
+		return &unmetNode{
+			message: fmt.Sprintf("feature %q must have version %s %s, but it is not versioned", expr.Name, expr.Constraint, expr.Version),
+		}
+	}
+	cmp := f.Version.Compare(expr.Version)
+	switch expr.Constraint {
+	case VersionAtLeast:
+		if cmp >= 0 {
+			return nil
+		}
+	case VersionBelow:
+		if cmp < 0 {
+			return nil
+		}
+	default:
+		return &unmetNode{
+			message: fmt.Sprintf("feature %q has unknown version constraint %q", expr.Name, expr.Constraint),
+		}
+	}
+	return &unmetNode{
+		message: fmt.Sprintf("feature %q must have version %s %s, but version %s is available", expr.Name, expr.Constraint, expr.Version, f.Version),
+	}
+}
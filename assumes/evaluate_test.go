@@ -0,0 +1,129 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package assumes_test
+
+import (
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+	"gopkg.in/yaml.v2"
+
+	"gopkg.in/juju/charm.v6/assumes"
+)
+
+type EvaluateSuite struct{}
+
+var _ = gc.Suite(&EvaluateSuite{})
+
+func versionPtr(s string) *version.Number {
+	v := version.MustParse(s)
+	return &v
+}
+
+var testFeatures = assumes.NewFeatureSet(
+	assumes.Feature{Name: "juju", Version: versionPtr("2.9.1")},
+	assumes.Feature{Name: "k8s-api", Version: versionPtr("1.18.0")},
+	assumes.Feature{Name: "lxd"},
+)
+
+var satisfiesTests = []struct {
+	about   string
+	assumes string
+	expect  string
+}{{
+	about:   "available feature",
+	assumes: `[lxd]`,
+}, {
+	about:   "versioned feature",
+	assumes: `[juju >= 2.9, juju < 3.0, k8s-api >= 1.18]`,
+}, {
+	about: "any-of with one available feature",
+	assumes: `
+- any-of:
+    - lxd-profile
+    - lxd
+`,
+}, {
+	about:   "missing feature",
+	assumes: `[lxd, lxd-profile]`,
+	expect: `
+- feature "lxd-profile" is not available`,
+}, {
+	about:   "version too low",
+	assumes: `[juju >= 3.1]`,
+	expect: `
+- feature "juju" must have version >= 3.1.0, but version 2.9.1 is available`,
+}, {
+	about:   "version too high",
+	assumes: `[k8s-api < 1.18]`,
+	expect: `
+- feature "k8s-api" must have version < 1.18.0, but version 1.18.0 is available`,
+}, {
+	about:   "unversioned feature",
+	assumes: `[lxd >= 1.0]`,
+	expect: `
+- feature "lxd" must have version >= 1.0.0, but it is not versioned`,
+}, {
+	about: "nested expressions",
+	assumes: `
+- juju >= 2.9
+- any-of:
+    - juju >= 3.0
+    - all-of:
+        - lxd
+        - lxd-profile
+        - cloud-init
+- all-of:
+    - lxd
+    - k8s-api < 1.0
+`,
+	expect: `
+- at least one of the following must be satisfied:
+  - feature "juju" must have version >= 3.0.0, but version 2.9.1 is available
+  - all of the following must be satisfied:
+    - feature "lxd-profile" is not available
+    - feature "cloud-init" is not available
+- all of the following must be satisfied:
+  - feature "k8s-api" must have version < 1.0.0, but version 1.18.0 is available`,
+}}
+
+func (s *EvaluateSuite) TestSatisfies(c *gc.C) {
+	for i, test := range satisfiesTests {
+		c.Logf("test %d: %s", i, test.about)
+		var data interface{}
+		err := yaml.Unmarshal([]byte(test.assumes), &data)
+		c.Assert(err, gc.IsNil)
+		tree, err := assumes.Parse(data)
+		c.Assert(err, gc.IsNil)
+		err = testFeatures.Satisfies(tree)
+		if test.expect == "" {
+			c.Assert(err, gc.IsNil)
+			continue
+		}
+		c.Assert(assumes.IsUnsatisfiedError(err), gc.Equals, true)
+		c.Assert(err.(*assumes.UnsatisfiedError).Explanation, gc.Equals, test.expect[1:])
+		c.Assert(err.Error(), gc.Equals, "charm requirements not satisfied:"+test.expect)
+	}
+}
+
+func (s *EvaluateSuite) TestSatisfiesNilTree(c *gc.C) {
+	err := testFeatures.Satisfies(nil)
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *EvaluateSuite) TestSatisfiesUnparsedTree(c *gc.C) {
+	tree := &assumes.ExpressionTree{
+		Expression: assumes.CompositeExpression{
+			ExprType: assumes.ExpressionAnyOf,
+			SubExpressions: []assumes.Expression{
+				assumes.FeatureExpression{Name: "cloud-init"},
+				assumes.FeatureExpression{Name: "lxd-profile"},
+			},
+		},
+	}
+	err := testFeatures.Satisfies(tree)
+	c.Assert(err, gc.ErrorMatches, `charm requirements not satisfied:
+- at least one of the following must be satisfied:
+  - feature "cloud-init" is not available
+  - feature "lxd-profile" is not available`)
+}
@@ -0,0 +1,308 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/juju/schema"
+	"github.com/juju/utils/arch"
+)
+
+// Base identifies the platform that a charm runs on: an operating
+// system, a channel whose track is the version of the operating
+// system, and optionally the architectures supported.
+type Base struct {
+	Name          string   `bson:"name" json:"name"`
+	Channel       Channel  `bson:"channel" json:"channel"`
+	Architectures []string `bson:"architectures,omitempty" json:"architectures,omitempty"`
+}
+
+var validBaseName = regexp.MustCompile("^[a-z]+$")
+
+// ParseBase parses a base written in the form "name@channel",
+// for example "ubuntu@22.04" or "ubuntu@22.04/edge". The risk
+// of the channel defaults to stable.
+func ParseBase(s string) (Base, error) {
+	i := strings.Index(s, "@")
+	if i < 0 {
+		return Base{}, fmt.Errorf("invalid base %q: expected name@channel", s)
+	}
+	ch, err := ParseChannel(s[i+1:])
+	if err != nil {
+		return Base{}, fmt.Errorf("invalid base %q: %v", s, err)
+	}
+	b := Base{
+		Name:    s[:i],
+		Channel: ch.Normalize(),
+	}
+	if err := b.Validate(); err != nil {
+		return Base{}, err
+	}
+	return b, nil
+}
+
+// MustParseBase is like ParseBase but panics on error.
+func MustParseBase(s string) Base {
+	b, err := ParseBase(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// String returns the base in "name@channel" form. A stable risk
+// is left out, as it is the default. The architectures are not
+// included.
+func (b Base) String() string {
+	if b.Name == "" {
+		return ""
+	}
+	return b.Name + "@" + baseChannelString(b.Channel)
+}
+
+// baseChannelString returns the channel of a base as a string,
+// leaving out a stable risk as it is the default.
+func baseChannelString(ch Channel) string {
+	if ch.Risk == Stable && ch.Branch == "" {
+		ch.Risk = ""
+	}
+	return ch.String()
+}
+
+// Validate checks that the base is well formed.
+func (b Base) Validate() error {
+	if !validBaseName.MatchString(b.Name) {
+		return fmt.Errorf("invalid base name %q", b.Name)
+	}
+	if b.Channel.Track == "" {
+		return fmt.Errorf("base %q: channel must include a track", b.Name)
+	}
+	if b.Channel.Risk != "" && !b.Channel.Risk.IsValid() {
+		return fmt.Errorf("base %q: unknown risk %q", b.Name, b.Channel.Risk)
+	}
+	for _, a := range b.Architectures {
+		if !arch.IsSupportedArch(a) {
+			return fmt.Errorf("base %q: unsupported architecture %q", b.Name, a)
+		}
+	}
+	return nil
+}
+
+// BaseForSeries returns the base denoted by a legacy series
+// name, for example ubuntu@16.04 for "xenial".
+func BaseForSeries(series string) (Base, error) {
+	r, ok := ReleaseForSeries(series)
+	if !ok {
+		return Base{}, &unknownSeriesError{series}
+	}
+	return Base{
+		Name:    r.OS,
+		Channel: Channel{Track: r.Version, Risk: Stable},
+	}, nil
+}
+
+// SeriesForBase returns the legacy series name for a base,
+// for example "xenial" for ubuntu@16.04. The risk and
+// architectures of the base are ignored.
+func SeriesForBase(b Base) (string, error) {
+	for _, r := range Releases() {
+		if r.OS == b.Name && r.Version == b.Channel.Track {
+			return r.Series, nil
+		}
+	}
+	return "", fmt.Errorf("base %q has no series name", b)
+}
+
+// BaseForCharm is the base aware counterpart of SeriesForCharm. It
+// takes a requested base and the bases supported by a charm, and
+// returns the base to use. If the requested base is empty, the first
+// supported base is used. Otherwise the requested base must match a
+// supported base by name and track, and any architectures requested
+// must be supported; a supported base with the same risk is
+// preferred. The returned base has the requested channel, and the
+// requested architectures or, failing that, the supported ones.
+func BaseForCharm(requestedBase Base, supportedBases []Base) (Base, error) {
+	// Old charm with no supported bases.
+	if len(supportedBases) == 0 {
+		if requestedBase.Name == "" {
+			return Base{}, missingBaseError
+		}
+		return requestedBase, nil
+	}
+	// Use the charm default.
+	if requestedBase.Name == "" {
+		return supportedBases[0], nil
+	}
+	requested := requestedBase.Channel.Normalize()
+	var best *Base
+	for i := range supportedBases {
+		b := &supportedBases[i]
+		if b.Name != requestedBase.Name || b.Channel.Track != requested.Track {
+			continue
+		}
+		if !supportsArchitectures(*b, requestedBase.Architectures) {
+			continue
+		}
+		if b.Channel.Normalize().Risk == requested.Risk {
+			best = b
+			break
+		}
+		if best == nil {
+			best = b
+		}
+	}
+	if best == nil {
+		return Base{}, &unsupportedBaseError{requestedBase, supportedBases}
+	}
+	result := Base{
+		Name:          requestedBase.Name,
+		Channel:       requested,
+		Architectures: requestedBase.Architectures,
+	}
+	if len(result.Architectures) == 0 {
+		result.Architectures = best.Architectures
+	}
+	return result, nil
+}
+
+// supportsArchitectures reports whether the base supports all the
+// given architectures. A base that does not list any architectures
+// supports them all.
+func supportsArchitectures(b Base, arches []string) bool {
+	if len(b.Architectures) == 0 {
+		return true
+	}
+	for _, a := range arches {
+		found := false
+		for _, ba := range b.Architectures {
+			found = found || a == ba
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// missingBaseError is used to denote that BaseForCharm could not
+// determine a base because a charm did not declare any.
+var missingBaseError = fmt.Errorf("base not specified and charm does not define any")
+
+// IsMissingBaseError returns true if err is a missingBaseError.
+func IsMissingBaseError(err error) bool {
+	return err == missingBaseError
+}
+
+// unsupportedBaseError represents an error indicating that the
+// requested base is not supported by the charm.
+type unsupportedBaseError struct {
+	requestedBase  Base
+	supportedBases []Base
+}
+
+func (e *unsupportedBaseError) Error() string {
+	supported := make([]string, len(e.supportedBases))
+	for i, b := range e.supportedBases {
+		supported[i] = b.String()
+	}
+	return fmt.Sprintf(
+		"base %q not supported by charm, supported bases are: %s",
+		e.requestedBase, strings.Join(supported, ","),
+	)
+}
+
+// NewUnsupportedBaseError returns an error indicating that the
+// requested base is not supported by a charm.
+func NewUnsupportedBaseError(requestedBase Base, supportedBases []Base) error {
+	return &unsupportedBaseError{requestedBase, supportedBases}
+}
+
+// IsUnsupportedBaseError returns true if err is an unsupportedBaseError.
+func IsUnsupportedBaseError(err error) bool {
+	_, ok := err.(*unsupportedBaseError)
+	return ok
+}
+
+// When specified, the "bases" section in the metadata.yaml
+// should have the following format, where the architectures
+// are optional:
+//
+//	bases:
+//	    - name: ubuntu
+//	      channel: "22.04"
+//	      architectures: [amd64, arm64]
+//	    ...
+var baseSchema = collectingFieldMap(
+	schema.Fields{
+		"name":          stringSchema(),
+		"channel":       stringSchema(),
+		"architectures": listSchema(stringSchema()),
+	},
+	schema.Defaults{
+		"architectures": schema.Omit,
+	},
+)
+
+func parseBases(data interface{}) ([]Base, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var errs metaErrors
+	var bases []Base
+	for i, b := range data.([]interface{}) {
+		m := b.(map[string]interface{})
+		path := fmt.Sprintf("bases[%d]", i)
+		ch, err := ParseChannel(m["channel"].(string))
+		if err != nil {
+			errs.addf(path+".channel", "%s.channel: %v", path, err)
+			continue
+		}
+		base := Base{
+			Name:          m["name"].(string),
+			Channel:       ch.Normalize(),
+			Architectures: parseStringList(m["architectures"]),
+		}
+		bases = append(bases, base)
+	}
+	return bases, errs.err()
+}
+
+type marshaledBase struct {
+	Name          string   `yaml:"name"`
+	Channel       string   `yaml:"channel"`
+	Architectures []string `yaml:"architectures,omitempty"`
+}
+
+func marshaledBases(bases []Base) []marshaledBase {
+	var mbs []marshaledBase
+	for _, b := range bases {
+		mbs = append(mbs, marshaledBase{
+			Name:          b.Name,
+			Channel:       baseChannelString(b.Channel),
+			Architectures: b.Architectures,
+		})
+	}
+	return mbs
+}
+
+// ComputedBases returns the bases supported by the charm. These are
+// the bases declared in its metadata or, for a charm that declares
+// series instead, the bases that those series denote.
+func (meta *Meta) ComputedBases() ([]Base, error) {
+	if len(meta.Bases) > 0 {
+		return meta.Bases, nil
+	}
+	var bases []Base
+	for _, series := range meta.Series {
+		b, err := BaseForSeries(series)
+		if err != nil {
+			return nil, err
+		}
+		bases = append(bases, b)
+	}
+	return bases, nil
+}
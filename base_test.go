@@ -0,0 +1,175 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"regexp"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type BaseSuite struct{}
+
+var _ = gc.Suite(&BaseSuite{})
+
+var parseBaseTests = []struct {
+	s      string
+	expect charm.Base
+	str    string
+	err    string
+}{{
+	s:      "ubuntu@22.04",
+	expect: charm.Base{Name: "ubuntu", Channel: charm.Channel{Track: "22.04", Risk: charm.Stable}},
+}, {
+	s:      "ubuntu@22.04/stable",
+	expect: charm.Base{Name: "ubuntu", Channel: charm.Channel{Track: "22.04", Risk: charm.Stable}},
+	str:    "ubuntu@22.04",
+}, {
+	s:      "ubuntu@20.04/edge",
+	expect: charm.Base{Name: "ubuntu", Channel: charm.Channel{Track: "20.04", Risk: charm.Edge}},
+}, {
+	s:      "centos@7/stable/fips",
+	expect: charm.Base{Name: "centos", Channel: charm.Channel{Track: "7", Risk: charm.Stable, Branch: "fips"}},
+}, {
+	s:   "ubuntu",
+	err: `invalid base "ubuntu": expected name@channel`,
+}, {
+	s:   "ubuntu@",
+	err: `invalid base "ubuntu@": channel cannot be empty`,
+}, {
+	s:   "Ubuntu@22.04",
+	err: `invalid base name "Ubuntu"`,
+}, {
+	s:   "ubuntu@edge",
+	err: `base "ubuntu": channel must include a track`,
+}, {
+	s:   "ubuntu@22.04/risky",
+	err: `invalid base "ubuntu@22.04/risky": invalid channel "22.04/risky": unknown risk "risky"`,
+}}
+
+func (s *BaseSuite) TestParseBase(c *gc.C) {
+	for i, test := range parseBaseTests {
+		c.Logf("test %d: %q", i, test.s)
+		b, err := charm.ParseBase(test.s)
+		if test.err != "" {
+			c.Assert(err, gc.ErrorMatches, regexp.QuoteMeta(test.err))
+			c.Assert(func() { charm.MustParseBase(test.s) }, gc.PanicMatches, regexp.QuoteMeta(test.err))
+			continue
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(b, jc.DeepEquals, test.expect)
+		str := test.str
+		if str == "" {
+			str = test.s
+		}
+		c.Assert(b.String(), gc.Equals, str)
+	}
+}
+
+func (s *BaseSuite) TestValidateArchitectures(c *gc.C) {
+	b := charm.MustParseBase("ubuntu@22.04")
+	b.Architectures = []string{"amd64", "s390x"}
+	c.Assert(b.Validate(), jc.ErrorIsNil)
+	b.Architectures = []string{"amd64", "vax"}
+	c.Assert(b.Validate(), gc.ErrorMatches, `base "ubuntu": unsupported architecture "vax"`)
+}
+
+func (s *BaseSuite) TestBaseForSeries(c *gc.C) {
+	b, err := charm.BaseForSeries("xenial")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(b, jc.DeepEquals, charm.MustParseBase("ubuntu@16.04"))
+
+	series, err := charm.SeriesForBase(charm.MustParseBase("ubuntu@22.04/edge"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(series, gc.Equals, "jammy")
+
+	_, err = charm.BaseForSeries("plan9")
+	c.Assert(err, gc.ErrorMatches, `unknown series "plan9"`)
+	_, err = charm.SeriesForBase(charm.MustParseBase("ubuntu@99.04"))
+	c.Assert(err, gc.ErrorMatches, `base "ubuntu@99.04" has no series name`)
+}
+
+func withArches(b charm.Base, arches ...string) charm.Base {
+	b.Architectures = arches
+	return b
+}
+
+var baseForCharmTests = []struct {
+	about     string
+	requested charm.Base
+	supported []charm.Base
+	expect    charm.Base
+	err       string
+}{{
+	about:     "charm default",
+	supported: []charm.Base{charm.MustParseBase("ubuntu@22.04"), charm.MustParseBase("ubuntu@20.04")},
+	expect:    charm.MustParseBase("ubuntu@22.04"),
+}, {
+	about:     "requested base supported",
+	requested: charm.MustParseBase("ubuntu@20.04"),
+	supported: []charm.Base{charm.MustParseBase("ubuntu@22.04"), charm.MustParseBase("ubuntu@20.04")},
+	expect:    charm.MustParseBase("ubuntu@20.04"),
+}, {
+	about:     "same risk preferred",
+	requested: charm.MustParseBase("ubuntu@22.04/edge"),
+	supported: []charm.Base{
+		withArches(charm.MustParseBase("ubuntu@22.04"), "amd64"),
+		withArches(charm.MustParseBase("ubuntu@22.04/edge"), "arm64"),
+	},
+	expect: withArches(charm.MustParseBase("ubuntu@22.04/edge"), "arm64"),
+}, {
+	about:     "architectures filter supported bases",
+	requested: withArches(charm.MustParseBase("ubuntu@22.04"), "arm64"),
+	supported: []charm.Base{
+		withArches(charm.MustParseBase("ubuntu@22.04"), "amd64"),
+		withArches(charm.MustParseBase("ubuntu@22.04/edge"), "amd64", "arm64"),
+	},
+	expect: withArches(charm.MustParseBase("ubuntu@22.04"), "arm64"),
+}, {
+	about:     "unsupported architecture",
+	requested: withArches(charm.MustParseBase("ubuntu@22.04"), "s390x"),
+	supported: []charm.Base{withArches(charm.MustParseBase("ubuntu@22.04"), "amd64")},
+	err:       `base "ubuntu@22.04" not supported by charm, supported bases are: ubuntu@22.04`,
+}, {
+	about:     "unsupported base",
+	requested: charm.MustParseBase("centos@7"),
+	supported: []charm.Base{charm.MustParseBase("ubuntu@22.04"), charm.MustParseBase("ubuntu@20.04/edge")},
+	err:       `base "centos@7" not supported by charm, supported bases are: ubuntu@22.04,ubuntu@20.04/edge`,
+}, {
+	about:     "old charm with requested base",
+	requested: charm.MustParseBase("ubuntu@16.04"),
+	expect:    charm.MustParseBase("ubuntu@16.04"),
+}, {
+	about: "old charm without requested base",
+	err:   "base not specified and charm does not define any",
+}}
+
+func (s *BaseSuite) TestBaseForCharm(c *gc.C) {
+	for i, test := range baseForCharmTests {
+		c.Logf("test %d: %s", i, test.about)
+		b, err := charm.BaseForCharm(test.requested, test.supported)
+		if test.err != "" {
+			c.Assert(err, gc.ErrorMatches, regexp.QuoteMeta(test.err))
+			continue
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(b, jc.DeepEquals, test.expect)
+	}
+}
+
+func (s *BaseSuite) TestBaseForCharmErrors(c *gc.C) {
+	_, err := charm.BaseForCharm(charm.Base{}, nil)
+	c.Assert(charm.IsMissingBaseError(err), jc.IsTrue)
+	c.Assert(charm.IsUnsupportedBaseError(err), jc.IsFalse)
+
+	_, err = charm.BaseForCharm(charm.MustParseBase("centos@7"), []charm.Base{charm.MustParseBase("ubuntu@22.04")})
+	c.Assert(charm.IsUnsupportedBaseError(err), jc.IsTrue)
+	c.Assert(charm.IsMissingBaseError(err), jc.IsFalse)
+
+	err = charm.NewUnsupportedBaseError(charm.MustParseBase("centos@7"), nil)
+	c.Assert(charm.IsUnsupportedBaseError(err), jc.IsTrue)
+}
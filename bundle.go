@@ -16,7 +16,9 @@ type Bundle interface {
 }
 
 // ReadBundle reads a Bundle from path, which can point to either a
-// bundle archive or a bundle directory.
+// bundle archive or a bundle directory. A bundle archive may be a zip
+// file or a gzip-compressed tarball; its format is detected from its
+// content.
 func ReadBundle(path string) (Bundle, error) {
 	info, err := os.Stat(path)
 	if err != nil {
@@ -25,5 +27,12 @@ func ReadBundle(path string) (Bundle, error) {
 	if info.IsDir() {
 		return ReadBundleDir(path)
 	}
+	isTarGz, err := isTarGzFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isTarGz {
+		return ReadBundleTarArchive(path)
+	}
 	return ReadBundleArchive(path)
 }
@@ -72,6 +72,8 @@ func checkWordpressBundle(c *gc.C, b charm.Bundle, path string) {
 	switch b := b.(type) {
 	case *charm.BundleArchive:
 		c.Assert(b.Path, gc.Equals, path)
+	case *charm.BundleTarArchive:
+		c.Assert(b.Path, gc.Equals, path)
 	case *charm.BundleDir:
 		c.Assert(b.Path, gc.Equals, path)
 	}
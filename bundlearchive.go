@@ -7,8 +7,6 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
-
-	ziputil "github.com/juju/utils/zip"
 )
 
 type BundleArchive struct {
@@ -55,25 +53,40 @@ func readBundleArchive(zopen zipOpener) (*BundleArchive, error) {
 		return nil, err
 	}
 	defer zipr.Close()
-	reader, err := zipOpenFile(zipr, "bundle.yaml")
+	if err := checkZipFiles(zipr.File, DefaultExpandLimits); err != nil {
+		return nil, err
+	}
+	a.data, a.readMe, err = readBundleArchiveFiles(func(path string) (io.ReadCloser, error) {
+		return zipOpenFile(zipr, path)
+	})
 	if err != nil {
 		return nil, err
 	}
-	a.data, err = ReadBundleData(reader)
+	return a, nil
+}
+
+// readBundleArchiveFiles reads the bundle data and README from
+// an archive, using openFile to open each one.
+func readBundleArchiveFiles(openFile func(path string) (io.ReadCloser, error)) (*BundleData, string, error) {
+	reader, err := openFile("bundle.yaml")
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := ReadBundleData(reader)
 	reader.Close()
 	if err != nil {
-		return nil, err
+		return nil, "", setErrorFile(err, "bundle.yaml")
 	}
-	reader, err = zipOpenFile(zipr, "README.md")
+	reader, err = openFile("README.md")
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	readMe, err := ioutil.ReadAll(reader)
+	reader.Close()
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	a.readMe = string(readMe)
-	return a, nil
+	return data, string(readMe), nil
 }
 
 // Data implements Bundle.Data.
@@ -88,12 +101,31 @@ func (a *BundleArchive) ReadMe() string {
 
 // ExpandTo expands the bundle archive into dir, creating it if necessary.
 // If any errors occur during the expansion procedure, the process will
-// abort.
+// abort. An archive that is unsafe to expand, or that exceeds
+// DefaultExpandLimits, is refused.
 func (a *BundleArchive) ExpandTo(dir string) error {
+	return a.ExpandToWithOptions(dir, ExpandOptions{})
+}
+
+// ExpandToWithOptions is like ExpandTo, but
+// expands the archive according to the given options.
+func (a *BundleArchive) ExpandToWithOptions(dir string, opts ExpandOptions) error {
+	if opts.Atomic {
+		return expandAtomically(dir, func(dir string) error {
+			return a.expand(dir, opts)
+		}, func(dir string) error {
+			_, err := ReadBundleDir(dir)
+			return err
+		})
+	}
+	return a.expand(dir, opts)
+}
+
+func (a *BundleArchive) expand(dir string, opts ExpandOptions) error {
 	zipr, err := a.zopen.openZip()
 	if err != nil {
 		return err
 	}
 	defer zipr.Close()
-	return ziputil.ExtractAll(zipr.Reader, dir)
+	return expandZip(zipr.Reader, dir, opts.limits())
 }
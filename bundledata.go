@@ -59,7 +59,13 @@ func (bd *BundleData) UnmarshalYAML(f func(interface{}) error) error {
 	if err := f(&bdc); err != nil {
 		return err
 	}
-	return bdc.setBundleData(bd)
+	if err := bdc.setBundleData(bd); err != nil {
+		return &ParseError{
+			Path: "services",
+			Err:  err,
+		}
+	}
+	return nil
 }
 
 // SetBSON implements the bson.Setter interface.
@@ -151,6 +157,10 @@ type ApplicationSpec struct {
 	// the series is specified in the URL.
 	Series string `bson:",omitempty" yaml:",omitempty" json:",omitempty"`
 
+	// Channel holds the channel from which to deploy a charm
+	// store charm, for example "stable" or "2.0/edge".
+	Channel string `bson:",omitempty" yaml:",omitempty" json:",omitempty"`
+
 	// Resources is the set of resource revisions to deploy for the
 	// application. Bundles only support charm store resources and not ones
 	// that were uploaded to the controller.
@@ -242,15 +252,20 @@ type ApplicationSpec struct {
 
 // ReadBundleData reads bundle data from the given reader.
 // The returned data is not verified - call Verify to ensure
-// that it is OK.
+// that it is OK. Problems that can be traced to a location
+// in the YAML are reported with a *ParseError.
 func ReadBundleData(r io.Reader) (*BundleData, error) {
 	bytes, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
+	src := newYAMLSource(bytes)
 	var bd BundleData
 	if err := yaml.Unmarshal(bytes, &bd); err != nil {
-		return nil, fmt.Errorf("cannot unmarshal bundle data: %v", err)
+		if perr, ok := err.(*ParseError); ok {
+			return nil, src.pathErrorf(perr.Path, "cannot unmarshal bundle data: %v", perr.Err)
+		}
+		return nil, src.yamlError(fmt.Errorf("cannot unmarshal bundle data: %v", err))
 	}
 	return &bd, nil
 }
@@ -350,6 +365,7 @@ func (bd *BundleData) Verify(
 // - All applications referred to by relations are specified in the bundle.
 // - All basic constraints are valid.
 // - All storage constraints are valid.
+// - All application channels are valid and match the charm URLs.
 //
 // If charms is not nil, it should hold a map with an entry for each
 // charm url returned by bd.RequiredCharms. The verification will then
@@ -366,6 +382,19 @@ func (bd *BundleData) VerifyWithCharms(
 	return bd.verifyBundle("", verifyConstraints, verifyStorage, charms)
 }
 
+// VerifyWithWarnings is like VerifyWithCharms, but also returns a
+// warning for each series named in the bundle that is not in the
+// release table or that has reached end of life. Such series are not
+// treated as errors, as the bundle may target releases made after
+// this package was built.
+func (bd *BundleData) VerifyWithWarnings(
+	verifyConstraints func(c string) error,
+	verifyStorage func(s string) error,
+	charms map[string]Charm,
+) ([]ReadWarning, error) {
+	return seriesWarnings(bundleSeries(bd)), bd.VerifyWithCharms(verifyConstraints, verifyStorage, charms)
+}
+
 func (bd *BundleData) verifyBundle(
 	bundleDir string,
 	verifyConstraints func(c string) error,
@@ -470,6 +499,22 @@ func (verifier *bundleDataVerifier) verifyApplications() {
 			verifier.addErrorf("application %q declares an invalid series %q", name, svc.Series)
 		}
 
+		// Check the channel.
+		if svc.Channel != "" {
+			channel, cerr := ParseChannel(svc.Channel)
+			switch {
+			case cerr != nil:
+				verifier.addErrorf("invalid channel %q in application %q: %v", svc.Channel, name, cerr)
+			case curl != nil && !isLocalSchema(curl.Schema):
+				if curl.Channel != (Channel{}) && curl.Channel.Normalize() != channel.Normalize() {
+					verifier.addErrorf("the charm URL for application %q has a channel which does not match, please remove the channel from the URL", name)
+				}
+			case err == nil && svc.Charm != "":
+				// The charm is a local directory or a local charm URL.
+				verifier.addErrorf("application %q declares a channel but its charm is not a charm store charm", name)
+			}
+		}
+
 		if err := verifier.verifyConstraints(svc.Constraints); err != nil {
 			verifier.addErrorf("invalid constraints %q in application %q: %v", svc.Constraints, name, err)
 		}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
@@ -414,6 +415,31 @@ relations:
 		`invalid relation syntax "mediawiki/db"`,
 		`invalid series bad series for machine "0"`,
 	},
+}, {
+	about: "channels",
+	data: `
+applications:
+    wordpress:
+        charm: cs:edge/xenial/wordpress
+        channel: 2.0/edge
+    mysql:
+        charm: cs:xenial/mysql
+        channel: candidate
+    mariadb:
+        charm: cs:candidate/xenial/mariadb
+        channel: 2.0/risky
+    postgres:
+        charm: cs:edge/xenial/postgres
+        channel: edge
+    riak:
+        charm: local:xenial/riak
+        channel: stable
+`,
+	errors: []string{
+		`the charm URL for application "wordpress" has a channel which does not match, please remove the channel from the URL`,
+		`invalid channel "2.0/risky" in application "mariadb": invalid channel "2.0/risky": unknown risk "risky"`,
+		`application "riak" declares a channel but its charm is not a charm store charm`,
+	},
 }, {
 	about: "mediawiki should be ok",
 	data:  mediawikiBundle,
@@ -468,6 +494,55 @@ func assertVerifyErrors(c *gc.C, bundleData string, charms map[string]charm.Char
 	c.Assert(errStrings, jc.DeepEquals, expectErrors)
 }
 
+func (s *bundleDataSuite) TestVerifyWithWarningsUnknownSeries(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+series: xenail
+machines:
+    0:
+        series: bundle
+applications:
+    wordpress:
+        charm: wordpress
+        series: foo
+        num_units: 1
+        to: [0]
+`))
+	c.Assert(err, gc.IsNil)
+	warnings, err := bd.VerifyWithWarnings(nil, nil, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(warnings, jc.DeepEquals, []charm.ReadWarning{{
+		Path:    "applications.wordpress.series",
+		Message: `unknown series "foo"`,
+	}, {
+		Path:    "machines.0.series",
+		Message: `unknown series "bundle"`,
+	}, {
+		Path:    "series",
+		Message: `unknown series "xenail"`,
+	}})
+}
+
+func (s *bundleDataSuite) TestVerifyWithWarningsEOLSeries(c *gc.C) {
+	s.PatchValue(charm.TimeNow, func() time.Time {
+		return time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	})
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+series: trusty
+applications:
+    wordpress:
+        charm: wordpress
+        series: jammy
+        num_units: 1
+`))
+	c.Assert(err, gc.IsNil)
+	warnings, err := bd.VerifyWithWarnings(nil, nil, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(warnings, jc.DeepEquals, []charm.ReadWarning{{
+		Path:    "series",
+		Message: `series "trusty" reached end of life on 2019-04-25`,
+	}})
+}
+
 func (*bundleDataSuite) TestVerifyCharmURL(c *gc.C) {
 	bd, err := charm.ReadBundleData(strings.NewReader(mediawikiBundle))
 	c.Assert(err, gc.IsNil)
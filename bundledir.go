@@ -31,7 +31,7 @@ func ReadBundleDir(path string) (dir *BundleDir, err error) {
 	dir.data, err = ReadBundleData(file)
 	file.Close()
 	if err != nil {
-		return nil, err
+		return nil, setErrorFile(err, dir.join("bundle.yaml"))
 	}
 	readMe, err := ioutil.ReadFile(dir.join("README.md"))
 	if err != nil {
@@ -50,7 +50,13 @@ func (dir *BundleDir) ReadMe() string {
 }
 
 func (dir *BundleDir) ArchiveTo(w io.Writer) error {
-	return writeArchive(w, dir.Path, -1, nil)
+	return dir.ArchiveToWithOptions(w, ArchiveOptions{})
+}
+
+// ArchiveToWithOptions is like ArchiveTo, but creates
+// the bundle archive according to the given options.
+func (dir *BundleDir) ArchiveToWithOptions(w io.Writer, opts ArchiveOptions) error {
+	return writeArchive(w, dir.Path, -1, nil, opts)
 }
 
 // join builds a path rooted at the bundle's expanded directory
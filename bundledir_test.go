@@ -4,10 +4,13 @@
 package charm_test
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 
 	"gopkg.in/juju/charm.v6"
@@ -41,6 +44,23 @@ func (s *BundleDirSuite) TestArchiveTo(c *gc.C) {
 	s.assertArchiveTo(c, baseDir, charmDir)
 }
 
+func (s *BundleDirSuite) TestArchiveToDeterministic(c *gc.C) {
+	var archives [][]byte
+	for i := 0; i < 2; i++ {
+		path := cloneDir(c, bundleDirPath(c, "wordpress-simple"))
+		mtime := time.Now().Add(time.Duration(i) * time.Hour)
+		err := os.Chtimes(filepath.Join(path, "bundle.yaml"), mtime, mtime)
+		c.Assert(err, gc.IsNil)
+		dir, err := charm.ReadBundleDir(path)
+		c.Assert(err, gc.IsNil)
+		var buf bytes.Buffer
+		err = dir.ArchiveToWithOptions(&buf, charm.ArchiveOptions{Deterministic: true})
+		c.Assert(err, gc.IsNil)
+		archives = append(archives, buf.Bytes())
+	}
+	c.Assert(archives[0], jc.DeepEquals, archives[1])
+}
+
 func (s *BundleDirSuite) assertArchiveTo(c *gc.C, baseDir, bundleDir string) {
 	dir, err := charm.ReadBundleDir(bundleDir)
 	c.Assert(err, gc.IsNil)
@@ -0,0 +1,121 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Risk describes how stable the releases on a channel are.
+type Risk string
+
+const (
+	Stable    Risk = "stable"
+	Candidate Risk = "candidate"
+	Beta      Risk = "beta"
+	Edge      Risk = "edge"
+)
+
+// Risks holds the known risks, from the most to the least stable.
+var Risks = []Risk{Stable, Candidate, Beta, Edge}
+
+// IsValid reports whether the risk is one of the known risks.
+func (r Risk) IsValid() bool {
+	for _, risk := range Risks {
+		if r == risk {
+			return true
+		}
+	}
+	return false
+}
+
+// validTrack holds the form of a channel track,
+// for example "2.0", "22.04" or "latest".
+var validTrack = regexp.MustCompile(`^[a-z0-9][a-z0-9.+_-]*$`)
+
+// IsValidTrack reports whether track is a valid channel track.
+func IsValidTrack(track string) bool {
+	return validTrack.MatchString(track)
+}
+
+// Channel identifies a stream of releases. It is written in the
+// form "track/risk/branch", where the track and branch may be
+// omitted, for example "stable", "22.04/stable" or
+// "2.0/edge/fix-123".
+type Channel struct {
+	Track  string `bson:"track,omitempty" json:"track,omitempty"`
+	Risk   Risk   `bson:"risk,omitempty" json:"risk,omitempty"`
+	Branch string `bson:"branch,omitempty" json:"branch,omitempty"`
+}
+
+// ParseChannel parses a channel string. A channel of a single
+// part is a risk if it names a known risk and a track otherwise.
+func ParseChannel(s string) (Channel, error) {
+	if s == "" {
+		return Channel{}, fmt.Errorf("channel cannot be empty")
+	}
+	parts := strings.Split(s, "/")
+	for _, part := range parts {
+		if part == "" {
+			return Channel{}, fmt.Errorf("invalid channel %q: empty part", s)
+		}
+	}
+	var ch Channel
+	switch len(parts) {
+	case 1:
+		if Risk(parts[0]).IsValid() {
+			return Channel{Risk: Risk(parts[0])}, nil
+		}
+		ch.Track = parts[0]
+	case 2:
+		if Risk(parts[0]).IsValid() {
+			ch.Risk, ch.Branch = Risk(parts[0]), parts[1]
+		} else {
+			ch.Track, ch.Risk = parts[0], Risk(parts[1])
+		}
+	case 3:
+		ch.Track, ch.Risk, ch.Branch = parts[0], Risk(parts[1]), parts[2]
+	default:
+		return Channel{}, fmt.Errorf("invalid channel %q: too many parts", s)
+	}
+	if len(parts) > 1 && !ch.Risk.IsValid() {
+		return Channel{}, fmt.Errorf("invalid channel %q: unknown risk %q", s, ch.Risk)
+	}
+	if ch.Track != "" && !IsValidTrack(ch.Track) {
+		return Channel{}, fmt.Errorf("invalid channel %q: invalid track %q", s, ch.Track)
+	}
+	return ch, nil
+}
+
+// MustParseChannel is like ParseChannel but panics on error.
+func MustParseChannel(s string) Channel {
+	ch, err := ParseChannel(s)
+	if err != nil {
+		panic(err)
+	}
+	return ch
+}
+
+// String returns the channel in "track/risk/branch" form,
+// omitting any parts that are empty.
+func (ch Channel) String() string {
+	var parts []string
+	for _, part := range []string{ch.Track, string(ch.Risk), ch.Branch} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Normalize returns the channel with its risk
+// defaulting to stable.
+func (ch Channel) Normalize() Channel {
+	if ch.Risk == "" {
+		ch.Risk = Stable
+	}
+	return ch
+}
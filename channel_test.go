@@ -0,0 +1,84 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"regexp"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type ChannelSuite struct{}
+
+var _ = gc.Suite(&ChannelSuite{})
+
+var parseChannelTests = []struct {
+	s      string
+	expect charm.Channel
+	err    string
+}{{
+	s:      "stable",
+	expect: charm.Channel{Risk: charm.Stable},
+}, {
+	s:      "22.04",
+	expect: charm.Channel{Track: "22.04"},
+}, {
+	s:      "2.0/edge",
+	expect: charm.Channel{Track: "2.0", Risk: charm.Edge},
+}, {
+	s:      "beta/fix-123",
+	expect: charm.Channel{Risk: charm.Beta, Branch: "fix-123"},
+}, {
+	s:      "2.0/candidate/fix-123",
+	expect: charm.Channel{Track: "2.0", Risk: charm.Candidate, Branch: "fix-123"},
+}, {
+	s:   "",
+	err: "channel cannot be empty",
+}, {
+	s:   "2.0//fix",
+	err: `invalid channel "2.0//fix": empty part`,
+}, {
+	s:   "2.0/risky",
+	err: `invalid channel "2.0/risky": unknown risk "risky"`,
+}, {
+	s:   "2.0/edge/fix/more",
+	err: `invalid channel "2.0/edge/fix/more": too many parts`,
+}, {
+	s:   "~joe/edge",
+	err: `invalid channel "~joe/edge": invalid track "~joe"`,
+}, {
+	s:   "Latest",
+	err: `invalid channel "Latest": invalid track "Latest"`,
+}}
+
+func (s *ChannelSuite) TestParseChannel(c *gc.C) {
+	for i, test := range parseChannelTests {
+		c.Logf("test %d: %q", i, test.s)
+		ch, err := charm.ParseChannel(test.s)
+		if test.err != "" {
+			c.Assert(err, gc.ErrorMatches, regexp.QuoteMeta(test.err))
+			c.Assert(func() { charm.MustParseChannel(test.s) }, gc.PanicMatches, regexp.QuoteMeta(test.err))
+			continue
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(ch, jc.DeepEquals, test.expect)
+		c.Assert(ch.String(), gc.Equals, test.s)
+	}
+}
+
+func (s *ChannelSuite) TestNormalize(c *gc.C) {
+	c.Assert(charm.MustParseChannel("22.04").Normalize(), gc.Equals, charm.Channel{Track: "22.04", Risk: charm.Stable})
+	c.Assert(charm.MustParseChannel("22.04/edge").Normalize(), gc.Equals, charm.Channel{Track: "22.04", Risk: charm.Edge})
+}
+
+func (s *ChannelSuite) TestRiskIsValid(c *gc.C) {
+	for _, risk := range charm.Risks {
+		c.Assert(risk.IsValid(), jc.IsTrue)
+	}
+	c.Assert(charm.Risk("").IsValid(), jc.IsFalse)
+	c.Assert(charm.Risk("risky").IsValid(), jc.IsFalse)
+}
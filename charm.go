@@ -24,7 +24,8 @@ type Charm interface {
 }
 
 // ReadCharm reads a Charm from path, which can point to either a charm archive or a
-// charm directory.
+// charm directory. A charm archive may be a zip file or a gzip-compressed
+// tarball; its format is detected from its content.
 func ReadCharm(path string) (charm Charm, err error) {
 	info, err := os.Stat(path)
 	if err != nil {
@@ -33,7 +34,7 @@ func ReadCharm(path string) (charm Charm, err error) {
 	if info.IsDir() {
 		charm, err = ReadCharmDir(path)
 	} else {
-		charm, err = ReadCharmArchive(path)
+		charm, err = readCharmFile(path)
 	}
 	if err != nil {
 		return nil, err
@@ -41,6 +42,19 @@ func ReadCharm(path string) (charm Charm, err error) {
 	return charm, nil
 }
 
+// readCharmFile reads a charm archive in either
+// of the formats that ArchiveToWithOptions creates.
+func readCharmFile(path string) (Charm, error) {
+	isTarGz, err := isTarGzFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isTarGz {
+		return ReadCharmTarArchive(path)
+	}
+	return ReadCharmArchive(path)
+}
+
 // SeriesForCharm takes a requested series and a list of series supported by a
 // charm and returns the series which is relevant.
 // If the requested series is empty, then the first supported series is used,
@@ -65,6 +79,31 @@ func SeriesForCharm(requestedSeries string, supportedSeries []string) (string, e
 	return "", &unsupportedSeriesError{requestedSeries, supportedSeries}
 }
 
+// SeriesForCharmPreferringLTS is like SeriesForCharm, except that
+// if the requested series is empty, the newest LTS series supported by
+// the charm that has not reached end of life is used. If there is no
+// such series, the first supported series is used.
+func SeriesForCharmPreferringLTS(requestedSeries string, supportedSeries []string) (string, error) {
+	if requestedSeries != "" || len(supportedSeries) == 0 {
+		return SeriesForCharm(requestedSeries, supportedSeries)
+	}
+	now := timeNow()
+	var best Release
+	for _, series := range supportedSeries {
+		r, ok := ReleaseForSeries(series)
+		if !ok || !r.LTS || r.IsEOL(now) {
+			continue
+		}
+		if best.Series == "" || r.Released.After(best.Released) {
+			best = r
+		}
+	}
+	if best.Series == "" {
+		return supportedSeries[0], nil
+	}
+	return best.Series, nil
+}
+
 // missingSeriesError is used to denote that SeriesForCharm could not determine
 // a series because a legacy charm did not declare any.
 var missingSeriesError = fmt.Errorf("series not specified and charm does not define any")
@@ -124,6 +124,8 @@ func checkDummy(c *gc.C, f charm.Charm, path string) {
 	switch f := f.(type) {
 	case *charm.CharmArchive:
 		c.Assert(f.Path, gc.Equals, path)
+	case *charm.CharmTarArchive:
+		c.Assert(f.Path, gc.Equals, path)
 	case *charm.CharmDir:
 		c.Assert(f.Path, gc.Equals, path)
 	}
@@ -36,7 +36,7 @@ var _ Charm = (*CharmArchive)(nil)
 
 // ReadCharmArchive returns a CharmArchive for the charm in path.
 func ReadCharmArchive(path string) (*CharmArchive, error) {
-	a, err := readCharmArchive(newZipOpenerFromPath(path))
+	a, err := readCharmArchive(newZipOpenerFromPath(path), ReadMeta)
 	if err != nil {
 		return nil, err
 	}
@@ -44,11 +44,28 @@ func ReadCharmArchive(path string) (*CharmArchive, error) {
 	return a, nil
 }
 
+// ReadCharmArchiveWithOptions is like ReadCharmArchive, but reads
+// the charm's metadata as ReadMetaWithOptions does. The warnings
+// returned refer to the archive's metadata.yaml file.
+func ReadCharmArchiveWithOptions(path string, opts ReadOptions) (*CharmArchive, []ReadWarning, error) {
+	var warnings []ReadWarning
+	a, err := readCharmArchive(newZipOpenerFromPath(path), func(r io.Reader) (*Meta, error) {
+		meta, w, err := ReadMetaWithOptions(r, opts)
+		warnings = w
+		return meta, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	a.Path = path
+	return a, setWarningsFile(warnings, "metadata.yaml"), nil
+}
+
 // ReadCharmArchiveBytes returns a CharmArchive read from the given data.
 // Make sure the archive fits in memory before using this.
 func ReadCharmArchiveBytes(data []byte) (archive *CharmArchive, err error) {
 	zopener := newZipOpenerFromReader(bytes.NewReader(data), int64(len(data)))
-	return readCharmArchive(zopener)
+	return readCharmArchive(zopener, ReadMeta)
 }
 
 // ReadCharmArchiveFromReader returns a CharmArchive that uses
@@ -58,10 +75,10 @@ func ReadCharmArchiveBytes(data []byte) (archive *CharmArchive, err error) {
 // Note that the caller is responsible for closing r - methods on
 // the returned CharmArchive may fail after that.
 func ReadCharmArchiveFromReader(r io.ReaderAt, size int64) (archive *CharmArchive, err error) {
-	return readCharmArchive(newZipOpenerFromReader(r, size))
+	return readCharmArchive(newZipOpenerFromReader(r, size), ReadMeta)
 }
 
-func readCharmArchive(zopen zipOpener) (archive *CharmArchive, err error) {
+func readCharmArchive(zopen zipOpener, readMeta func(io.Reader) (*Meta, error)) (archive *CharmArchive, err error) {
 	b := &CharmArchive{
 		zopen: zopen,
 	}
@@ -70,66 +87,90 @@ func readCharmArchive(zopen zipOpener) (archive *CharmArchive, err error) {
 		return nil, err
 	}
 	defer zipr.Close()
-	reader, err := zipOpenFile(zipr, "metadata.yaml")
+	if err := checkZipFiles(zipr.File, DefaultExpandLimits); err != nil {
+		return nil, err
+	}
+	err = readCharmArchiveFiles(func(path string) (io.ReadCloser, error) {
+		return zipOpenFile(zipr, path)
+	}, readMeta, &b.meta, &b.config, &b.metrics, &b.actions, &b.revision)
 	if err != nil {
 		return nil, err
 	}
-	b.meta, err = ReadMeta(reader)
+	return b, nil
+}
+
+// readCharmArchiveFiles reads the files that describe a charm from
+// an archive, using openFile to open each one. The openFile function
+// must return a *noCharmArchiveFile error for a missing file.
+func readCharmArchiveFiles(
+	openFile func(path string) (io.ReadCloser, error),
+	readMeta func(io.Reader) (*Meta, error),
+	meta **Meta,
+	config **Config,
+	metrics **Metrics,
+	actions **Actions,
+	revision *int,
+) (err error) {
+	reader, err := openFile("metadata.yaml")
+	if err != nil {
+		return err
+	}
+	*meta, err = readMeta(reader)
 	reader.Close()
 	if err != nil {
-		return nil, err
+		return setErrorFile(err, "metadata.yaml")
 	}
 
-	reader, err = zipOpenFile(zipr, "config.yaml")
+	reader, err = openFile("config.yaml")
 	if _, ok := err.(*noCharmArchiveFile); ok {
-		b.config = NewConfig()
+		*config = NewConfig()
 	} else if err != nil {
-		return nil, err
+		return err
 	} else {
-		b.config, err = ReadConfig(reader)
+		*config, err = ReadConfig(reader)
 		reader.Close()
 		if err != nil {
-			return nil, err
+			return setErrorFile(err, "config.yaml")
 		}
 	}
 
-	reader, err = zipOpenFile(zipr, "metrics.yaml")
+	reader, err = openFile("metrics.yaml")
 	if err == nil {
-		b.metrics, err = ReadMetrics(reader)
+		*metrics, err = ReadMetrics(reader)
 		reader.Close()
 		if err != nil {
-			return nil, err
+			return setErrorFile(err, "metrics.yaml")
 		}
 	} else if _, ok := err.(*noCharmArchiveFile); !ok {
-		return nil, err
+		return err
 	}
 
-	reader, err = zipOpenFile(zipr, "actions.yaml")
+	reader, err = openFile("actions.yaml")
 	if _, ok := err.(*noCharmArchiveFile); ok {
-		b.actions = NewActions()
+		*actions = NewActions()
 	} else if err != nil {
-		return nil, err
+		return err
 	} else {
-		b.actions, err = ReadActionsYaml(reader)
+		*actions, err = ReadActionsYaml(reader)
 		reader.Close()
 		if err != nil {
-			return nil, err
+			return setErrorFile(err, "actions.yaml")
 		}
 	}
 
-	reader, err = zipOpenFile(zipr, "revision")
+	reader, err = openFile("revision")
 	if err != nil {
 		if _, ok := err.(*noCharmArchiveFile); !ok {
-			return nil, err
+			return err
 		}
 	} else {
-		_, err = fmt.Fscan(reader, &b.revision)
+		_, err = fmt.Fscan(reader, revision)
+		reader.Close()
 		if err != nil {
-			return nil, errors.New("invalid revision file")
+			return errors.New("invalid revision file")
 		}
 	}
-
-	return b, nil
+	return nil
 }
 
 func zipOpenFile(zipr *zipReadCloser, path string) (rc io.ReadCloser, err error) {
@@ -258,28 +299,57 @@ func (a *CharmArchive) Manifest() (set.Strings, error) {
 	if err != nil {
 		return set.NewStrings(), err
 	}
+	return archiveManifest(paths), nil
+}
+
+// archiveManifest returns the manifest of a charm
+// archive that holds the given cleaned paths.
+func archiveManifest(paths []string) set.Strings {
 	manifest := set.NewStrings(paths...)
 	// We always write out a revision file, even if there isn't one in the
 	// archive; and we always strip ".", because that's sometimes not present.
 	manifest.Add("revision")
 	manifest.Remove(".")
-	return manifest, nil
+	return manifest
 }
 
 // ExpandTo expands the charm archive into dir, creating it if necessary.
 // If any errors occur during the expansion procedure, the process will
-// abort.
+// abort, which may leave dir partly populated; see ExpandOptions.Atomic.
+// An archive that is unsafe to expand, or that exceeds
+// DefaultExpandLimits, is refused.
 func (a *CharmArchive) ExpandTo(dir string) error {
+	return a.ExpandToWithOptions(dir, ExpandOptions{})
+}
+
+// ExpandToWithOptions is like ExpandTo, but
+// expands the archive according to the given options.
+func (a *CharmArchive) ExpandToWithOptions(dir string, opts ExpandOptions) error {
+	if opts.Atomic {
+		return expandAtomically(dir, func(dir string) error {
+			return a.expand(dir, opts)
+		}, a.verifyExpanded)
+	}
+	return a.expand(dir, opts)
+}
+
+func (a *CharmArchive) expand(dir string, opts ExpandOptions) error {
 	zipr, err := a.zopen.openZip()
 	if err != nil {
 		return err
 	}
 	defer zipr.Close()
-	if err := ziputil.ExtractAll(zipr.Reader, dir); err != nil {
+	if err := expandZip(zipr.Reader, dir, opts.limits()); err != nil {
 		return err
 	}
+	return finishExpandedCharm(dir, a.meta.Hooks(), a.revision)
+}
+
+// finishExpandedCharm makes the hooks of the charm expanded
+// into dir executable, and writes its revision file.
+func finishExpandedCharm(dir string, hooks map[string]bool, revision int) error {
 	hooksDir := filepath.Join(dir, "hooks")
-	fixHook := fixHookFunc(hooksDir, a.meta.Hooks())
+	fixHook := fixHookFunc(hooksDir, hooks)
 	if err := filepath.Walk(hooksDir, fixHook); err != nil {
 		if !os.IsNotExist(err) {
 			return err
@@ -289,7 +359,7 @@ func (a *CharmArchive) ExpandTo(dir string) error {
 	if err != nil {
 		return err
 	}
-	if _, err := revFile.Write([]byte(strconv.Itoa(a.revision))); err != nil {
+	if _, err := revFile.Write([]byte(strconv.Itoa(revision))); err != nil {
 		return err
 	}
 	if err := revFile.Sync(); err != nil {
@@ -301,6 +371,35 @@ func (a *CharmArchive) ExpandTo(dir string) error {
 	return nil
 }
 
+// verifyExpanded checks that the charm expanded into dir can be
+// read, has the archive's revision, and has executable hooks.
+func (a *CharmArchive) verifyExpanded(dir string) error {
+	return verifyExpandedCharm(dir, a.meta.Hooks(), a.revision)
+}
+
+func verifyExpandedCharm(dir string, hooks map[string]bool, revision int) error {
+	ch, err := ReadCharmDir(dir)
+	if err != nil {
+		return err
+	}
+	if ch.Revision() != revision {
+		return fmt.Errorf("expanded charm has revision %d, expected %d", ch.Revision(), revision)
+	}
+	for hook := range hooks {
+		info, err := os.Stat(filepath.Join(dir, "hooks", hook))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && info.Mode()&0100 == 0 {
+			return fmt.Errorf("hook %q is not executable", hook)
+		}
+	}
+	return nil
+}
+
 // fixHookFunc returns a WalkFunc that makes sure hooks are owner-executable.
 func fixHookFunc(hooksDir string, hookNames map[string]bool) filepath.WalkFunc {
 	return func(path string, info os.FileInfo, err error) error {
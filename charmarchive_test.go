@@ -342,7 +342,8 @@ func (s *CharmArchiveSuite) TestExpandToWithBadLink(c *gc.C) {
 
 	path := filepath.Join(c.MkDir(), "charm")
 	err = archive.ExpandTo(path)
-	c.Assert(err, gc.ErrorMatches, `cannot extract "hooks/badlink": symlink "../../target" leads out of scope`)
+	c.Assert(err, gc.ErrorMatches, `symlink "hooks/badlink" links out of charm: "../../target"`)
+	c.Assert(err, gc.FitsTypeOf, &charm.UnsafeSymlinkError{})
 
 	// Symlink targeting an absolute path.
 	os.Remove(badLink)
@@ -354,7 +355,8 @@ func (s *CharmArchiveSuite) TestExpandToWithBadLink(c *gc.C) {
 
 	path = filepath.Join(c.MkDir(), "charm")
 	err = archive.ExpandTo(path)
-	c.Assert(err, gc.ErrorMatches, `cannot extract "hooks/badlink": symlink "/target" is absolute`)
+	c.Assert(err, gc.ErrorMatches, `symlink "hooks/badlink" is absolute: "/target"`)
+	c.Assert(err, gc.FitsTypeOf, &charm.UnsafeSymlinkError{})
 }
 
 func extCharmArchiveDirPath(c *gc.C, dirpath string) string {
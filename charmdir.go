@@ -4,15 +4,21 @@
 package charm
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
-	"syscall"
+	"time"
+
+	"github.com/juju/utils/set"
 )
 
 // The CharmDir type encapsulates access to data and operations
@@ -39,15 +45,35 @@ func IsCharmDir(path string) bool {
 
 // ReadCharmDir returns a CharmDir representing an expanded charm directory.
 func ReadCharmDir(path string) (dir *CharmDir, err error) {
+	return readCharmDir(path, ReadMeta)
+}
+
+// ReadCharmDirWithOptions is like ReadCharmDir, but reads the
+// charm's metadata as ReadMetaWithOptions does. The warnings
+// returned refer to the charm's metadata.yaml file.
+func ReadCharmDirWithOptions(path string, opts ReadOptions) (*CharmDir, []ReadWarning, error) {
+	var warnings []ReadWarning
+	dir, err := readCharmDir(path, func(r io.Reader) (*Meta, error) {
+		meta, w, err := ReadMetaWithOptions(r, opts)
+		warnings = w
+		return meta, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return dir, setWarningsFile(warnings, dir.join("metadata.yaml")), nil
+}
+
+func readCharmDir(path string, readMeta func(io.Reader) (*Meta, error)) (dir *CharmDir, err error) {
 	dir = &CharmDir{Path: path}
 	file, err := os.Open(dir.join("metadata.yaml"))
 	if err != nil {
 		return nil, err
 	}
-	dir.meta, err = ReadMeta(file)
+	dir.meta, err = readMeta(file)
 	file.Close()
 	if err != nil {
-		return nil, err
+		return nil, setErrorFile(err, dir.join("metadata.yaml"))
 	}
 
 	file, err = os.Open(dir.join("config.yaml"))
@@ -59,7 +85,7 @@ func ReadCharmDir(path string) (dir *CharmDir, err error) {
 		dir.config, err = ReadConfig(file)
 		file.Close()
 		if err != nil {
-			return nil, err
+			return nil, setErrorFile(err, dir.join("config.yaml"))
 		}
 	}
 
@@ -68,7 +94,7 @@ func ReadCharmDir(path string) (dir *CharmDir, err error) {
 		dir.metrics, err = ReadMetrics(file)
 		file.Close()
 		if err != nil {
-			return nil, err
+			return nil, setErrorFile(err, dir.join("metrics.yaml"))
 		}
 	} else if !os.IsNotExist(err) {
 		return nil, err
@@ -83,7 +109,7 @@ func ReadCharmDir(path string) (dir *CharmDir, err error) {
 		dir.actions, err = ReadActionsYaml(file)
 		file.Close()
 		if err != nil {
-			return nil, err
+			return nil, setErrorFile(err, dir.join("actions.yaml"))
 		}
 	}
 
@@ -171,133 +197,414 @@ func resolveSymlinkedRoot(rootPath string) (string, error) {
 
 // ArchiveTo creates a charm file from the charm expanded in dir.
 // By convention a charm archive should have a ".charm" suffix.
+//
+// Hidden files and the build directory at the root of the charm are
+// left out, along with any files matched by the patterns in a
+// .jujuignore file in the root, which has the syntax of a .gitignore
+// file.
 func (dir *CharmDir) ArchiveTo(w io.Writer) error {
-	return writeArchive(w, dir.Path, dir.revision, dir.Meta().Hooks())
+	return dir.ArchiveToWithOptions(w, ArchiveOptions{})
 }
 
-func writeArchive(w io.Writer, path string, revision int, hooks map[string]bool) error {
-	zipw := zip.NewWriter(w)
-	defer zipw.Close()
+// Manifest returns the set of paths that ArchiveTo would include in the
+// charm file, in the same form as CharmArchive.Manifest, so that the
+// effect of a .jujuignore file can be previewed.
+func (dir *CharmDir) Manifest() (set.Strings, error) {
+	entries, err := dir.archiveEntries()
+	if err != nil {
+		return set.NewStrings(), err
+	}
+	manifest := set.NewStrings()
+	for _, e := range entries {
+		manifest.Add(strings.TrimSuffix(e.name, "/"))
+	}
+	// As with CharmArchive.Manifest, the revision file is always
+	// present and the root directory never is.
+	manifest.Add("revision")
+	manifest.Remove(".")
+	return manifest, nil
+}
+
+// archiveEntries returns the entries that ArchiveTo would write
+// to the charm file, in name order, without their content.
+func (dir *CharmDir) archiveEntries() ([]*archiveEntry, error) {
+	rootPath, err := resolveSymlinkedRoot(dir.Path)
+	if err != nil {
+		return nil, err
+	}
+	ignore, err := readIgnoreRules(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	// A deterministic packer holds its entries rather than
+	// writing them, so it needs no entry writer.
+	p := archivePacker{
+		root:          rootPath,
+		hooks:         dir.Meta().Hooks(),
+		ignore:        ignore,
+		deterministic: true,
+	}
+	p.AddRevision(dir.revision)
+	if err := filepath.Walk(rootPath, p.WalkFunc()); err != nil {
+		return nil, err
+	}
+	sort.Sort(archiveEntriesByName(p.pending))
+	return p.pending, nil
+}
+
+// ArchiveToWithOptions is like ArchiveTo, but creates
+// the charm file according to the given options.
+func (dir *CharmDir) ArchiveToWithOptions(w io.Writer, opts ArchiveOptions) error {
+	return writeArchive(w, dir.Path, dir.revision, dir.Meta().Hooks(), opts)
+}
+
+// ArchiveOptions controls how charm and bundle
+// directories are archived by ArchiveToWithOptions.
+type ArchiveOptions struct {
+	// Deterministic causes the archive to depend only on the
+	// names, contents and executable bits of the files archived,
+	// so that archiving the same content twice yields identical
+	// bytes, and so an identical fingerprint. The entries are
+	// sorted by name and given a fixed modification time,
+	// normalised permissions and fixed compression settings.
+	Deterministic bool
+
+	// Format holds the format of the archive. If it
+	// is empty, a zip archive is created.
+	Format ArchiveFormat
+}
+
+// ArchiveFormat identifies the file format of a charm or bundle archive.
+type ArchiveFormat string
+
+const (
+	// ZipFormat is the format of a zip archive,
+	// in which charms and bundles are usually held.
+	ZipFormat ArchiveFormat = "zip"
+
+	// TarGzFormat is the format of a gzip-compressed tarball.
+	TarGzFormat ArchiveFormat = "tar.gz"
+)
+
+// archiveModTime holds the modification time given to all
+// entries in a deterministic archive. It is the earliest time
+// that a zip file can represent.
+var archiveModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func writeArchive(w io.Writer, path string, revision int, hooks map[string]bool, opts ArchiveOptions) error {
+	var ew entryWriter
+	switch opts.Format {
+	case "", ZipFormat:
+		ew = newZipEntryWriter(w, opts.Deterministic)
+	case TarGzFormat:
+		var err error
+		ew, err = newTarEntryWriter(w)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown archive format %q", opts.Format)
+	}
 
 	// The root directory may be symlinked elsewhere so
-	// resolve that before creating the zip.
+	// resolve that before creating the archive.
 	rootPath, err := resolveSymlinkedRoot(path)
 	if err != nil {
 		return err
 	}
-	zp := zipPacker{zipw, rootPath, hooks}
+	ignore, err := readIgnoreRules(rootPath)
+	if err != nil {
+		return err
+	}
+	p := archivePacker{
+		w:             ew,
+		root:          rootPath,
+		hooks:         hooks,
+		ignore:        ignore,
+		deterministic: opts.Deterministic,
+	}
 	if revision != -1 {
-		zp.AddRevision(revision)
+		p.AddRevision(revision)
+	}
+	if err := filepath.Walk(rootPath, p.WalkFunc()); err != nil {
+		return err
+	}
+	if err := p.Flush(); err != nil {
+		return err
 	}
-	return filepath.Walk(rootPath, zp.WalkFunc())
+	return ew.Close()
+}
+
+// archiveEntry describes a file, directory or symlink in a charm
+// or bundle archive, whatever the archive's format. Its content
+// comes from the file at path or, if path is empty, from data,
+// which holds the target of a symlink.
+type archiveEntry struct {
+	// name holds the slash-separated name of the entry;
+	// the names of directories end in a slash.
+	name    string
+	mode    os.FileMode
+	path    string
+	data    []byte
+	size    int64
+	modTime time.Time
 }
 
-type zipPacker struct {
-	*zip.Writer
-	root  string
-	hooks map[string]bool
+// writeContent writes the content of the entry to w.
+func (e *archiveEntry) writeContent(w io.Writer) error {
+	if e.path == "" {
+		_, err := w.Write(e.data)
+		return err
+	}
+	file, err := os.Open(e.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+type archiveEntriesByName []*archiveEntry
+
+func (es archiveEntriesByName) Len() int           { return len(es) }
+func (es archiveEntriesByName) Swap(i, j int)      { es[i], es[j] = es[j], es[i] }
+func (es archiveEntriesByName) Less(i, j int) bool { return es[i].name < es[j].name }
+
+// entryWriter writes entries to an archive of a particular format.
+type entryWriter interface {
+	// writeEntry writes the given entry to the archive.
+	writeEntry(e *archiveEntry) error
+
+	// Close finishes writing the archive.
+	Close() error
+}
+
+// zipEntryWriter writes entries to a zip archive.
+type zipEntryWriter struct {
+	zw *zip.Writer
+}
+
+// newZipEntryWriter returns an entryWriter that writes a zip
+// archive to w. When deterministic is true, the compression
+// level is pinned rather than left to the zip package.
+func newZipEntryWriter(w io.Writer, deterministic bool) *zipEntryWriter {
+	zw := zip.NewWriter(w)
+	if deterministic {
+		zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, flate.DefaultCompression)
+		})
+	}
+	return &zipEntryWriter{zw}
 }
 
-func (zp *zipPacker) WalkFunc() filepath.WalkFunc {
+func (w *zipEntryWriter) writeEntry(e *archiveEntry) error {
+	h := &zip.FileHeader{
+		Name:   e.name,
+		Method: zip.Deflate,
+	}
+	if e.path == "" {
+		// Directories, symlinks and the revision
+		// are too small to be worth compressing.
+		h.Method = zip.Store
+	}
+	h.SetMode(e.mode)
+	if !e.modTime.IsZero() {
+		h.SetModTime(e.modTime)
+	}
+	fw, err := w.zw.CreateHeader(h)
+	if err != nil || e.mode.IsDir() {
+		return err
+	}
+	return e.writeContent(fw)
+}
+
+func (w *zipEntryWriter) Close() error {
+	return w.zw.Close()
+}
+
+// tarEntryWriter writes entries to a gzip-compressed tarball.
+type tarEntryWriter struct {
+	tw  *tar.Writer
+	gzw *gzip.Writer
+}
+
+// newTarEntryWriter returns an entryWriter that writes a
+// gzip-compressed tarball to w.
+func newTarEntryWriter(w io.Writer) (*tarEntryWriter, error) {
+	// The gzip header is left without a name or
+	// time, so it does not vary between archives.
+	gzw, err := gzip.NewWriterLevel(w, gzip.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	return &tarEntryWriter{
+		tw:  tar.NewWriter(gzw),
+		gzw: gzw,
+	}, nil
+}
+
+func (w *tarEntryWriter) writeEntry(e *archiveEntry) error {
+	h := &tar.Header{
+		Name:    e.name,
+		Mode:    int64(e.mode & os.ModePerm),
+		ModTime: e.modTime,
+	}
+	if h.ModTime.IsZero() {
+		h.ModTime = archiveModTime
+	}
+	switch {
+	case e.mode.IsDir():
+		h.Typeflag = tar.TypeDir
+	case e.mode&os.ModeSymlink != 0:
+		h.Typeflag = tar.TypeSymlink
+		h.Linkname = string(e.data)
+	case e.path == "":
+		h.Typeflag = tar.TypeReg
+		h.Size = int64(len(e.data))
+	default:
+		h.Typeflag = tar.TypeReg
+		h.Size = e.size
+	}
+	if err := w.tw.WriteHeader(h); err != nil {
+		return err
+	}
+	if h.Typeflag != tar.TypeReg {
+		return nil
+	}
+	return e.writeContent(w.tw)
+}
+
+func (w *tarEntryWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return w.gzw.Close()
+}
+
+// archivePacker walks a charm or bundle directory, and writes
+// the entries to be archived to an entryWriter.
+type archivePacker struct {
+	w      entryWriter
+	root   string
+	hooks  map[string]bool
+	ignore ignoreRules
+
+	// deterministic holds whether entries are normalised
+	// and held in pending until Flush writes them in order.
+	deterministic bool
+	pending       []*archiveEntry
+}
+
+func (p *archivePacker) WalkFunc() filepath.WalkFunc {
 	return func(path string, fi os.FileInfo, err error) error {
-		return zp.visit(path, fi, err)
+		return p.visit(path, fi, err)
 	}
 }
 
-func (zp *zipPacker) AddRevision(revision int) error {
-	h := &zip.FileHeader{Name: "revision"}
-	h.SetMode(syscall.S_IFREG | 0644)
-	w, err := zp.CreateHeader(h)
-	if err == nil {
-		_, err = w.Write([]byte(strconv.Itoa(revision)))
+func (p *archivePacker) AddRevision(revision int) error {
+	return p.add(&archiveEntry{
+		name: "revision",
+		mode: 0644,
+		data: []byte(strconv.Itoa(revision)),
+	})
+}
+
+// add writes the given entry or, when
+// deterministic, holds it for Flush.
+func (p *archivePacker) add(e *archiveEntry) error {
+	if !p.deterministic {
+		return p.w.writeEntry(e)
 	}
-	return err
+	e.modTime = archiveModTime
+	p.pending = append(p.pending, e)
+	return nil
 }
 
-func (zp *zipPacker) visit(path string, fi os.FileInfo, err error) error {
+// Flush writes any pending entries in name order.
+func (p *archivePacker) Flush() error {
+	sort.Sort(archiveEntriesByName(p.pending))
+	for _, e := range p.pending {
+		if err := p.w.writeEntry(e); err != nil {
+			return err
+		}
+	}
+	p.pending = nil
+	return nil
+}
+
+func (p *archivePacker) visit(path string, fi os.FileInfo, err error) error {
 	if err != nil {
 		return err
 	}
-	relpath, err := filepath.Rel(zp.root, path)
+	relpath, err := filepath.Rel(p.root, path)
 	if err != nil {
 		return err
 	}
-	method := zip.Deflate
-	hidden := len(relpath) > 1 && relpath[0] == '.'
+	ignored := relpath != "." && p.ignore.ignored(filepath.ToSlash(relpath), fi.IsDir())
 	if fi.IsDir() {
-		if relpath == "build" {
-			return filepath.SkipDir
-		}
-		if hidden {
+		if ignored {
 			return filepath.SkipDir
 		}
 		relpath += "/"
-		method = zip.Store
+	}
+	if ignored || relpath == "revision" {
+		return nil
 	}
 
 	mode := fi.Mode()
 	if err := checkFileType(relpath, mode); err != nil {
 		return err
 	}
-	if mode&os.ModeSymlink != 0 {
-		method = zip.Store
-	}
-	if hidden || relpath == "revision" {
-		return nil
-	}
-	h := &zip.FileHeader{
-		Name:   relpath,
-		Method: method,
-	}
 
 	perm := os.FileMode(0644)
 	if mode&os.ModeSymlink != 0 {
 		perm = 0777
-	} else if mode&0100 != 0 {
+	} else if mode&0100 != 0 || (p.deterministic && fi.IsDir()) {
 		perm = 0755
 	}
 	if filepath.Dir(relpath) == "hooks" {
 		hookName := filepath.Base(relpath)
-		if _, ok := zp.hooks[hookName]; ok && !fi.IsDir() && mode&0100 == 0 {
+		if _, ok := p.hooks[hookName]; ok && !fi.IsDir() && mode&0100 == 0 {
 			logger.Warningf("making %q executable in charm", path)
 			perm = perm | 0100
 		}
 	}
-	h.SetMode(mode&^0777 | perm)
+	if p.deterministic {
+		// Leave out any setuid, setgid and sticky bits.
+		mode &= os.ModeType
+	}
 
-	w, err := zp.CreateHeader(h)
-	if err != nil || fi.IsDir() {
-		return err
+	e := &archiveEntry{
+		name:    filepath.ToSlash(relpath),
+		mode:    mode&^0777 | perm,
+		size:    fi.Size(),
+		modTime: fi.ModTime(),
 	}
-	var data []byte
-	if mode&os.ModeSymlink != 0 {
+	switch {
+	case fi.IsDir():
+	case mode&os.ModeSymlink != 0:
 		target, err := os.Readlink(path)
 		if err != nil {
 			return err
 		}
-		if err := checkSymlinkTarget(zp.root, relpath, target); err != nil {
+		if err := checkSymlinkTarget(p.root, relpath, target); err != nil {
 			return err
 		}
-		data = []byte(target)
-		_, err = w.Write(data)
-	} else {
-		file, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		_, err = io.Copy(w, file)
+		e.data = []byte(target)
+	default:
+		e.path = path
 	}
-	return err
+	return p.add(e)
 }
 
 func checkSymlinkTarget(basedir, symlink, target string) error {
 	if filepath.IsAbs(target) {
-		return fmt.Errorf("symlink %q is absolute: %q", symlink, target)
+		return &UnsafeSymlinkError{Path: symlink, Target: target, Absolute: true}
 	}
 	p := filepath.Join(filepath.Dir(symlink), target)
 	if p == ".." || strings.HasPrefix(p, "../") {
-		return fmt.Errorf("symlink %q links out of charm: %q", symlink, target)
+		return &UnsafeSymlinkError{Path: symlink, Target: target}
 	}
 	return nil
 }
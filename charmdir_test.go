@@ -6,6 +6,7 @@ package charm_test
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/sha512"
 	"fmt"
 	jc "github.com/juju/testing/checkers"
 	"io/ioutil"
@@ -13,8 +14,10 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/juju/testing"
+	"github.com/juju/utils/set"
 	gc "gopkg.in/check.v1"
 
 	"gopkg.in/juju/charm.v6"
@@ -196,6 +199,7 @@ func (s *CharmDirSuite) TestArchiveToWithNonExecutableHooks(c *gc.C) {
 			hooks = append(hooks, relName+"-relation-"+kind)
 		}
 	}
+	hooks = append(hooks, "data-storage-attached", "data-storage-detaching")
 
 	dir := readCharmDir(c, "all-hooks")
 	path := filepath.Join(c.MkDir(), "archive.charm")
@@ -272,6 +276,164 @@ func (s *CharmDirSuite) TestArchiveToWithBadType(c *gc.C) {
 	c.Assert(err, gc.ErrorMatches, `file is a named pipe: "hooks/badfile"`)
 }
 
+func (s *CharmDirSuite) TestArchiveToDeterministic(c *gc.C) {
+	archive := func(path string) []byte {
+		dir, err := charm.ReadCharmDir(path)
+		c.Assert(err, gc.IsNil)
+		var buf bytes.Buffer
+		err = dir.ArchiveToWithOptions(&buf, charm.ArchiveOptions{Deterministic: true})
+		c.Assert(err, gc.IsNil)
+		return buf.Bytes()
+	}
+	path1 := cloneDir(c, charmDirPath(c, "dummy"))
+	path2 := cloneDir(c, charmDirPath(c, "dummy"))
+
+	// Change the modification times and permissions in
+	// the second copy without changing its content.
+	mtime := time.Date(2016, 6, 1, 12, 0, 0, 0, time.UTC)
+	err := filepath.Walk(path2, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, mtime, mtime)
+	})
+	c.Assert(err, gc.IsNil)
+	err = os.Chmod(filepath.Join(path2, "metadata.yaml"), 0600)
+	c.Assert(err, gc.IsNil)
+	err = os.Chmod(filepath.Join(path2, "empty"), 0700)
+	c.Assert(err, gc.IsNil)
+
+	data1, data2 := archive(path1), archive(path2)
+	c.Assert(data1, jc.DeepEquals, data2)
+	c.Assert(sha512.Sum384(data1), gc.Equals, sha512.Sum384(data2))
+
+	zipr, err := zip.NewReader(bytes.NewReader(data1), int64(len(data1)))
+	c.Assert(err, gc.IsNil)
+	var names []string
+	for _, f := range zipr.File {
+		names = append(names, f.Name)
+		c.Check(f.ModTime().Equal(time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)), jc.IsTrue, gc.Commentf("%s: %v", f.Name, f.ModTime()))
+		switch f.Name {
+		case "metadata.yaml":
+			c.Check(f.Mode(), gc.Equals, os.FileMode(0644))
+		case "empty/":
+			c.Check(f.Mode(), gc.Equals, os.ModeDir|0755)
+		case "hooks/install":
+			c.Check(f.Mode(), gc.Equals, os.FileMode(0755))
+		}
+	}
+	c.Assert(names, jc.DeepEquals, []string{
+		"./", "actions.yaml", "config.yaml", "empty/", "empty/.gitkeep",
+		"hooks/", "hooks/install", "metadata.yaml", "revision", "src/", "src/hello.c",
+	})
+
+	// A change in content changes the archive.
+	err = ioutil.WriteFile(filepath.Join(path2, "README"), []byte("changed"), 0644)
+	c.Assert(err, gc.IsNil)
+	c.Assert(archive(path2), gc.Not(jc.DeepEquals), data1)
+}
+
+func (s *CharmDirSuite) TestManifest(c *gc.C) {
+	dir := readCharmDir(c, "dummy")
+	manifest, err := dir.Manifest()
+	c.Assert(err, gc.IsNil)
+	c.Assert(manifest, jc.DeepEquals, set.NewStrings(dummyManifest...))
+}
+
+var jujuIgnoreTests = []struct {
+	about    string
+	ignore   string
+	excluded []string
+	included []string
+	err      string
+}{{
+	about:    "no patterns",
+	excluded: []string{".ignored", ".dir", "build"},
+	included: []string{"venv/lib/x.pyc", "tests/unit.py", "src/build/out", "data/a.bin"},
+}, {
+	about: "directory and suffix patterns",
+	ignore: `
+# Leave out the virtualenv and compiled python.
+venv/
+*.pyc
+`,
+	excluded: []string{"venv", "venv/lib/x.pyc", "src/y.pyc"},
+	included: []string{"tests/unit.py", "src/venv"},
+}, {
+	about:    "anchored patterns",
+	ignore:   "/tests\nsrc/build\n",
+	excluded: []string{"tests", "tests/unit.py", "src/build", "src/build/out"},
+	included: []string{"src/tests/other.py"},
+}, {
+	about:    "double stars",
+	ignore:   "data/**/*.bin\n**/other.py\n",
+	excluded: []string{"data/a.bin", "data/sub/b.bin", "src/tests/other.py"},
+	included: []string{"data/sub/keep.txt"},
+}, {
+	about:    "negation",
+	ignore:   "data/*\n!data/sub/\n!src/y.pyc\n*.pyc\n!venv/lib/x.pyc\n",
+	excluded: []string{"data/a.bin", "src/y.pyc"},
+	included: []string{"data/sub/b.bin", "data/sub/keep.txt", "venv/lib/x.pyc"},
+}, {
+	about:    "default patterns can be negated",
+	ignore:   "!/build/\n",
+	excluded: []string{".ignored"},
+	included: []string{"build/ignored"},
+}, {
+	about:    "escapes and character classes",
+	ignore:   "\\#hash\nsrc/[xy].pyc\nunit.p[!y]\n",
+	excluded: []string{"#hash", "src/y.pyc"},
+	included: []string{"tests/unit.py"},
+}, {
+	about:  "invalid pattern",
+	ignore: "# comment\ndata/[a\n",
+	err:    `cannot read .jujuignore: line 2: invalid pattern "data/\[a": unterminated character class`,
+}}
+
+func (s *CharmDirSuite) TestJujuIgnore(c *gc.C) {
+	for i, test := range jujuIgnoreTests {
+		c.Logf("test %d: %s", i, test.about)
+		path := cloneDir(c, charmDirPath(c, "dummy"))
+		for _, name := range []string{
+			"venv/lib/x.pyc", "tests/unit.py", "src/tests/other.py", "src/y.pyc",
+			"src/build/out", "src/venv", "data/a.bin", "data/sub/b.bin", "data/sub/keep.txt", "#hash",
+		} {
+			err := os.MkdirAll(filepath.Dir(filepath.Join(path, name)), 0755)
+			c.Assert(err, gc.IsNil)
+			err = ioutil.WriteFile(filepath.Join(path, name), []byte(name), 0644)
+			c.Assert(err, gc.IsNil)
+		}
+		if test.ignore != "" {
+			err := ioutil.WriteFile(filepath.Join(path, ".jujuignore"), []byte(test.ignore), 0644)
+			c.Assert(err, gc.IsNil)
+		}
+		dir, err := charm.ReadCharmDir(path)
+		c.Assert(err, gc.IsNil)
+		manifest, err := dir.Manifest()
+		if test.err != "" {
+			c.Assert(err, gc.ErrorMatches, test.err)
+			err = dir.ArchiveTo(&bytes.Buffer{})
+			c.Assert(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		c.Assert(err, gc.IsNil)
+		for _, name := range test.excluded {
+			c.Check(manifest.Contains(name), jc.IsFalse, gc.Commentf("%s", name))
+		}
+		for _, name := range test.included {
+			c.Check(manifest.Contains(name), jc.IsTrue, gc.Commentf("%s", name))
+		}
+		c.Check(manifest.Contains(".jujuignore"), jc.IsFalse)
+
+		// The archive holds exactly the files in the manifest.
+		archive, err := charm.ReadCharmArchive(archivePath(c, dir))
+		c.Assert(err, gc.IsNil)
+		archiveManifest, err := archive.Manifest()
+		c.Assert(err, gc.IsNil)
+		c.Assert(archiveManifest.SortedValues(), jc.DeepEquals, manifest.SortedValues())
+	}
+}
+
 func (s *CharmDirSuite) TestDirRevisionFile(c *gc.C) {
 	charmDir := cloneDir(c, charmDirPath(c, "dummy"))
 	revPath := filepath.Join(charmDir, "revision")
@@ -0,0 +1,367 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package charmstoretesting provides an in-process charm store,
+// backed by a local repository, for testing code that uses the
+// charmstore package without network access.
+package charmstoretesting
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+
+	"gopkg.in/juju/charm.v6"
+	"gopkg.in/juju/charm.v6/charmstore"
+	"gopkg.in/juju/charm.v6/resource"
+)
+
+// Server is a charm store that serves the charms and bundles held in
+// a local repository, speaking the parts of the charm store API that
+// the charmstore package uses. A charm store URL refers to the charm
+// or bundle in the repository with the same series, name and revision;
+// its user is preserved but otherwise ignored, and so is the channel
+// query parameter, which must be a valid channel if present. A URL
+// without a series refers to the charm or bundle with its name in
+// whichever series holds one, and cannot be resolved if there are
+// several.
+//
+// Resources have no content, and a revision of -1, until it is set
+// with SetResource.
+type Server struct {
+	*httptest.Server
+
+	repo *charm.LocalRepository
+
+	mu        sync.Mutex
+	resources map[string]map[string]*resourceContent
+}
+
+type resourceContent struct {
+	revision int
+	data     []byte
+}
+
+// NewServer starts and returns a new charm store that serves the
+// charms and bundles in the given repository. The caller is
+// responsible for calling Close when done.
+func NewServer(repo *charm.LocalRepository) *Server {
+	s := &Server{
+		repo:      repo,
+		resources: make(map[string]map[string]*resourceContent),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns a client for the server.
+func (s *Server) Client() charmstore.Client {
+	return charmstore.NewClient(charmstore.Params{
+		URL: s.URL,
+	})
+}
+
+// SetResource sets the content of the named resource of the charm
+// that url refers to, incrementing the resource's revision.
+func (s *Server) SetResource(url *charm.URL, name string, data []byte) error {
+	_, entry, err := s.resolve(url)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	ch, _, err := s.repo.Charm(entry)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if _, ok := ch.Meta().Resources[name]; !ok {
+		return errors.NotFoundf("resource %q of %q", name, url)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byName := s.resources[entry.String()]
+	if byName == nil {
+		byName = make(map[string]*resourceContent)
+		s.resources[entry.String()] = byName
+	}
+	revision := 0
+	if content := byName[name]; content != nil {
+		revision = content.revision + 1
+	}
+	byName[name] = &resourceContent{
+		revision: revision,
+		data:     append([]byte(nil), data...),
+	}
+	return nil
+}
+
+// resolve returns the fully specified charm store URL that url refers
+// to, along with the local URL of the charm or bundle in the repository.
+func (s *Server) resolve(url *charm.URL) (*charm.URL, *charm.URL, error) {
+	local := &charm.URL{
+		Schema:   "local",
+		Series:   url.Series,
+		Name:     url.Name,
+		Revision: url.Revision,
+	}
+	if local.Series == "" {
+		series, err := s.seriesForName(url)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+		local.Series = series
+	}
+	entry, err := s.repo.Resolve(local)
+	if errors.IsNotFound(err) {
+		return nil, nil, errors.NotFoundf("charm or bundle %q", url)
+	}
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	resolved := *url
+	resolved.Series = entry.Series
+	resolved.Revision = entry.Revision
+	return &resolved, entry, nil
+}
+
+func (s *Server) seriesForName(url *charm.URL) (string, error) {
+	var series []string
+	for _, u := range s.repo.URLs() {
+		if u.Name == url.Name && (len(series) == 0 || series[len(series)-1] != u.Series) {
+			series = append(series, u.Series)
+		}
+	}
+	switch len(series) {
+	case 0:
+		return "", errors.NotFoundf("charm or bundle %q", url)
+	case 1:
+		return series[0], nil
+	}
+	return "", errors.Errorf("cannot resolve %q: available in series %s", url, strings.Join(series, ", "))
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", req.Method))
+		return
+	}
+	id, endpoint, err := splitPath(req.URL.Path)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	url, err := charm.ParseURL("cs:" + id)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if url.Channel != (charm.Channel{}) {
+		writeError(w, http.StatusBadRequest, errors.Errorf("channel in path %q: use the channel query parameter", req.URL.Path))
+		return
+	}
+	if channel := req.URL.Query().Get("channel"); channel != "" {
+		if _, err := charm.ParseChannel(channel); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	resolved, entry, err := s.resolve(url)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	switch {
+	case endpoint == "meta/id":
+		writeJSON(w, charmstore.IdResponse{Id: resolved.String()})
+	case endpoint == "meta/revision-info":
+		s.serveRevisions(w, resolved, entry)
+	case endpoint == "meta/charm-metadata":
+		s.serveCharm(w, entry, func(ch charm.Charm) interface{} { return ch.Meta() })
+	case endpoint == "meta/charm-config":
+		s.serveCharm(w, entry, func(ch charm.Charm) interface{} { return ch.Config() })
+	case endpoint == "meta/bundle-metadata":
+		s.serveBundleData(w, entry)
+	case endpoint == "meta/resources":
+		s.serveResources(w, entry)
+	case endpoint == "archive":
+		s.serveArchive(w, entry)
+	case strings.HasPrefix(endpoint, "resource/"):
+		s.serveResource(w, entry, strings.TrimPrefix(endpoint, "resource/"))
+	default:
+		writeError(w, http.StatusNotFound, errors.Errorf("endpoint %q not found", endpoint))
+	}
+}
+
+// splitPath splits a request path of the form
+// "/v5/id/endpoint" into its id and endpoint.
+func splitPath(path string) (id, endpoint string, err error) {
+	prefix := "/" + charmstore.APIVersion + "/"
+	if !strings.HasPrefix(path, prefix) {
+		return "", "", errors.Errorf("path %q not found", path)
+	}
+	path = path[len(prefix):]
+	if strings.HasSuffix(path, "/archive") {
+		return strings.TrimSuffix(path, "/archive"), "archive", nil
+	}
+	for _, sep := range []string{"/meta/", "/resource/"} {
+		if i := strings.LastIndex(path, sep); i > 0 {
+			return path[:i], path[i+1:], nil
+		}
+	}
+	return "", "", errors.Errorf("path %q not found", path)
+}
+
+func (s *Server) serveRevisions(w http.ResponseWriter, resolved, entry *charm.URL) {
+	var revisions []int
+	for _, u := range s.repo.URLs() {
+		if u.Series == entry.Series && u.Name == entry.Name {
+			revisions = append(revisions, u.Revision)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(revisions)))
+	var resp charmstore.RevisionInfoResponse
+	for _, rev := range revisions {
+		resp.Revisions = append(resp.Revisions, resolved.WithRevision(rev).String())
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) serveCharm(w http.ResponseWriter, entry *charm.URL, get func(charm.Charm) interface{}) {
+	ch, _, err := s.repo.Charm(entry)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, get(ch))
+}
+
+func (s *Server) serveBundleData(w http.ResponseWriter, entry *charm.URL) {
+	b, _, err := s.repo.Bundle(entry)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, b.Data())
+}
+
+func (s *Server) serveArchive(w http.ResponseWriter, entry *charm.URL) {
+	var archive interface{}
+	if ch, _, err := s.repo.Charm(entry); err == nil {
+		archive = ch
+	} else {
+		b, _, err := s.repo.Bundle(entry)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		archive = b
+	}
+	var buf bytes.Buffer
+	switch archive := archive.(type) {
+	case interface {
+		ArchiveTo(io.Writer) error
+	}:
+		if err := archive.ArchiveTo(&buf); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	case *charm.CharmArchive:
+		f, err := os.Open(archive.Path)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		defer f.Close()
+		if _, err := io.Copy(&buf, f); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	default:
+		writeError(w, http.StatusInternalServerError, errors.Errorf("cannot archive %q", entry))
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) serveResources(w http.ResponseWriter, entry *charm.URL) {
+	ch, _, err := s.repo.Charm(entry)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	resources := ch.Meta().Resources
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	infos := make([]charmstore.ResourceInfo, 0, len(names))
+	for _, name := range names {
+		meta := resources[name]
+		info := charmstore.ResourceInfo{
+			Name:        meta.Name,
+			Type:        meta.Type.String(),
+			Path:        meta.Path,
+			Description: meta.Description,
+			Revision:    -1,
+		}
+		if content := s.resourceContent(entry, name); content != nil {
+			fp, err := resource.GenerateFingerprint(bytes.NewReader(content.data))
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			info.Revision = content.revision
+			info.Fingerprint = fp.Bytes()
+			info.Size = int64(len(content.data))
+		}
+		infos = append(infos, info)
+	}
+	writeJSON(w, infos)
+}
+
+func (s *Server) serveResource(w http.ResponseWriter, entry *charm.URL, name string) {
+	content := s.resourceContent(entry, name)
+	if content == nil {
+		writeError(w, http.StatusNotFound, errors.NotFoundf("resource %q of %q", name, entry))
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(content.data)
+}
+
+func (s *Server) resourceContent(entry *charm.URL, name string) *resourceContent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resources[entry.String()][name]
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	serr := charmstore.Error{
+		Message: err.Error(),
+	}
+	if errors.IsNotFound(err) {
+		serr.Code = charmstore.CodeNotFound
+	}
+	data, _ := json.Marshal(serr)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
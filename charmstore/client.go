@@ -0,0 +1,279 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package charmstore provides a client for
+// the charms and bundles held in a charm store.
+package charmstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/juju/errors"
+
+	"gopkg.in/juju/charm.v6"
+	"gopkg.in/juju/charm.v6/resource"
+)
+
+// APIVersion is the version of the charm
+// store API used by the client.
+const APIVersion = "v5"
+
+// Client gets charms, bundles and resources from a charm store.
+// Every method takes the URL of a charm or bundle, which may have
+// been parsed from either the "cs:" or the https form; a URL that
+// is not fully specified is resolved by the store. The channel of
+// the URL, if any, is sent to the store in the channel query
+// parameter.
+type Client interface {
+	// Resolve returns the fully specified URL of
+	// the charm or bundle that url refers to.
+	Resolve(url *charm.URL) (*charm.URL, error)
+
+	// Revisions returns the URLs of all the revisions of the
+	// charm or bundle that url refers to, from the most recent
+	// to the oldest.
+	Revisions(url *charm.URL) ([]*charm.URL, error)
+
+	// Meta returns the metadata of a charm.
+	Meta(url *charm.URL) (*charm.Meta, error)
+
+	// Config returns the configuration of a charm.
+	Config(url *charm.URL) (*charm.Config, error)
+
+	// BundleData returns the data of a bundle.
+	BundleData(url *charm.URL) (*charm.BundleData, error)
+
+	// Archive returns the archive of a charm or bundle.
+	// The caller is responsible for closing it.
+	Archive(url *charm.URL) (io.ReadCloser, error)
+
+	// Resources returns the resources of a charm.
+	Resources(url *charm.URL) ([]resource.Resource, error)
+
+	// Resource returns the named resource of a charm along
+	// with its content. The caller is responsible for closing
+	// the content.
+	Resource(url *charm.URL, name string) (resource.Resource, io.ReadCloser, error)
+}
+
+// Params holds parameters for NewClient.
+type Params struct {
+	// URL holds the root URL of the charm store,
+	// for example "https://api.jujucharms.com/charmstore".
+	URL string
+
+	// HTTPClient holds the client used to make requests.
+	// If it is nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// NewClient returns a Client that makes requests to the charm store
+// at p.URL.
+func NewClient(p Params) Client {
+	c := &client{
+		url:  strings.TrimSuffix(p.URL, "/") + "/" + APIVersion,
+		http: p.HTTPClient,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+type client struct {
+	url  string
+	http *http.Client
+}
+
+// Resolve implements Client.Resolve.
+func (c *client) Resolve(url *charm.URL) (*charm.URL, error) {
+	var resp IdResponse
+	if err := c.getJSON(url, "meta/id", &resp); err != nil {
+		return nil, errors.Trace(err)
+	}
+	resolved, err := charm.ParseURL(resp.Id)
+	if err != nil {
+		return nil, errors.Annotatef(err, "cannot resolve %q", url)
+	}
+	// The store does not include the channel in the id.
+	return resolved.WithChannel(url.Channel), nil
+}
+
+// Revisions implements Client.Revisions.
+func (c *client) Revisions(url *charm.URL) ([]*charm.URL, error) {
+	var resp RevisionInfoResponse
+	if err := c.getJSON(url, "meta/revision-info", &resp); err != nil {
+		return nil, errors.Trace(err)
+	}
+	urls := make([]*charm.URL, len(resp.Revisions))
+	for i, s := range resp.Revisions {
+		u, err := charm.ParseURL(s)
+		if err != nil {
+			return nil, errors.Annotatef(err, "cannot get revisions of %q", url)
+		}
+		urls[i] = u.WithChannel(url.Channel)
+	}
+	return urls, nil
+}
+
+// Meta implements Client.Meta.
+func (c *client) Meta(url *charm.URL) (*charm.Meta, error) {
+	var meta charm.Meta
+	if err := c.getJSON(url, "meta/charm-metadata", &meta); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &meta, nil
+}
+
+// Config implements Client.Config.
+func (c *client) Config(url *charm.URL) (*charm.Config, error) {
+	var config charm.Config
+	if err := c.getJSON(url, "meta/charm-config", &config); err != nil {
+		return nil, errors.Trace(err)
+	}
+	// Defaults unmarshaled from JSON hold float64 values for int
+	// options, where ReadConfig gives int64 values.
+	for name, option := range config.Options {
+		if def, ok := option.Default.(float64); ok && option.Type == "int" {
+			option.Default = int64(def)
+			config.Options[name] = option
+		}
+	}
+	return &config, nil
+}
+
+// BundleData implements Client.BundleData.
+func (c *client) BundleData(url *charm.URL) (*charm.BundleData, error) {
+	var bd charm.BundleData
+	if err := c.getJSON(url, "meta/bundle-metadata", &bd); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &bd, nil
+}
+
+// Archive implements Client.Archive.
+func (c *client) Archive(url *charm.URL) (io.ReadCloser, error) {
+	r, err := c.get(url, "archive")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return r, nil
+}
+
+// Resources implements Client.Resources.
+func (c *client) Resources(url *charm.URL) ([]resource.Resource, error) {
+	var infos []ResourceInfo
+	if err := c.getJSON(url, "meta/resources", &infos); err != nil {
+		return nil, errors.Trace(err)
+	}
+	resources := make([]resource.Resource, len(infos))
+	for i, info := range infos {
+		res, err := info.resource()
+		if err != nil {
+			return nil, errors.Annotatef(err, "cannot read resources of %q", url)
+		}
+		resources[i] = res
+	}
+	return resources, nil
+}
+
+// Resource implements Client.Resource.
+func (c *client) Resource(url *charm.URL, name string) (resource.Resource, io.ReadCloser, error) {
+	resources, err := c.Resources(url)
+	if err != nil {
+		return resource.Resource{}, nil, errors.Trace(err)
+	}
+	for _, res := range resources {
+		if res.Name != name {
+			continue
+		}
+		r, err := c.get(url, "resource/"+name)
+		if err != nil {
+			return resource.Resource{}, nil, errors.Trace(err)
+		}
+		return res, r, nil
+	}
+	return resource.Resource{}, nil, errors.NotFoundf("resource %q of %q", name, url)
+}
+
+func (info ResourceInfo) resource() (resource.Resource, error) {
+	rtype, err := resource.ParseType(info.Type)
+	if err != nil {
+		return resource.Resource{}, errors.Trace(err)
+	}
+	var fp resource.Fingerprint
+	if len(info.Fingerprint) > 0 {
+		fp, err = resource.NewFingerprint(info.Fingerprint)
+		if err != nil {
+			return resource.Resource{}, errors.Annotatef(err, "resource %q", info.Name)
+		}
+	}
+	res := resource.Resource{
+		Meta: resource.Meta{
+			Name:        info.Name,
+			Type:        rtype,
+			Path:        info.Path,
+			Description: info.Description,
+		},
+		Origin:      resource.OriginStore,
+		Revision:    info.Revision,
+		Fingerprint: fp,
+		Size:        info.Size,
+	}
+	if err := res.Validate(); err != nil {
+		return resource.Resource{}, errors.Trace(err)
+	}
+	return res, nil
+}
+
+func (c *client) getJSON(url *charm.URL, endpoint string, v interface{}) error {
+	r, err := c.get(url, endpoint)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer r.Close()
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return errors.Annotatef(err, "cannot unmarshal %s response for %q", endpoint, url)
+	}
+	return nil
+}
+
+// get makes a GET request to the given endpoint of the
+// charm or bundle with the given URL, and returns the
+// body of the response.
+func (c *client) get(url *charm.URL, endpoint string) (io.ReadCloser, error) {
+	store, ok := charm.StoreForSchema(url.Schema)
+	if !ok {
+		return nil, errors.Errorf("cannot get %q from a charm store: unknown schema", url)
+	}
+	if store.Local {
+		return nil, errors.Errorf("cannot get %q from a charm store: local charm or bundle URL", url)
+	}
+	reqURL := c.url + "/" + url.WithChannel(charm.Channel{}).Path() + "/" + endpoint
+	if url.Channel != (charm.Channel{}) {
+		reqURL += "?channel=" + neturl.QueryEscape(url.Channel.String())
+	}
+	resp, err := c.http.Get(reqURL)
+	if err != nil {
+		return nil, errors.Annotatef(err, "cannot get %q", url)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+	var serr Error
+	data, _ := ioutil.ReadAll(resp.Body)
+	if err := json.Unmarshal(data, &serr); err != nil || serr.Message == "" {
+		serr.Message = fmt.Sprintf("unexpected response status %q", resp.Status)
+	}
+	if serr.Code == CodeNotFound {
+		return nil, errors.NewNotFound(&serr, "")
+	}
+	return nil, errors.Annotatef(&serr, "cannot get %q", url)
+}
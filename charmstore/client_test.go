@@ -0,0 +1,285 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charmstore_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/utils/fs"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+	"gopkg.in/juju/charm.v6/charmstore"
+	"gopkg.in/juju/charm.v6/charmstore/charmstoretesting"
+	"gopkg.in/juju/charm.v6/resource"
+)
+
+type ClientSuite struct {
+	testing.IsolationSuite
+	server *charmstoretesting.Server
+	client charmstore.Client
+}
+
+var _ = gc.Suite(&ClientSuite{})
+
+const repoPath = "../internal/test-charm-repo"
+
+func (s *ClientSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	repo, err := charm.NewLocalRepository(repoPath)
+	c.Assert(err, jc.ErrorIsNil)
+	s.server = charmstoretesting.NewServer(repo)
+	s.client = s.server.Client()
+}
+
+func (s *ClientSuite) TearDownTest(c *gc.C) {
+	s.server.Close()
+	s.IsolationSuite.TearDownTest(c)
+}
+
+var resolveTests = []struct {
+	url      string
+	expected string
+	err      string
+}{{
+	url:      "cs:quantal/upgrade",
+	expected: "cs:quantal/upgrade-2",
+}, {
+	url:      "cs:upgrade-1",
+	expected: "cs:quantal/upgrade-1",
+}, {
+	url:      "cs:~bob/edge/quantal/wordpress",
+	expected: "cs:~bob/edge/quantal/wordpress-3",
+}, {
+	url:      "cs:bundle/wordpress-simple",
+	expected: "cs:bundle/wordpress-simple-0",
+}, {
+	url:      "https://jujucharms.com/upgrade/quantal/1",
+	expected: "cs:quantal/upgrade-1",
+}, {
+	url:      "https://jujucharms.com/u/bob/2.0/candidate/wordpress",
+	expected: "cs:~bob/2.0/candidate/quantal/wordpress-3",
+}, {
+	url: "cs:quantal/missing",
+	err: `charm or bundle "cs:quantal/missing" not found`,
+}, {
+	url: "local:quantal/upgrade",
+	err: `cannot get "local:quantal/upgrade" from a charm store: local charm or bundle URL`,
+}}
+
+func (s *ClientSuite) TestResolve(c *gc.C) {
+	for i, test := range resolveTests {
+		c.Logf("test %d: %s", i, test.url)
+		url, err := s.client.Resolve(charm.MustParseURL(test.url))
+		if test.err != "" {
+			c.Assert(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(url.String(), gc.Equals, test.expected)
+	}
+}
+
+func (s *ClientSuite) TestResolveRegisteredLocalSchema(c *gc.C) {
+	err := charm.RegisterStore(charm.Store{
+		Schema: "file",
+		Local:  true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	s.AddCleanup(func(*gc.C) { charm.UnregisterStore("file") })
+
+	_, err = s.client.Resolve(charm.MustParseURL("file:quantal/upgrade"))
+	c.Assert(err, gc.ErrorMatches, `cannot get "file:quantal/upgrade" from a charm store: local charm or bundle URL`)
+}
+
+func (s *ClientSuite) TestRequests(c *gc.C) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests = append(requests, req.URL.String())
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/meta/id"):
+			w.Write([]byte(`{"Id": "cs:~bob/xenial/wordpress-3"}`))
+		case strings.HasSuffix(req.URL.Path, "/meta/charm-config"):
+			w.Write([]byte(`{"Options": {"n": {"Type": "int", "Default": 3}}}`))
+		}
+	}))
+	defer server.Close()
+	client := charmstore.NewClient(charmstore.Params{URL: server.URL})
+
+	// The channel is sent as a query parameter, and the
+	// resolved URL keeps it.
+	url, err := client.Resolve(charm.MustParseURL("cs:~bob/2.0/edge/wordpress"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(url.String(), gc.Equals, "cs:~bob/2.0/edge/xenial/wordpress-3")
+
+	// Config defaults have the types that ReadConfig gives them.
+	config, err := client.Config(charm.MustParseURL("cs:xenial/wordpress"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(config.Options["n"].Default, gc.Equals, int64(3))
+
+	c.Assert(requests, jc.DeepEquals, []string{
+		"/v5/~bob/wordpress/meta/id?channel=2.0%2Fedge",
+		"/v5/xenial/wordpress/meta/charm-config",
+	})
+}
+
+func (s *ClientSuite) TestNotFound(c *gc.C) {
+	_, err := s.client.Meta(charm.MustParseURL("cs:quantal/missing"))
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *ClientSuite) TestRevisions(c *gc.C) {
+	urls, err := s.client.Revisions(charm.MustParseURL("cs:~bob/quantal/upgrade-1"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(urls, jc.DeepEquals, []*charm.URL{
+		charm.MustParseURL("cs:~bob/quantal/upgrade-2"),
+		charm.MustParseURL("cs:~bob/quantal/upgrade-1"),
+	})
+}
+
+func (s *ClientSuite) TestMetaAndConfig(c *gc.C) {
+	dir, err := charm.ReadCharmDir(filepath.Join(repoPath, "quantal", "dummy"))
+	c.Assert(err, jc.ErrorIsNil)
+
+	meta, err := s.client.Meta(charm.MustParseURL("https://jujucharms.com/dummy/quantal"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(meta, jc.DeepEquals, dir.Meta())
+
+	config, err := s.client.Config(charm.MustParseURL("cs:quantal/dummy"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(config, jc.DeepEquals, dir.Config())
+
+	_, err = s.client.Meta(charm.MustParseURL("cs:bundle/wordpress-simple"))
+	c.Assert(err, gc.ErrorMatches, `cannot get "cs:bundle/wordpress-simple": "local:bundle/wordpress-simple-0" is not a charm`)
+}
+
+func (s *ClientSuite) TestBundleData(c *gc.C) {
+	dir, err := charm.ReadBundleDir(filepath.Join(repoPath, "bundle", "wordpress-simple"))
+	c.Assert(err, jc.ErrorIsNil)
+
+	bd, err := s.client.BundleData(charm.MustParseURL("cs:bundle/wordpress-simple"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bd, jc.DeepEquals, dir.Data())
+}
+
+func (s *ClientSuite) TestArchive(c *gc.C) {
+	r, err := s.client.Archive(charm.MustParseURL("cs:quantal/wordpress"))
+	c.Assert(err, jc.ErrorIsNil)
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	c.Assert(err, jc.ErrorIsNil)
+	ch, err := charm.ReadCharmArchiveBytes(data)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ch.Meta().Name, gc.Equals, "wordpress")
+	c.Assert(ch.Revision(), gc.Equals, 3)
+
+	r, err = s.client.Archive(charm.MustParseURL("cs:bundle/wordpress-simple"))
+	c.Assert(err, jc.ErrorIsNil)
+	data, err = ioutil.ReadAll(r)
+	r.Close()
+	c.Assert(err, jc.ErrorIsNil)
+	b, err := charm.ReadBundleArchiveBytes(data)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(b.Data().Applications, gc.HasLen, 2)
+}
+
+const resourcesMeta = `
+name: dummy
+summary: s
+description: d
+resources:
+  data:
+    type: file
+    filename: data.tgz
+    description: some data
+  image:
+    type: oci-image
+`
+
+// newServer starts a server for a new repository holding the
+// dummy charm, with the given metadata, in each of the given
+// series.
+func newServer(c *gc.C, metadata string, series ...string) *charmstoretesting.Server {
+	path := c.MkDir()
+	for _, series := range series {
+		charmPath := filepath.Join(path, series, "dummy")
+		err := os.MkdirAll(filepath.Dir(charmPath), 0755)
+		c.Assert(err, jc.ErrorIsNil)
+		err = fs.Copy(filepath.Join(repoPath, "quantal", "dummy"), charmPath)
+		c.Assert(err, jc.ErrorIsNil)
+		err = ioutil.WriteFile(filepath.Join(charmPath, "metadata.yaml"), []byte(metadata), 0644)
+		c.Assert(err, jc.ErrorIsNil)
+	}
+	repo, err := charm.NewLocalRepository(path)
+	c.Assert(err, jc.ErrorIsNil)
+	return charmstoretesting.NewServer(repo)
+}
+
+func (s *ClientSuite) TestResolveAmbiguousSeries(c *gc.C) {
+	server := newServer(c, resourcesMeta, "trusty", "xenial")
+	defer server.Close()
+
+	_, err := server.Client().Resolve(charm.MustParseURL("cs:dummy"))
+	c.Assert(err, gc.ErrorMatches, `cannot get "cs:dummy": cannot resolve "cs:dummy": available in series trusty, xenial`)
+	url, err := server.Client().Resolve(charm.MustParseURL("cs:xenial/dummy"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(url.String(), gc.Equals, "cs:xenial/dummy-1")
+}
+
+func (s *ClientSuite) TestResources(c *gc.C) {
+	server := newServer(c, resourcesMeta, "quantal")
+	defer server.Close()
+	client := server.Client()
+
+	url := charm.MustParseURL("cs:dummy")
+	err := server.SetResource(url, "data", []byte("first"))
+	c.Assert(err, jc.ErrorIsNil)
+	err = server.SetResource(url, "data", []byte("second"))
+	c.Assert(err, jc.ErrorIsNil)
+	err = server.SetResource(url, "missing", nil)
+	c.Assert(err, gc.ErrorMatches, `resource "missing" of "cs:dummy" not found`)
+
+	resources, err := client.Resources(url)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(resources, gc.HasLen, 2)
+	fp, err := resource.GenerateFingerprint(strings.NewReader("second"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(resources[0], jc.DeepEquals, resource.Resource{
+		Meta: resource.Meta{
+			Name:        "data",
+			Type:        resource.TypeFile,
+			Path:        "data.tgz",
+			Description: "some data",
+		},
+		Origin:      resource.OriginStore,
+		Revision:    1,
+		Fingerprint: fp,
+		Size:        6,
+	})
+	c.Assert(resources[1].Name, gc.Equals, "image")
+	c.Assert(resources[1].Revision, gc.Equals, -1)
+	c.Assert(resources[1].Fingerprint.IsZero(), jc.IsTrue)
+
+	res, r, err := client.Resource(url, "data")
+	c.Assert(err, jc.ErrorIsNil)
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Equals, "second")
+	c.Assert(res.Revision, gc.Equals, 1)
+
+	_, _, err = client.Resource(url, "image")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	_, _, err = client.Resource(url, "missing")
+	c.Assert(err, gc.ErrorMatches, `resource "missing" of "cs:dummy" not found`)
+}
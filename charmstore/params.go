@@ -0,0 +1,51 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charmstore
+
+// The types below define the JSON bodies exchanged with the charm
+// store. Charm metadata, charm configuration and bundle data are
+// exchanged as the JSON forms of charm.Meta, charm.Config and
+// charm.BundleData.
+
+// Error holds the body of an error response.
+type Error struct {
+	Message string
+	Code    string `json:",omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// CodeNotFound is the code of an error
+// response for a charm, bundle or
+// resource that cannot be found.
+const CodeNotFound = "not found"
+
+// IdResponse holds the body of a response to
+// a meta/id request.
+type IdResponse struct {
+	Id string
+}
+
+// RevisionInfoResponse holds the body of a response to a
+// meta/revision-info request. The revisions are ordered
+// from the most recent to the oldest.
+type RevisionInfoResponse struct {
+	Revisions []string
+}
+
+// ResourceInfo describes a resource in the body of a
+// meta/resources response. A resource whose content
+// has not been uploaded has a revision of -1 and no
+// fingerprint.
+type ResourceInfo struct {
+	Name        string
+	Type        string
+	Path        string
+	Description string `json:",omitempty"`
+	Revision    int
+	Fingerprint []byte
+	Size        int64
+}
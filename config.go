@@ -51,10 +51,10 @@ func (option Option) validate(name string, value interface{}) (_ interface{}, er
 }
 
 var optionTypeCheckers = map[string]schema.Checker{
-	"string":  schema.String(),
-	"int":     schema.Int(),
-	"float":   schema.Float(),
-	"boolean": schema.Bool(),
+	"string":  stringSchema(),
+	"int":     intSchema(),
+	"float":   floatSchema(),
+	"boolean": boolSchema(),
 }
 
 func (option Option) parse(name, str string) (val interface{}, err error) {
@@ -86,15 +86,17 @@ func NewConfig() *Config {
 	return &Config{map[string]Option{}}
 }
 
-// ReadConfig reads a Config in YAML format.
+// ReadConfig reads a Config in YAML format. Problems that can be
+// traced to a location in the YAML are reported with a *ParseError.
 func ReadConfig(r io.Reader) (*Config, error) {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
+	src := newYAMLSource(data)
 	var config *Config
 	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, err
+		return nil, src.yamlError(err)
 	}
 	if config == nil {
 		return nil, fmt.Errorf("invalid config: empty configuration")
@@ -106,7 +108,7 @@ func ReadConfig(r io.Reader) (*Config, error) {
 		// into interface{} and explicitly checking the field.
 		var configInterface interface{}
 		if err := yaml.Unmarshal(data, &configInterface); err != nil {
-			return nil, err
+			return nil, src.yamlError(err)
 		}
 		m, _ := configInterface.(map[interface{}]interface{})
 		if _, ok := m["options"]; !ok {
@@ -120,14 +122,14 @@ func ReadConfig(r io.Reader) (*Config, error) {
 			// Missing type is valid in python.
 			option.Type = "string"
 		default:
-			return nil, fmt.Errorf("invalid config: option %q has unknown type %q", name, option.Type)
+			return nil, src.pathErrorf("options."+name+".type", "invalid config: option %q has unknown type %q", name, option.Type)
 		}
 		def := option.Default
 		if def == "" && option.Type == "string" {
 			// Skip normal validation for compatibility with pyjuju.
 		} else if option.Default, err = option.validate(name, def); err != nil {
 			option.error(&err, name, def)
-			return nil, fmt.Errorf("invalid config default: %v", err)
+			return nil, src.pathErrorf("options."+name+".default", "invalid config default: %v", err)
 		}
 		config.Options[name] = option
 	}
@@ -0,0 +1,287 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/juju/schema"
+
+	"gopkg.in/juju/charm.v6/resource"
+)
+
+// Container describes a workload container that is run
+// alongside the charm, as stored in a charm's metadata.
+type Container struct {
+	// Resource names the oci-image resource in the charm's
+	// metadata that holds the container's image.
+	Resource string `bson:"resource"`
+
+	// Mounts holds the stores from the charm's storage
+	// section that are mounted into the container.
+	Mounts []Mount `bson:"mounts,omitempty"`
+}
+
+// Mount describes where a store is mounted within a container.
+type Mount struct {
+	// Storage names the store in the charm's storage section.
+	Storage string `bson:"storage"`
+
+	// Location is the absolute path at which the store is
+	// mounted within the container. If it is empty, the
+	// store's own location is used.
+	Location string `bson:"location,omitempty"`
+}
+
+// DeploymentType describes how the units of an application
+// are deployed on a container substrate.
+type DeploymentType string
+
+const (
+	DeploymentStateless DeploymentType = "stateless"
+	DeploymentStateful  DeploymentType = "stateful"
+	DeploymentDaemon    DeploymentType = "daemon"
+)
+
+// DeploymentMode describes what the charm deploys: either
+// an operator that manages the workload itself, or the
+// workload containers described by the charm.
+type DeploymentMode string
+
+const (
+	ModeOperator DeploymentMode = "operator"
+	ModeWorkload DeploymentMode = "workload"
+)
+
+// ServiceType describes how an application's workload
+// is exposed on a container substrate.
+type ServiceType string
+
+const (
+	ServiceCluster      ServiceType = "cluster"
+	ServiceLoadBalancer ServiceType = "loadbalancer"
+	ServiceExternal     ServiceType = "external"
+	ServiceOmit         ServiceType = "omit"
+)
+
+// Deployment holds the deployment settings for charms
+// that run on container substrates.
+type Deployment struct {
+	DeploymentType DeploymentType `bson:"type,omitempty"`
+	DeploymentMode DeploymentMode `bson:"mode,omitempty"`
+	ServiceType    ServiceType    `bson:"service,omitempty"`
+
+	// MinVersion holds the minimum version of the
+	// substrate that the charm supports, if any.
+	MinVersion string `bson:"min-version,omitempty"`
+}
+
+// When specified, the "containers" section in the metadata.yaml
+// should have the following format:
+//
+//	containers:
+//	    <container-name>:
+//	        resource: <oci-image resource name>
+//	        mounts:
+//	            - storage: <storage name>
+//	              location: <absolute path>
+//	    ...
+var containerSchema = collectingFieldMap(
+	schema.Fields{
+		"resource": stringSchema(),
+		"mounts": listSchema(fieldMapSchema(
+			schema.Fields{
+				"storage":  stringSchema(),
+				"location": stringSchema(),
+			},
+			schema.Defaults{
+				"location": schema.Omit,
+			},
+		)),
+	},
+	schema.Defaults{
+		"resource": schema.Omit,
+		"mounts":   schema.Omit,
+	},
+)
+
+// When specified, the "deployment" section in the metadata.yaml
+// should have the following format, where all fields are optional:
+//
+//	deployment:
+//	    type: stateless | stateful | daemon
+//	    mode: operator | workload
+//	    service: cluster | loadbalancer | external | omit
+//	    min-version: <version>
+var deploymentSchema = collectingFieldMap(
+	schema.Fields{
+		// The values are checked by validateMetaDeployment, which
+		// gives more helpful errors than the schema would.
+		"type": describedC{schema.String(), map[string]interface{}{
+			"enum": []string{string(DeploymentStateless), string(DeploymentStateful), string(DeploymentDaemon)},
+		}},
+		"mode": describedC{schema.String(), map[string]interface{}{
+			"enum": []string{string(ModeOperator), string(ModeWorkload)},
+		}},
+		"service": describedC{schema.String(), map[string]interface{}{
+			"enum": []string{string(ServiceCluster), string(ServiceLoadBalancer), string(ServiceExternal), string(ServiceOmit)},
+		}},
+		"min-version": stringSchema(),
+	},
+	schema.Defaults{
+		"type":        schema.Omit,
+		"mode":        schema.Omit,
+		"service":     schema.Omit,
+		"min-version": schema.Omit,
+	},
+)
+
+func parseContainers(data interface{}) map[string]Container {
+	if data == nil {
+		return nil
+	}
+	result := make(map[string]Container)
+	for name, val := range data.(map[string]interface{}) {
+		result[name] = parseContainer(val)
+	}
+	return result
+}
+
+func parseContainer(data interface{}) Container {
+	var container Container
+	cMap := data.(map[string]interface{})
+	if val, ok := cMap["resource"].(string); ok {
+		container.Resource = val
+	}
+	if mounts, ok := cMap["mounts"].([]interface{}); ok {
+		for _, m := range mounts {
+			mMap := m.(map[string]interface{})
+			mount := Mount{
+				Storage: mMap["storage"].(string),
+			}
+			if loc, ok := mMap["location"].(string); ok {
+				mount.Location = loc
+			}
+			container.Mounts = append(container.Mounts, mount)
+		}
+	}
+	return container
+}
+
+func parseDeployment(data interface{}) *Deployment {
+	if data == nil {
+		return nil
+	}
+	dMap := data.(map[string]interface{})
+	var deployment Deployment
+	if val, ok := dMap["type"].(string); ok {
+		deployment.DeploymentType = DeploymentType(val)
+	}
+	if val, ok := dMap["mode"].(string); ok {
+		deployment.DeploymentMode = DeploymentMode(val)
+	}
+	if val, ok := dMap["service"].(string); ok {
+		deployment.ServiceType = ServiceType(val)
+	}
+	if val, ok := dMap["min-version"].(string); ok {
+		deployment.MinVersion = val
+	}
+	return &deployment
+}
+
+// validateMetaContainers checks that the containers in meta refer
+// to oci-image resources and filesystem stores that it declares.
+func validateMetaContainers(meta Meta) error {
+	names := make([]string, 0, len(meta.Containers))
+	for name := range meta.Containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs metaErrors
+	for _, name := range names {
+		container := meta.Containers[name]
+		cpath := "containers." + name
+		if container.Resource == "" {
+			errs.addf(cpath+".resource", "container %q: resource must be specified", name)
+		} else if res, ok := meta.Resources[container.Resource]; !ok {
+			errs.addf(cpath+".resource", "container %q: resource %q not found", name, container.Resource)
+		} else if res.Type != resource.TypeContainerImage {
+			errs.addf(cpath+".resource", "container %q: resource %q is not of type %q", name, container.Resource, resource.TypeContainerImage)
+		}
+		for i, mount := range container.Mounts {
+			mpath := fmt.Sprintf("%s.mounts[%d]", cpath, i)
+			if mount.Storage == "" {
+				errs.addf(mpath+".storage", "container %q: mount storage must be specified", name)
+			} else if store, ok := meta.Storage[mount.Storage]; !ok {
+				errs.addf(mpath+".storage", "container %q: storage %q not found", name, mount.Storage)
+			} else if store.Type != StorageFilesystem {
+				errs.addf(mpath+".storage", "container %q: storage %q is not of type %q", name, mount.Storage, StorageFilesystem)
+			}
+			if mount.Location != "" && !path.IsAbs(mount.Location) {
+				errs.addf(mpath+".location", "container %q: mount location %q is not absolute", name, mount.Location)
+			}
+		}
+	}
+	return errs.err()
+}
+
+// validateMetaDeployment checks that any deployment settings
+// in meta hold known values.
+func validateMetaDeployment(meta Meta) error {
+	d := meta.Deployment
+	if d == nil {
+		return nil
+	}
+	var errs metaErrors
+	switch d.DeploymentType {
+	case "", DeploymentStateless, DeploymentStateful, DeploymentDaemon:
+	default:
+		errs.addf("deployment.type", "invalid deployment type %q", d.DeploymentType)
+	}
+	switch d.DeploymentMode {
+	case "", ModeOperator, ModeWorkload:
+	default:
+		errs.addf("deployment.mode", "invalid deployment mode %q", d.DeploymentMode)
+	}
+	switch d.ServiceType {
+	case "", ServiceCluster, ServiceLoadBalancer, ServiceExternal, ServiceOmit:
+	default:
+		errs.addf("deployment.service", "invalid service type %q", d.ServiceType)
+	}
+	return errs.err()
+}
+
+type marshaledContainer struct {
+	Resource string           `yaml:"resource"`
+	Mounts   []marshaledMount `yaml:"mounts,omitempty"`
+}
+
+type marshaledMount struct {
+	Storage  string `yaml:"storage"`
+	Location string `yaml:"location,omitempty"`
+}
+
+func marshaledContainers(containers map[string]Container) map[string]marshaledContainer {
+	marshaled := make(map[string]marshaledContainer, len(containers))
+	for name, container := range containers {
+		c := marshaledContainer{
+			Resource: container.Resource,
+		}
+		for _, mount := range container.Mounts {
+			c.Mounts = append(c.Mounts, marshaledMount(mount))
+		}
+		marshaled[name] = c
+	}
+	return marshaled
+}
+
+type marshaledDeployment struct {
+	DeploymentType DeploymentType `yaml:"type,omitempty"`
+	DeploymentMode DeploymentMode `yaml:"mode,omitempty"`
+	ServiceType    ServiceType    `yaml:"service,omitempty"`
+	MinVersion     string         `yaml:"min-version,omitempty"`
+}
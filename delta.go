@@ -0,0 +1,349 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/juju/utils/set"
+	"gopkg.in/yaml.v2"
+)
+
+// deltaFileName holds the name of the entry in a delta archive that
+// describes the delta. The content of the added and modified files
+// is held in entries under deltaFilesDir.
+const (
+	deltaFileName = "delta.yaml"
+	deltaFilesDir = "files/"
+)
+
+// metadataFiles holds the files that describe a charm.
+var metadataFiles = set.NewStrings("metadata.yaml", "config.yaml", "actions.yaml", "metrics.yaml")
+
+// CharmDelta holds the changes between two revisions of a charm,
+// from which the newer revision can be rebuilt from the older one.
+// The files are compared as they are found once the charms are
+// expanded, as by DigestManifest. Directories are not held in a
+// delta, so empty directories are not preserved.
+type CharmDelta struct {
+	// OldRevision and NewRevision hold the
+	// revisions of the old and new charms.
+	OldRevision int
+	NewRevision int
+
+	// OldDigest and NewDigest hold the digests of the
+	// manifests of the old and new charms, as returned
+	// by DigestManifest.Digest.
+	OldDigest []byte
+	NewDigest []byte
+
+	// Added, Removed and Modified hold the sorted
+	// paths of the files that differ between the charms.
+	Added    []string
+	Removed  []string
+	Modified []string
+
+	// Metadata holds the sorted paths of those of the charm's
+	// descriptor files, metadata.yaml, config.yaml, actions.yaml
+	// and metrics.yaml, that have been added, removed or modified.
+	// It records only which of them changed; use CheckUpgrade to
+	// find how the charm's metadata, config and actions differ.
+	Metadata []string
+
+	// newFiles walks the added and modified files.
+	newFiles charmFileWalker
+}
+
+// NewCharmDelta returns the changes from oldCharm to newCharm,
+// each of which must be a *CharmDir, *CharmArchive or
+// *CharmTarArchive.
+func NewCharmDelta(oldCharm, newCharm Charm) (*CharmDelta, error) {
+	oldFiles, err := charmFiles(oldCharm)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := charmFiles(newCharm)
+	if err != nil {
+		return nil, err
+	}
+	oldManifest, err := digestManifest(oldFiles)
+	if err != nil {
+		return nil, err
+	}
+	newManifest, err := digestManifest(newFiles)
+	if err != nil {
+		return nil, err
+	}
+	diff := oldManifest.Compare(newManifest)
+	d := &CharmDelta{
+		OldRevision: oldCharm.Revision(),
+		NewRevision: newCharm.Revision(),
+		OldDigest:   oldManifest.Digest(),
+		NewDigest:   newManifest.Digest(),
+		Added:       diff.Added,
+		Removed:     diff.Missing,
+		Modified:    diff.Drifted,
+	}
+	d.Metadata = d.metadata()
+	d.newFiles = &selectedFiles{
+		walker: newFiles,
+		names:  set.NewStrings(append(d.Added, d.Modified...)...),
+	}
+	return d, nil
+}
+
+// metadata returns the changed files that describe the charm.
+func (d *CharmDelta) metadata() []string {
+	var changed []string
+	for _, names := range [][]string{d.Added, d.Removed, d.Modified} {
+		for _, name := range names {
+			if metadataFiles.Contains(name) {
+				changed = append(changed, name)
+			}
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// selectedFiles walks the files of a charm with the given names.
+type selectedFiles struct {
+	walker charmFileWalker
+	names  set.Strings
+}
+
+func (s *selectedFiles) walkFiles(f func(name string, mode os.FileMode, r io.Reader) error) error {
+	return s.walker.walkFiles(func(name string, mode os.FileMode, r io.Reader) error {
+		if !s.names.Contains(name) {
+			return nil
+		}
+		return f(name, mode, r)
+	})
+}
+
+// deltaHeader holds the form of a CharmDelta
+// that is written to the delta archive.
+type deltaHeader struct {
+	OldRevision int      `yaml:"old-revision"`
+	NewRevision int      `yaml:"new-revision"`
+	OldDigest   string   `yaml:"old-digest"`
+	NewDigest   string   `yaml:"new-digest"`
+	Added       []string `yaml:"added,omitempty"`
+	Removed     []string `yaml:"removed,omitempty"`
+	Modified    []string `yaml:"modified,omitempty"`
+}
+
+// ArchiveTo writes the delta to w as a delta archive, which holds
+// the content of the added and modified files. It can be read with
+// ReadCharmDelta. By convention a delta archive has a
+// ".charm-delta" suffix.
+func (d *CharmDelta) ArchiveTo(w io.Writer) error {
+	data, err := yaml.Marshal(deltaHeader{
+		OldRevision: d.OldRevision,
+		NewRevision: d.NewRevision,
+		OldDigest:   hex.EncodeToString(d.OldDigest),
+		NewDigest:   hex.EncodeToString(d.NewDigest),
+		Added:       d.Added,
+		Removed:     d.Removed,
+		Modified:    d.Modified,
+	})
+	if err != nil {
+		return err
+	}
+	zipw := zip.NewWriter(w)
+	if err := writeZipFile(zipw, deltaFileName, 0644, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	err = d.newFiles.walkFiles(func(name string, mode os.FileMode, r io.Reader) error {
+		return writeZipFile(zipw, deltaFilesDir+name, mode, r)
+	})
+	if err != nil {
+		return err
+	}
+	return zipw.Close()
+}
+
+// writeZipFile writes a file with the given name, mode and content
+// to zipw, normalising its mode as DigestManifest does.
+func writeZipFile(zipw *zip.Writer, name string, mode os.FileMode, r io.Reader) error {
+	w, err := createZipFile(zipw, name, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// createZipFile adds a file with the given name and mode to zipw,
+// normalising its mode as DigestManifest does, and returns a writer
+// for its content.
+func createZipFile(zipw *zip.Writer, name string, mode os.FileMode) (io.Writer, error) {
+	mode = digestMode(mode)
+	h := &zip.FileHeader{
+		Name:   name,
+		Method: zip.Deflate,
+	}
+	if mode&os.ModeSymlink != 0 {
+		h.Method = zip.Store
+	}
+	h.SetMode(mode)
+	h.SetModTime(archiveModTime)
+	return zipw.CreateHeader(h)
+}
+
+// ReadCharmDelta reads a delta archive written by CharmDelta.ArchiveTo.
+func ReadCharmDelta(path string) (*CharmDelta, error) {
+	return readCharmDelta(newZipOpenerFromPath(path))
+}
+
+// ReadCharmDeltaBytes reads a delta archive from the given data.
+func ReadCharmDeltaBytes(data []byte) (*CharmDelta, error) {
+	return readCharmDelta(newZipOpenerFromReader(bytes.NewReader(data), int64(len(data))))
+}
+
+func readCharmDelta(zopen zipOpener) (*CharmDelta, error) {
+	zipr, err := zopen.openZip()
+	if err != nil {
+		return nil, err
+	}
+	defer zipr.Close()
+	if err := checkZipFiles(zipr.File, DefaultExpandLimits); err != nil {
+		return nil, err
+	}
+	r, err := zipOpenFile(zipr, deltaFileName)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+	var h deltaHeader
+	if err := yaml.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("cannot read delta: %v", err)
+	}
+	d := &CharmDelta{
+		OldRevision: h.OldRevision,
+		NewRevision: h.NewRevision,
+		Added:       h.Added,
+		Removed:     h.Removed,
+		Modified:    h.Modified,
+		newFiles:    &deltaFiles{zopen},
+	}
+	if d.OldDigest, err = hex.DecodeString(h.OldDigest); err != nil {
+		return nil, fmt.Errorf("cannot read delta: invalid old digest: %v", err)
+	}
+	if d.NewDigest, err = hex.DecodeString(h.NewDigest); err != nil {
+		return nil, fmt.Errorf("cannot read delta: invalid new digest: %v", err)
+	}
+	for _, names := range [][]string{d.Added, d.Removed, d.Modified} {
+		for _, name := range names {
+			if err := checkArchivePath(name); err != nil {
+				return nil, err
+			}
+			if name == "." || path.Clean(name) != name {
+				return nil, fmt.Errorf("cannot read delta: invalid path %q", name)
+			}
+		}
+	}
+	d.Metadata = d.metadata()
+	return d, nil
+}
+
+// deltaFiles walks the files held in a delta archive.
+type deltaFiles struct {
+	zopen zipOpener
+}
+
+func (d *deltaFiles) walkFiles(f func(name string, mode os.FileMode, r io.Reader) error) error {
+	zipr, err := d.zopen.openZip()
+	if err != nil {
+		return err
+	}
+	defer zipr.Close()
+	for _, zf := range zipr.File {
+		if !strings.HasPrefix(zf.Name, deltaFilesDir) || zf.Mode().IsDir() {
+			continue
+		}
+		name := strings.TrimPrefix(zf.Name, deltaFilesDir)
+		if err := checkFileType(name, zf.Mode()); err != nil {
+			return err
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = f(name, zf.Mode(), r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply writes to w a charm archive holding the new charm, rebuilt
+// from oldCharm, which must be a *CharmDir, *CharmArchive or
+// *CharmTarArchive, and the delta. It returns an error if oldCharm
+// is not the charm that the delta was computed from, or if the
+// rebuilt charm does not have the content digest recorded in the
+// delta. The archive is built in memory and checked before any of
+// it is written, so nothing is written to w when Apply fails.
+func (d *CharmDelta) Apply(w io.Writer, oldCharm Charm) error {
+	oldFiles, err := charmFiles(oldCharm)
+	if err != nil {
+		return err
+	}
+	oldManifest, err := digestManifest(oldFiles)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(oldManifest.Digest(), d.OldDigest) {
+		return fmt.Errorf("cannot apply delta: charm does not match the delta's old revision")
+	}
+	replaced := set.NewStrings(append(d.Removed, d.Modified...)...)
+	var buf bytes.Buffer
+	zipw := zip.NewWriter(&buf)
+	rebuilt := make(DigestManifest)
+	write := func(name string, mode os.FileMode, r io.Reader) error {
+		if _, ok := rebuilt[name]; ok {
+			return fmt.Errorf("cannot apply delta: more than one file %q", name)
+		}
+		fw, err := createZipFile(zipw, name, mode)
+		if err != nil {
+			return err
+		}
+		return rebuilt.add(name, mode, io.TeeReader(r, fw))
+	}
+	err = oldFiles.walkFiles(func(name string, mode os.FileMode, r io.Reader) error {
+		if replaced.Contains(name) {
+			return nil
+		}
+		return write(name, mode, r)
+	})
+	if err != nil {
+		return err
+	}
+	if err := d.newFiles.walkFiles(write); err != nil {
+		return err
+	}
+	if err := zipw.Close(); err != nil {
+		return err
+	}
+	if !bytes.Equal(rebuilt.Digest(), d.NewDigest) {
+		return fmt.Errorf("cannot apply delta: rebuilt charm does not match the delta's new revision")
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,215 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type DeltaSuite struct {
+	oldDir *charm.CharmDir
+	newDir *charm.CharmDir
+}
+
+var _ = gc.Suite(&DeltaSuite{})
+
+func (s *DeltaSuite) SetUpTest(c *gc.C) {
+	// The old revision holds a large file that is unchanged
+	// in the new one, so that the delta is much smaller
+	// than the new archive.
+	oldPath := cloneDir(c, charmDirPath(c, "dummy"))
+	big := make([]byte, 64*1024)
+	rand.New(rand.NewSource(0)).Read(big)
+	err := ioutil.WriteFile(filepath.Join(oldPath, "src", "big"), big, 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	s.oldDir, err = charm.ReadCharmDir(oldPath)
+	c.Assert(err, jc.ErrorIsNil)
+
+	newPath := cloneDir(c, oldPath)
+	for name, content := range map[string]string{
+		"revision":            "2",
+		"src/hello.c":         "changed",
+		"hooks/upgrade-charm": "#!/bin/sh\n",
+		"metadata.yaml":       "name: dummy\nsummary: changed\ndescription: d\n",
+	} {
+		err := ioutil.WriteFile(filepath.Join(newPath, name), []byte(content), 0644)
+		c.Assert(err, jc.ErrorIsNil)
+	}
+	err = os.Remove(filepath.Join(newPath, "empty", ".gitkeep"))
+	c.Assert(err, jc.ErrorIsNil)
+	s.newDir, err = charm.ReadCharmDir(newPath)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *DeltaSuite) TestNewCharmDelta(c *gc.C) {
+	delta, err := charm.NewCharmDelta(s.oldDir, s.newDir)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(delta.OldRevision, gc.Equals, 1)
+	c.Assert(delta.NewRevision, gc.Equals, 2)
+	c.Assert(delta.Added, jc.DeepEquals, []string{"hooks/upgrade-charm"})
+	c.Assert(delta.Removed, jc.DeepEquals, []string{"empty/.gitkeep"})
+	c.Assert(delta.Modified, jc.DeepEquals, []string{"metadata.yaml", "revision", "src/hello.c"})
+	c.Assert(delta.Metadata, jc.DeepEquals, []string{"metadata.yaml"})
+
+	oldManifest, err := s.oldDir.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(delta.OldDigest, jc.DeepEquals, oldManifest.Digest())
+	newManifest, err := s.newDir.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(delta.NewDigest, jc.DeepEquals, newManifest.Digest())
+
+	// The delta between archives is the same.
+	oldArchive, err := charm.ReadCharmArchive(archivePath(c, s.oldDir))
+	c.Assert(err, jc.ErrorIsNil)
+	newArchive, err := charm.ReadCharmTarArchive(tarArchivePath(c, s.newDir))
+	c.Assert(err, jc.ErrorIsNil)
+	archiveDelta, err := charm.NewCharmDelta(oldArchive, newArchive)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(archiveDelta.Added, jc.DeepEquals, delta.Added)
+	c.Assert(archiveDelta.Removed, jc.DeepEquals, delta.Removed)
+	c.Assert(archiveDelta.Modified, jc.DeepEquals, delta.Modified)
+	c.Assert(archiveDelta.NewDigest, jc.DeepEquals, delta.NewDigest)
+}
+
+func (s *DeltaSuite) TestApplyDeltaArchive(c *gc.C) {
+	oldArchive, err := charm.ReadCharmArchive(archivePath(c, s.oldDir))
+	c.Assert(err, jc.ErrorIsNil)
+	delta, err := charm.NewCharmDelta(oldArchive, s.newDir)
+	c.Assert(err, jc.ErrorIsNil)
+
+	var buf bytes.Buffer
+	err = delta.ArchiveTo(&buf)
+	c.Assert(err, jc.ErrorIsNil)
+	newArchive, err := ioutil.ReadFile(archivePath(c, s.newDir))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(buf.Len() < len(newArchive)/4, jc.IsTrue, gc.Commentf("delta %d bytes, archive %d bytes", buf.Len(), len(newArchive)))
+
+	path := filepath.Join(c.MkDir(), "delta.charm-delta")
+	err = ioutil.WriteFile(path, buf.Bytes(), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	readDelta, err := charm.ReadCharmDelta(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(readDelta.Added, jc.DeepEquals, delta.Added)
+	c.Assert(readDelta.Removed, jc.DeepEquals, delta.Removed)
+	c.Assert(readDelta.Modified, jc.DeepEquals, delta.Modified)
+	c.Assert(readDelta.Metadata, jc.DeepEquals, delta.Metadata)
+	c.Assert(readDelta.OldDigest, jc.DeepEquals, delta.OldDigest)
+	c.Assert(readDelta.NewDigest, jc.DeepEquals, delta.NewDigest)
+
+	buf.Reset()
+	err = readDelta.Apply(&buf, oldArchive)
+	c.Assert(err, jc.ErrorIsNil)
+	rebuilt, err := charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(rebuilt.Revision(), gc.Equals, 2)
+	c.Assert(rebuilt.Meta().Summary, gc.Equals, "changed")
+
+	expected, err := s.newDir.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+	m, err := rebuilt.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(m, jc.DeepEquals, expected)
+
+	// The rebuilt charm expands as the new one would.
+	dir := filepath.Join(c.MkDir(), "charm")
+	err = rebuilt.ExpandTo(dir)
+	c.Assert(err, jc.ErrorIsNil)
+	info, err := os.Stat(filepath.Join(dir, "hooks", "upgrade-charm"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.Mode()&0100, gc.Not(gc.Equals), os.FileMode(0))
+}
+
+func (s *DeltaSuite) TestApplyComputedDelta(c *gc.C) {
+	delta, err := charm.NewCharmDelta(s.oldDir, s.newDir)
+	c.Assert(err, jc.ErrorIsNil)
+	var buf bytes.Buffer
+	err = delta.Apply(&buf, s.oldDir)
+	c.Assert(err, jc.ErrorIsNil)
+	rebuilt, err := charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(rebuilt.Revision(), gc.Equals, 2)
+}
+
+func (s *DeltaSuite) TestApplyToWrongCharm(c *gc.C) {
+	delta, err := charm.NewCharmDelta(s.oldDir, s.newDir)
+	c.Assert(err, jc.ErrorIsNil)
+	err = delta.Apply(ioutil.Discard, s.newDir)
+	c.Assert(err, gc.ErrorMatches, `cannot apply delta: charm does not match the delta's old revision`)
+}
+
+func (s *DeltaSuite) TestApplyTamperedDelta(c *gc.C) {
+	delta, err := charm.NewCharmDelta(s.oldDir, s.newDir)
+	c.Assert(err, jc.ErrorIsNil)
+	var buf bytes.Buffer
+	err = delta.ArchiveTo(&buf)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Rewrite the delta archive with different content for one file.
+	zipr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	c.Assert(err, jc.ErrorIsNil)
+	var tampered bytes.Buffer
+	zipw := zip.NewWriter(&tampered)
+	for _, f := range zipr.File {
+		w, err := zipw.CreateHeader(&f.FileHeader)
+		c.Assert(err, jc.ErrorIsNil)
+		if f.Name == "files/src/hello.c" {
+			_, err = w.Write([]byte("tampered"))
+			c.Assert(err, jc.ErrorIsNil)
+			continue
+		}
+		r, err := f.Open()
+		c.Assert(err, jc.ErrorIsNil)
+		_, err = io.Copy(w, r)
+		r.Close()
+		c.Assert(err, jc.ErrorIsNil)
+	}
+	c.Assert(zipw.Close(), jc.ErrorIsNil)
+
+	readDelta, err := charm.ReadCharmDeltaBytes(tampered.Bytes())
+	c.Assert(err, jc.ErrorIsNil)
+	var out bytes.Buffer
+	err = readDelta.Apply(&out, s.oldDir)
+	c.Assert(err, gc.ErrorMatches, `cannot apply delta: rebuilt charm does not match the delta's new revision`)
+
+	// Nothing of the unverified archive is written.
+	c.Assert(out.Len(), gc.Equals, 0)
+}
+
+func (s *DeltaSuite) TestReadCharmDeltaInvalid(c *gc.C) {
+	for i, test := range []struct {
+		header string
+		err    string
+	}{{
+		header: "added: [../evil]\n",
+		err:    `unsafe path "../evil" in archive: path leads out of archive`,
+	}, {
+		header: "removed: [src/../src/hello.c]\n",
+		err:    `cannot read delta: invalid path "src/../src/hello.c"`,
+	}, {
+		header: "old-digest: xyz\n",
+		err:    `cannot read delta: invalid old digest: .*`,
+	}} {
+		c.Logf("test %d: %s", i, test.header)
+		var buf bytes.Buffer
+		zipw := zip.NewWriter(&buf)
+		w, err := zipw.Create("delta.yaml")
+		c.Assert(err, jc.ErrorIsNil)
+		_, err = w.Write([]byte(test.header))
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(zipw.Close(), jc.ErrorIsNil)
+		_, err = charm.ReadCharmDeltaBytes(buf.Bytes())
+		c.Assert(err, gc.ErrorMatches, test.err)
+	}
+}
@@ -0,0 +1,323 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/juju/charm.v6/resource"
+)
+
+// FileDigest describes the content of a file in a charm.
+type FileDigest struct {
+	// Mode holds the mode of the file, normalised as it is in an
+	// archive: 0755 for an executable file, 0644 for any other
+	// regular file, and os.ModeSymlink|0777 for a symlink.
+	Mode os.FileMode
+
+	// Size holds the size of the file or,
+	// for a symlink, of its target.
+	Size int64
+
+	// Fingerprint holds the SHA-384 fingerprint of the
+	// content of the file or, for a symlink, of its target.
+	Fingerprint resource.Fingerprint
+}
+
+// Equal reports whether d and other describe the same content.
+func (d FileDigest) Equal(other FileDigest) bool {
+	return d.Mode == other.Mode &&
+		d.Size == other.Size &&
+		bytes.Equal(d.Fingerprint.Bytes(), other.Fingerprint.Bytes())
+}
+
+// DigestManifest holds a digest of each of the files and symlinks
+// in a charm, keyed by slash-separated path. Directories are not
+// included. The digest manifest of a charm directory is the same as
+// that of the archive that ArchiveTo creates from it, and as that of
+// a directory that the archive is expanded into: like ExpandTo, it
+// includes a revision file holding the charm's revision and treats
+// hooks as executable.
+type DigestManifest map[string]FileDigest
+
+// ManifestDiff holds the differences between two digest manifests.
+// Each field holds a sorted list of paths.
+type ManifestDiff struct {
+	// Drifted holds the files whose digests differ.
+	Drifted []string
+
+	// Added holds the files that are only in the other manifest.
+	Added []string
+
+	// Missing holds the files that are absent from the other manifest.
+	Missing []string
+}
+
+// IsEmpty reports whether the diff holds no differences.
+func (d ManifestDiff) IsEmpty() bool {
+	return len(d.Drifted) == 0 && len(d.Added) == 0 && len(d.Missing) == 0
+}
+
+// Compare returns the differences between the manifest and other.
+// To check that a charm expanded from an archive has not changed,
+// compare the manifest of the archive with ExpandedDigestManifest.
+func (m DigestManifest) Compare(other DigestManifest) ManifestDiff {
+	var diff ManifestDiff
+	for p, d := range m {
+		od, ok := other[p]
+		switch {
+		case !ok:
+			diff.Missing = append(diff.Missing, p)
+		case !d.Equal(od):
+			diff.Drifted = append(diff.Drifted, p)
+		}
+	}
+	for p := range other {
+		if _, ok := m[p]; !ok {
+			diff.Added = append(diff.Added, p)
+		}
+	}
+	sort.Strings(diff.Drifted)
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Missing)
+	return diff
+}
+
+// Digest returns a SHA-384 digest of the whole manifest, which
+// identifies the content of a charm as it is expanded.
+func (m DigestManifest) Digest() []byte {
+	paths := make([]string, 0, len(m))
+	for p := range m {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	h := sha512.New384()
+	for _, p := range paths {
+		d := m[p]
+		fmt.Fprintf(h, "%d:%s %d %d %x\n", len(p), p, uint32(d.Mode), d.Size, d.Fingerprint.Bytes())
+	}
+	return h.Sum(nil)
+}
+
+// DigestManifest returns the digest manifest of the files that
+// ArchiveTo would include in the charm file. To check a directory
+// that a charm archive was expanded into, use ExpandedDigestManifest.
+func (dir *CharmDir) DigestManifest() (DigestManifest, error) {
+	return digestManifest(dir)
+}
+
+// ExpandedDigestManifest returns the digest manifest of every file and
+// symlink in dir, with the modes they have on disk. Unlike the digest
+// manifest of a CharmDir, it applies no ignore rules and does not treat
+// hooks as executable, so comparing it with the manifest of the
+// archive that was expanded into dir shows any file that has been
+// added or changed since, hidden or not. Only an embedded signature
+// is left out, as it is not part of the charm.
+func ExpandedDigestManifest(dir string) (DigestManifest, error) {
+	return digestManifest(expandedFiles(dir))
+}
+
+// DigestManifest returns the digest manifest of the charm archive.
+func (a *CharmArchive) DigestManifest() (DigestManifest, error) {
+	return digestManifest(a)
+}
+
+// DigestManifest returns the digest manifest of the charm archive.
+func (a *CharmTarArchive) DigestManifest() (DigestManifest, error) {
+	return digestManifest(a)
+}
+
+func digestManifest(w charmFileWalker) (DigestManifest, error) {
+	m := make(DigestManifest)
+	if err := w.walkFiles(m.add); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// charmFileWalker is implemented by charms
+// whose files can be walked.
+type charmFileWalker interface {
+	// walkFiles calls f for each of the files and symlinks in the
+	// charm, as they are found once it is expanded, with the
+	// content of the file or the target of the symlink.
+	walkFiles(f func(name string, mode os.FileMode, r io.Reader) error) error
+}
+
+// charmFiles returns the file walker for the given charm.
+func charmFiles(ch Charm) (charmFileWalker, error) {
+	w, ok := ch.(charmFileWalker)
+	if !ok {
+		return nil, fmt.Errorf("cannot read files of charm of type %T", ch)
+	}
+	return w, nil
+}
+
+func (dir *CharmDir) walkFiles(f func(name string, mode os.FileMode, r io.Reader) error) error {
+	entries, err := dir.archiveEntries()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		mode := e.mode
+		if mode.IsDir() {
+			continue
+		}
+		if e.path == "" {
+			err = f(e.name, mode, bytes.NewReader(e.data))
+		} else {
+			err = walkFile(e.name, mode, e.path, f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandedFiles walks all the files in the directory
+// that it names, with their modes on disk.
+type expandedFiles string
+
+func (dir expandedFiles) walkFiles(f func(name string, mode os.FileMode, r io.Reader) error) error {
+	root, err := resolveSymlinkedRoot(string(dir))
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relpath, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(relpath)
+		mode := info.Mode()
+		if mode.IsDir() || name == SignatureFileName {
+			return nil
+		}
+		if err := checkFileType(name, mode); err != nil {
+			return err
+		}
+		if mode&os.ModeSymlink != 0 {
+			target, err := os.Readlink(filePath)
+			if err != nil {
+				return err
+			}
+			return f(name, mode, strings.NewReader(target))
+		}
+		return walkFile(name, mode, filePath, f)
+	})
+}
+
+// walkFile calls f with the content of the file at filePath.
+func walkFile(name string, mode os.FileMode, filePath string, f func(name string, mode os.FileMode, r io.Reader) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return f(name, mode, file)
+}
+
+func (a *CharmArchive) walkFiles(f func(name string, mode os.FileMode, r io.Reader) error) error {
+	zipr, err := a.zopen.openZip()
+	if err != nil {
+		return err
+	}
+	defer zipr.Close()
+	for _, zf := range zipr.File {
+		name, mode, ok, err := archivedFile(zf.Name, zf.Mode(), a.meta.Hooks())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = f(name, mode, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return f("revision", 0644, strings.NewReader(strconv.Itoa(a.revision)))
+}
+
+func (a *CharmTarArchive) walkFiles(f func(name string, mode os.FileMode, r io.Reader) error) error {
+	err := walkTar(a.topen, DefaultExpandLimits, func(h *tar.Header, r io.Reader) error {
+		name, mode, ok, err := archivedFile(h.Name, h.FileInfo().Mode(), a.meta.Hooks())
+		if err != nil || !ok {
+			return err
+		}
+		if h.Typeflag == tar.TypeSymlink {
+			r = strings.NewReader(h.Linkname)
+		}
+		return f(name, mode, r)
+	})
+	if err != nil {
+		return err
+	}
+	return f("revision", 0644, strings.NewReader(strconv.Itoa(a.revision)))
+}
+
+// archivedFile returns the cleaned name and the mode of the archive
+// entry with the given name and mode, as it would be expanded by
+// ExpandTo, and whether it is walked as a file of the charm.
+func archivedFile(name string, mode os.FileMode, hooks map[string]bool) (string, os.FileMode, bool, error) {
+	name = path.Clean(name)
+	// The revision file is always written by ExpandTo, and an
+	// embedded signature is not part of the charm.
+	if mode.IsDir() || name == "revision" || name == SignatureFileName {
+		return "", 0, false, nil
+	}
+	if err := checkFileType(name, mode); err != nil {
+		return "", 0, false, err
+	}
+	if path.Dir(name) == "hooks" && hooks[path.Base(name)] {
+		mode |= 0100
+	}
+	return name, mode, true, nil
+}
+
+// add adds the file with the given path, mode and content.
+func (m DigestManifest) add(name string, mode os.FileMode, r io.Reader) error {
+	h := resource.NewFingerprintHash()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return fmt.Errorf("cannot digest %q: %v", name, err)
+	}
+	m[name] = FileDigest{
+		Mode:        digestMode(mode),
+		Size:        size,
+		Fingerprint: h.Fingerprint(),
+	}
+	return nil
+}
+
+// digestMode returns the given mode normalised
+// as described in FileDigest.Mode.
+func digestMode(mode os.FileMode) os.FileMode {
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return os.ModeSymlink | 0777
+	case mode&0100 != 0:
+		return 0755
+	}
+	return 0644
+}
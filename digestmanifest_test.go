@@ -0,0 +1,175 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+	"gopkg.in/juju/charm.v6/resource"
+)
+
+type DigestManifestSuite struct{}
+
+var _ = gc.Suite(&DigestManifestSuite{})
+
+func (s *DigestManifestSuite) TestCharmDirDigestManifest(c *gc.C) {
+	dir := readCharmDir(c, "dummy")
+	m, err := dir.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+
+	var paths []string
+	for p := range m {
+		paths = append(paths, p)
+	}
+	c.Assert(paths, jc.SameContents, []string{
+		"actions.yaml",
+		"config.yaml",
+		"empty/.gitkeep",
+		"hooks/install",
+		"metadata.yaml",
+		"revision",
+		"src/hello.c",
+	})
+
+	data, err := ioutil.ReadFile(filepath.Join(dir.Path, "src", "hello.c"))
+	c.Assert(err, jc.ErrorIsNil)
+	fp, err := resource.GenerateFingerprint(bytes.NewReader(data))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(m["src/hello.c"], jc.DeepEquals, charm.FileDigest{
+		Mode:        0644,
+		Size:        int64(len(data)),
+		Fingerprint: fp,
+	})
+	c.Assert(m["hooks/install"].Mode, gc.Equals, os.FileMode(0755))
+	c.Assert(m["revision"].Size, gc.Equals, int64(1))
+}
+
+func (s *DigestManifestSuite) TestDigestManifestMatchesArchive(c *gc.C) {
+	dir := readCharmDir(c, "dummy")
+	expected, err := dir.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+
+	archive, err := charm.ReadCharmArchive(archivePath(c, dir))
+	c.Assert(err, jc.ErrorIsNil)
+	m, err := archive.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(expected.Compare(m).IsEmpty(), jc.IsTrue)
+	c.Assert(m, jc.DeepEquals, expected)
+
+	tarArchive, err := charm.ReadCharmTarArchive(tarArchivePath(c, dir))
+	c.Assert(err, jc.ErrorIsNil)
+	m, err = tarArchive.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(m, jc.DeepEquals, expected)
+
+	// The directory that the archive expands into matches too.
+	path := filepath.Join(c.MkDir(), "charm")
+	err = archive.ExpandTo(path)
+	c.Assert(err, jc.ErrorIsNil)
+	expanded, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+	m, err = expanded.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(m, jc.DeepEquals, expected)
+}
+
+func (s *DigestManifestSuite) TestDigestManifestHooksExecutable(c *gc.C) {
+	// The hooks in the archive are not executable, but
+	// they are once the archive has been expanded.
+	archive, err := charm.ReadCharmArchiveBytes(makeZip(c,
+		zipTestEntry{name: "hooks/install", mode: 0644, data: "#!/bin/sh\n"},
+		zipTestEntry{name: "hooks/helper", mode: 0644, data: "x"},
+	))
+	c.Assert(err, jc.ErrorIsNil)
+	m, err := archive.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(m["hooks/install"].Mode, gc.Equals, os.FileMode(0755))
+	c.Assert(m["hooks/helper"].Mode, gc.Equals, os.FileMode(0644))
+
+	path := filepath.Join(c.MkDir(), "charm")
+	err = archive.ExpandTo(path)
+	c.Assert(err, jc.ErrorIsNil)
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+	dm, err := dir.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(dm, jc.DeepEquals, m)
+}
+
+func (s *DigestManifestSuite) TestCompare(c *gc.C) {
+	archive, err := charm.ReadCharmArchive(archivePath(c, readCharmDir(c, "dummy")))
+	c.Assert(err, jc.ErrorIsNil)
+	expected, err := archive.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+
+	path := filepath.Join(c.MkDir(), "charm")
+	err = archive.ExpandTo(path)
+	c.Assert(err, jc.ErrorIsNil)
+	err = ioutil.WriteFile(filepath.Join(path, "src", "hello.c"), []byte("drifted"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	err = os.Chmod(filepath.Join(path, "config.yaml"), 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	err = ioutil.WriteFile(filepath.Join(path, "src", "extra.c"), nil, 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	err = os.Remove(filepath.Join(path, "empty", ".gitkeep"))
+	c.Assert(err, jc.ErrorIsNil)
+
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+	m, err := dir.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+	diff := expected.Compare(m)
+	c.Assert(diff, jc.DeepEquals, charm.ManifestDiff{
+		Drifted: []string{"config.yaml", "src/hello.c"},
+		Added:   []string{"src/extra.c"},
+		Missing: []string{"empty/.gitkeep"},
+	})
+	c.Assert(diff.IsEmpty(), jc.IsFalse)
+}
+
+func (s *DigestManifestSuite) TestExpandedDigestManifest(c *gc.C) {
+	archive, err := charm.ReadCharmArchive(archivePath(c, readCharmDir(c, "dummy")))
+	c.Assert(err, jc.ErrorIsNil)
+	expected, err := archive.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+
+	path := filepath.Join(c.MkDir(), "charm")
+	err = archive.ExpandTo(path)
+	c.Assert(err, jc.ErrorIsNil)
+	m, err := charm.ExpandedDigestManifest(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(m, jc.DeepEquals, expected)
+
+	// Files that ArchiveTo would leave out, and hooks
+	// that are no longer executable, are found.
+	err = ioutil.WriteFile(filepath.Join(path, ".evil"), []byte("x"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	err = os.Mkdir(filepath.Join(path, "build"), 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	err = ioutil.WriteFile(filepath.Join(path, "build", "evil"), []byte("x"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	err = os.Chmod(filepath.Join(path, "hooks", "install"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	m, err = charm.ExpandedDigestManifest(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(expected.Compare(m), jc.DeepEquals, charm.ManifestDiff{
+		Drifted: []string{"hooks/install"},
+		Added:   []string{".evil", "build/evil"},
+	})
+
+	// Whereas the charm directory would be archived as before.
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+	dm, err := dir.DigestManifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(expected.Compare(dm).IsEmpty(), jc.IsTrue)
+}
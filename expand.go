@@ -0,0 +1,425 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandOptions holds options for expanding charm and bundle
+// archives with ExpandToWithOptions.
+type ExpandOptions struct {
+	// Limits holds the limits on the content of the archive.
+	// If it is nil, DefaultExpandLimits is used.
+	Limits *ExpandLimits
+
+	// Atomic causes the archive to be expanded into a new
+	// directory next to the target, and checked, before the target
+	// is made a symlink to it with a single rename. The target is
+	// thus always either absent or complete, and if the expansion
+	// fails it is left as it was. If an existing target is a
+	// symlink made by an earlier atomic expansion, the directory it
+	// referred to is removed once it has been replaced. An existing
+	// target that is a plain directory is moved aside just before
+	// the symlink takes its place, and removed afterwards; it is
+	// moved back if the symlink cannot be put in place.
+	Atomic bool
+}
+
+// ExpandLimits holds limits on the content of an archive, which guard
+// against archives crafted to exhaust disk space or memory. A limit
+// of zero is not enforced.
+type ExpandLimits struct {
+	// MaxSize holds the maximum total uncompressed
+	// size of the files in the archive, in bytes.
+	MaxSize int64
+
+	// MaxFiles holds the maximum number of
+	// files and directories in the archive.
+	MaxFiles int
+
+	// MaxRatio holds the maximum ratio of the uncompressed size
+	// of a file in the archive to its compressed size. It only
+	// applies to files larger than a megabyte, as small files
+	// may legitimately compress very well.
+	MaxRatio float64
+}
+
+// DefaultExpandLimits holds the limits used when expanding an archive
+// without explicit limits. They are also checked, against the sizes
+// recorded in the archive, when a charm or bundle archive is read.
+var DefaultExpandLimits = ExpandLimits{
+	MaxSize:  2 << 30,
+	MaxFiles: 100000,
+	MaxRatio: 1000,
+}
+
+// ratioMinSize holds the size above which
+// ExpandLimits.MaxRatio is enforced.
+const ratioMinSize = 1 << 20
+
+// UnsafePathError is returned when an archive holds a file
+// whose path is absolute, leads out of the directory that
+// the archive is expanded into, or passes through a
+// symbolic link.
+type UnsafePathError struct {
+	Path   string
+	Reason string
+}
+
+func (e *UnsafePathError) Error() string {
+	return fmt.Sprintf("unsafe path %q in archive: %s", e.Path, e.Reason)
+}
+
+// UnsafeSymlinkError is returned when a charm or bundle holds a
+// symbolic link whose target is absolute or leads out of it.
+type UnsafeSymlinkError struct {
+	Path     string
+	Target   string
+	Absolute bool
+}
+
+func (e *UnsafeSymlinkError) Error() string {
+	if e.Absolute {
+		return fmt.Sprintf("symlink %q is absolute: %q", e.Path, e.Target)
+	}
+	return fmt.Sprintf("symlink %q links out of charm: %q", e.Path, e.Target)
+}
+
+// SizeLimitError is returned when the files in an archive
+// exceed ExpandLimits.MaxSize.
+type SizeLimitError struct {
+	MaxSize int64
+}
+
+func (e *SizeLimitError) Error() string {
+	return fmt.Sprintf("archive exceeds the maximum uncompressed size of %d bytes", e.MaxSize)
+}
+
+// FileLimitError is returned when an archive holds
+// more than ExpandLimits.MaxFiles files.
+type FileLimitError struct {
+	MaxFiles int
+}
+
+func (e *FileLimitError) Error() string {
+	return fmt.Sprintf("archive holds more than the maximum of %d files", e.MaxFiles)
+}
+
+// RatioLimitError is returned when a file in an archive
+// is compressed more than ExpandLimits.MaxRatio allows.
+type RatioLimitError struct {
+	Path     string
+	MaxRatio float64
+}
+
+func (e *RatioLimitError) Error() string {
+	return fmt.Sprintf("file %q in archive exceeds the maximum compression ratio of %g", e.Path, e.MaxRatio)
+}
+
+func (opts ExpandOptions) limits() ExpandLimits {
+	if opts.Limits == nil {
+		return DefaultExpandLimits
+	}
+	return *opts.Limits
+}
+
+// checkZipFiles checks the paths of the given files, and checks
+// their number and recorded sizes against the given limits. The zip
+// package refuses to read more data than the recorded size of a
+// file, so the recorded sizes can be relied on.
+func checkZipFiles(files []*zip.File, limits ExpandLimits) error {
+	if limits.MaxFiles > 0 && len(files) > limits.MaxFiles {
+		return &FileLimitError{limits.MaxFiles}
+	}
+	var total uint64
+	for _, f := range files {
+		if err := checkArchivePath(f.Name); err != nil {
+			return err
+		}
+		total += f.UncompressedSize64
+		if limits.MaxSize > 0 && total > uint64(limits.MaxSize) {
+			return &SizeLimitError{limits.MaxSize}
+		}
+		if limits.MaxRatio > 0 && f.UncompressedSize64 > ratioMinSize {
+			if f.CompressedSize64 == 0 || float64(f.UncompressedSize64)/float64(f.CompressedSize64) > limits.MaxRatio {
+				return &RatioLimitError{f.Name, limits.MaxRatio}
+			}
+		}
+	}
+	return nil
+}
+
+// checkArchivePath returns an error if the given
+// slash-separated path in an archive is unsafe.
+func checkArchivePath(name string) error {
+	if path.IsAbs(name) || filepath.IsAbs(name) {
+		return &UnsafePathError{name, "path is absolute"}
+	}
+	if p := path.Clean(name); p == ".." || strings.HasPrefix(p, "../") {
+		return &UnsafePathError{name, "path leads out of archive"}
+	}
+	return nil
+}
+
+// isExpandError reports whether err is one of the
+// errors that describes an unsafe archive.
+func isExpandError(err error) bool {
+	switch err.(type) {
+	case *UnsafePathError, *UnsafeSymlinkError, *SizeLimitError, *FileLimitError, *RatioLimitError:
+		return true
+	}
+	return false
+}
+
+// expandZip expands the files in the given zip archive into dir,
+// overwriting existing files and directories only where necessary.
+// It refuses to expand an unsafe archive.
+func expandZip(zipr *zip.Reader, dir string, limits ExpandLimits) error {
+	if err := checkZipFiles(zipr.File, limits); err != nil {
+		return err
+	}
+	var symlinks []string
+	for _, f := range zipr.File {
+		name := path.Clean(f.Name)
+		if err := expandEntry(dir, f.Name, f.Mode(), f.Open); err != nil {
+			if isExpandError(err) {
+				return err
+			}
+			return fmt.Errorf("cannot extract %q: %v", name, err)
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			symlinks = append(symlinks, name)
+		}
+	}
+	return checkExpandedSymlinks(dir, symlinks)
+}
+
+// checkExpandedSymlinks checks, once all the symlinks in an archive
+// expanded into dir exist, that none of those at the given cleaned
+// slash-separated paths leads out through another.
+func checkExpandedSymlinks(dir string, symlinks []string) error {
+	for _, name := range symlinks {
+		target, err := os.Readlink(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return fmt.Errorf("cannot extract %q: %v", name, err)
+		}
+		if err := checkSymlinkTraversal(dir, name, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandEntry expands the archive entry with the given name and mode
+// into dir. The content of the entry, or the target of a symlink, is
+// read from the reader returned by open.
+func expandEntry(dir, entryName string, mode os.FileMode, open func() (io.ReadCloser, error)) error {
+	name := path.Clean(entryName)
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	// Refuse to write through symlinks, which
+	// could lead anywhere.
+	for p := path.Dir(name); p != "." && p != "/"; p = path.Dir(p) {
+		info, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(p)))
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return &UnsafePathError{entryName, fmt.Sprintf("path passes through symlink %q", p)}
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+		return err
+	}
+	if err := checkFileType(name, mode); err != nil {
+		return err
+	}
+	switch mode & os.ModeType {
+	case os.ModeDir:
+		return expandDir(target, mode&os.ModePerm)
+	case os.ModeSymlink:
+		data, err := readEntry(open)
+		if err != nil {
+			return err
+		}
+		if err := checkSymlinkTarget(dir, name, string(data)); err != nil {
+			return err
+		}
+		if err := removeExisting(target); err != nil {
+			return err
+		}
+		return os.Symlink(string(data), target)
+	}
+	if err := removeExisting(target); err != nil {
+		return err
+	}
+	w, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode&os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	r, err := open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if _, err := io.Copy(w, r); err != nil {
+		// Do not leave a partial file behind, as when
+		// the content exceeds ExpandLimits.MaxRatio.
+		w.Close()
+		os.Remove(target)
+		return err
+	}
+	if err := w.Sync(); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func expandDir(target string, perm os.FileMode) error {
+	info, err := os.Lstat(target)
+	if err == nil && info.IsDir() {
+		if info.Mode()&os.ModePerm != perm {
+			return os.Chmod(target, perm)
+		}
+		return nil
+	}
+	if err := removeExisting(target); err != nil {
+		return err
+	}
+	return os.MkdirAll(target, perm)
+}
+
+// removeExisting removes anything at the given path.
+func removeExisting(path string) error {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return nil
+	}
+	return os.RemoveAll(path)
+}
+
+func readEntry(open func() (io.ReadCloser, error)) ([]byte, error) {
+	r, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// checkSymlinkTraversal returns an error if the target of the symlink
+// at the given slash-separated path within dir leaves, with "..", a
+// directory that it reached through another symlink. Such a target
+// might lead out of dir even though it appears not to.
+func checkSymlinkTraversal(dir, name, target string) error {
+	var parts []string
+	for _, part := range strings.Split(path.Dir(name)+"/"+target, "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if len(parts) == 0 {
+				return &UnsafeSymlinkError{Path: name, Target: target}
+			}
+			p := filepath.Join(dir, filepath.FromSlash(strings.Join(parts, "/")))
+			if info, err := os.Lstat(p); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				return &UnsafeSymlinkError{Path: name, Target: target}
+			}
+			parts = parts[:len(parts)-1]
+		default:
+			parts = append(parts, part)
+		}
+	}
+	return nil
+}
+
+// rename renames a file. It is a variable so that
+// tests can make it fail.
+var rename = os.Rename
+
+// expandAtomically calls expand to expand an archive into a new
+// directory next to dir, checks the result with verify, and then
+// atomically replaces dir with a symlink to it, as described for
+// ExpandOptions.Atomic.
+func expandAtomically(dir string, expand, verify func(dir string) error) (err error) {
+	dir = filepath.Clean(dir)
+	parent, base := filepath.Split(dir)
+	if parent == "" {
+		parent = "."
+	}
+	// Expanded directories are named after the target, so that
+	// those that the target refers to can be recognised.
+	prefix := "." + base + ".expand-"
+	isPlain := false
+	oldTarget, err := os.Readlink(dir)
+	switch {
+	case err == nil:
+		if filepath.Base(oldTarget) != oldTarget || !strings.HasPrefix(oldTarget, prefix) {
+			oldTarget = ""
+		}
+	case os.IsNotExist(err):
+		oldTarget = ""
+	default:
+		if _, lerr := os.Lstat(dir); lerr != nil {
+			return err
+		}
+		isPlain = true
+	}
+	if err := os.MkdirAll(parent, 0777); err != nil {
+		return err
+	}
+	newDir, err := ioutil.TempDir(parent, prefix)
+	if err != nil {
+		return err
+	}
+	link := newDir + ".link"
+	defer func() {
+		if err != nil {
+			os.Remove(link)
+			os.RemoveAll(newDir)
+		}
+	}()
+	// The directory is created with restrictive
+	// permissions; give it those of a new directory.
+	if err := os.Chmod(newDir, 0755); err != nil {
+		return err
+	}
+	if err := expand(newDir); err != nil {
+		return err
+	}
+	if err := verify(newDir); err != nil {
+		return fmt.Errorf("cannot verify expanded archive: %v", err)
+	}
+	if err := os.Symlink(filepath.Base(newDir), link); err != nil {
+		return err
+	}
+	if isPlain {
+		// A plain directory cannot be replaced by a rename, so
+		// move it aside first, and back again if the symlink
+		// cannot take its place. Later expansions can then
+		// replace the symlink atomically.
+		aside := newDir + ".old"
+		if err := rename(dir, aside); err != nil {
+			return err
+		}
+		if err := rename(link, dir); err != nil {
+			if rerr := rename(aside, dir); rerr != nil {
+				logger.Errorf("cannot restore %q from %q: %v", dir, aside, rerr)
+			}
+			return err
+		}
+		oldTarget = filepath.Base(aside)
+	} else if err := rename(link, dir); err != nil {
+		return err
+	}
+	if oldTarget != "" {
+		if err := os.RemoveAll(filepath.Join(parent, oldTarget)); err != nil {
+			logger.Warningf("cannot remove replaced directory %q: %v", oldTarget, err)
+		}
+	}
+	return nil
+}
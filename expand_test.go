@@ -0,0 +1,343 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type ExpandSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&ExpandSuite{})
+
+type zipTestEntry struct {
+	name string
+	mode os.FileMode
+	data string
+}
+
+// makeZip returns a zip archive holding the given entries, preceded
+// by the metadata of a minimal charm.
+func makeZip(c *gc.C, entries ...zipTestEntry) []byte {
+	entries = append([]zipTestEntry{{
+		name: "metadata.yaml",
+		mode: 0644,
+		data: "name: dummy\nsummary: s\ndescription: d\n",
+	}}, entries...)
+	var buf bytes.Buffer
+	zipw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{
+			Name:   e.name,
+			Method: zip.Deflate,
+		}
+		h.SetMode(e.mode)
+		w, err := zipw.CreateHeader(h)
+		c.Assert(err, jc.ErrorIsNil)
+		_, err = w.Write([]byte(e.data))
+		c.Assert(err, jc.ErrorIsNil)
+	}
+	err := zipw.Close()
+	c.Assert(err, jc.ErrorIsNil)
+	return buf.Bytes()
+}
+
+var noLimits = charm.ExpandOptions{
+	Limits: &charm.ExpandLimits{},
+}
+
+var expandTests = []struct {
+	about   string
+	entries []zipTestEntry
+	opts    charm.ExpandOptions
+	err     string
+	errType error
+}{{
+	about: "safe archive",
+	entries: []zipTestEntry{
+		{name: "hooks/", mode: os.ModeDir | 0755},
+		{name: "hooks/install", mode: 0755, data: "#!/bin/sh\n"},
+		{name: "hooks/start", mode: os.ModeSymlink | 0777, data: "install"},
+		{name: "link", mode: os.ModeSymlink | 0777, data: "hooks/../metadata.yaml"},
+	},
+}, {
+	about: "symlink out of the archive",
+	entries: []zipTestEntry{
+		{name: "hooks/install", mode: os.ModeSymlink | 0777, data: "../../target"},
+	},
+	err:     `symlink "hooks/install" links out of charm: "../../target"`,
+	errType: &charm.UnsafeSymlinkError{},
+}, {
+	about: "absolute symlink",
+	entries: []zipTestEntry{
+		{name: "hooks/install", mode: os.ModeSymlink | 0777, data: "/etc/passwd"},
+	},
+	err:     `symlink "hooks/install" is absolute: "/etc/passwd"`,
+	errType: &charm.UnsafeSymlinkError{},
+}, {
+	about: "symlink out of the archive through another symlink",
+	entries: []zipTestEntry{
+		{name: "escape", mode: os.ModeSymlink | 0777, data: "here/.."},
+		{name: "here", mode: os.ModeSymlink | 0777, data: "."},
+	},
+	err:     `symlink "escape" links out of charm: "here/.."`,
+	errType: &charm.UnsafeSymlinkError{},
+}, {
+	about: "file written through a symlink",
+	entries: []zipTestEntry{
+		{name: "link", mode: os.ModeSymlink | 0777, data: "hooks"},
+		{name: "link/install", mode: 0755, data: "#!/bin/sh\n"},
+	},
+	err:     `unsafe path "link/install" in archive: path passes through symlink "link"`,
+	errType: &charm.UnsafePathError{},
+}, {
+	about: "too many files",
+	entries: []zipTestEntry{
+		{name: "a", mode: 0644},
+		{name: "b", mode: 0644},
+	},
+	opts:    charm.ExpandOptions{Limits: &charm.ExpandLimits{MaxFiles: 2}},
+	err:     `archive holds more than the maximum of 2 files`,
+	errType: &charm.FileLimitError{},
+}, {
+	about: "too large",
+	entries: []zipTestEntry{
+		{name: "a", mode: 0644, data: "0123456789"},
+	},
+	opts:    charm.ExpandOptions{Limits: &charm.ExpandLimits{MaxSize: 40}},
+	err:     `archive exceeds the maximum uncompressed size of 40 bytes`,
+	errType: &charm.SizeLimitError{},
+}, {
+	about: "compressed too well",
+	entries: []zipTestEntry{
+		{name: "zeros", mode: 0644, data: strings.Repeat("\x00", 2<<20)},
+	},
+	opts:    charm.ExpandOptions{Limits: &charm.ExpandLimits{MaxRatio: 100}},
+	err:     `file "zeros" in archive exceeds the maximum compression ratio of 100`,
+	errType: &charm.RatioLimitError{},
+}, {
+	about: "small files may compress well",
+	entries: []zipTestEntry{
+		{name: "zeros", mode: 0644, data: strings.Repeat("\x00", 1<<20)},
+	},
+	opts: charm.ExpandOptions{Limits: &charm.ExpandLimits{MaxRatio: 100}},
+}}
+
+func (s *ExpandSuite) TestExpandToWithOptions(c *gc.C) {
+	for i, test := range expandTests {
+		c.Logf("test %d: %s", i, test.about)
+		archive, err := charm.ReadCharmArchiveBytes(makeZip(c, test.entries...))
+		c.Assert(err, jc.ErrorIsNil)
+		dir := filepath.Join(c.MkDir(), "charm")
+		opts := test.opts
+		if opts.Limits == nil {
+			opts = noLimits
+		}
+		err = archive.ExpandToWithOptions(dir, opts)
+		if test.err == "" {
+			c.Assert(err, jc.ErrorIsNil)
+			_, err = charm.ReadCharmDir(dir)
+			c.Assert(err, jc.ErrorIsNil)
+			continue
+		}
+		c.Assert(err, gc.ErrorMatches, test.err)
+		c.Assert(err, gc.FitsTypeOf, test.errType)
+	}
+}
+
+func (s *ExpandSuite) TestReadUnsafePaths(c *gc.C) {
+	for i, name := range []string{"../evil", "hooks/../../evil", "/evil"} {
+		c.Logf("test %d: %s", i, name)
+		_, err := charm.ReadCharmArchiveBytes(makeZip(c, zipTestEntry{name: name, mode: 0644}))
+		c.Assert(err, gc.ErrorMatches, `unsafe path ".*" in archive: path (is absolute|leads out of archive)`)
+		c.Assert(err, gc.FitsTypeOf, &charm.UnsafePathError{})
+	}
+}
+
+func (s *ExpandSuite) TestReadDefaultLimits(c *gc.C) {
+	s.PatchValue(&charm.DefaultExpandLimits, charm.ExpandLimits{MaxFiles: 1})
+	data := makeZip(c, zipTestEntry{name: "a", mode: 0644})
+	_, err := charm.ReadCharmArchiveBytes(data)
+	c.Assert(err, gc.FitsTypeOf, &charm.FileLimitError{})
+}
+
+func (s *ExpandSuite) TestBundleExpandToWithOptions(c *gc.C) {
+	archive, err := charm.ReadBundleArchive(archivePath(c, readBundleDir(c, "wordpress-simple")))
+	c.Assert(err, jc.ErrorIsNil)
+	err = archive.ExpandToWithOptions(c.MkDir(), charm.ExpandOptions{
+		Limits: &charm.ExpandLimits{MaxSize: 10},
+	})
+	c.Assert(err, gc.FitsTypeOf, &charm.SizeLimitError{})
+	err = archive.ExpandTo(c.MkDir())
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+// dirNames returns the names in the given directory.
+func dirNames(c *gc.C, dir string) []string {
+	f, err := os.Open(dir)
+	c.Assert(err, jc.ErrorIsNil)
+	defer f.Close()
+	names, err := f.Readdirnames(-1)
+	c.Assert(err, jc.ErrorIsNil)
+	return names
+}
+
+// checkAtomicDir checks that dir is a symlink to the only
+// other entry in its parent directory, and returns its target.
+func checkAtomicDir(c *gc.C, dir string) string {
+	target, err := os.Readlink(dir)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(dirNames(c, filepath.Dir(dir)), jc.SameContents, []string{filepath.Base(dir), target})
+	return target
+}
+
+func (s *ExpandSuite) TestExpandAtomic(c *gc.C) {
+	archive, err := charm.ReadCharmArchive(archivePath(c, readCharmDir(c, "dummy")))
+	c.Assert(err, jc.ErrorIsNil)
+	archive.SetRevision(42)
+	dir := filepath.Join(c.MkDir(), "charm")
+
+	err = archive.ExpandToWithOptions(dir, charm.ExpandOptions{Atomic: true})
+	c.Assert(err, jc.ErrorIsNil)
+	ch, err := charm.ReadCharmDir(dir)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ch.Revision(), gc.Equals, 42)
+	info, err := os.Stat(filepath.Join(dir, "hooks", "install"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.Mode()&0100, gc.Not(gc.Equals), os.FileMode(0))
+	checkAtomicDir(c, dir)
+
+	// The expanded charm can be archived again.
+	var buf bytes.Buffer
+	err = ch.ArchiveTo(&buf)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *ExpandSuite) TestExpandAtomicReplaces(c *gc.C) {
+	archive, err := charm.ReadCharmArchive(archivePath(c, readCharmDir(c, "dummy")))
+	c.Assert(err, jc.ErrorIsNil)
+	dir := filepath.Join(c.MkDir(), "charm")
+	err = archive.ExpandToWithOptions(dir, charm.ExpandOptions{Atomic: true})
+	c.Assert(err, jc.ErrorIsNil)
+	oldTarget := checkAtomicDir(c, dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "stale"), []byte("old"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The symlink is replaced and the directory
+	// it referred to is removed.
+	archive.SetRevision(2)
+	err = archive.ExpandToWithOptions(dir, charm.ExpandOptions{Atomic: true})
+	c.Assert(err, jc.ErrorIsNil)
+	ch, err := charm.ReadCharmDir(dir)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ch.Revision(), gc.Equals, 2)
+	_, err = os.Stat(filepath.Join(dir, "stale"))
+	c.Assert(err, jc.Satisfies, os.IsNotExist)
+	c.Assert(checkAtomicDir(c, dir), gc.Not(gc.Equals), oldTarget)
+}
+
+func (s *ExpandSuite) TestExpandAtomicReplacesDirectory(c *gc.C) {
+	archive, err := charm.ReadCharmArchive(archivePath(c, readCharmDir(c, "dummy")))
+	c.Assert(err, jc.ErrorIsNil)
+	parent := c.MkDir()
+	dir := filepath.Join(parent, "charm")
+	err = os.Mkdir(dir, 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	err = ioutil.WriteFile(filepath.Join(dir, "existing"), []byte("old"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The directory is replaced by a symlink to
+	// the expansion, and then removed.
+	err = archive.ExpandToWithOptions(dir, charm.ExpandOptions{Atomic: true})
+	c.Assert(err, jc.ErrorIsNil)
+	checkAtomicDir(c, dir)
+	_, err = charm.ReadCharmDir(dir)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = os.Stat(filepath.Join(dir, "existing"))
+	c.Assert(err, jc.Satisfies, os.IsNotExist)
+}
+
+func (s *ExpandSuite) TestExpandAtomicRestoresDirectory(c *gc.C) {
+	archive, err := charm.ReadCharmArchive(archivePath(c, readCharmDir(c, "dummy")))
+	c.Assert(err, jc.ErrorIsNil)
+	parent := c.MkDir()
+	dir := filepath.Join(parent, "charm")
+	err = os.Mkdir(dir, 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	err = ioutil.WriteFile(filepath.Join(dir, "existing"), []byte("old"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// If the symlink cannot take the place of the
+	// directory, the directory is put back.
+	s.PatchValue(charm.Rename, func(oldpath, newpath string) error {
+		if strings.HasSuffix(oldpath, ".link") {
+			return fmt.Errorf("cannot rename")
+		}
+		return os.Rename(oldpath, newpath)
+	})
+	err = archive.ExpandToWithOptions(dir, charm.ExpandOptions{Atomic: true})
+	c.Assert(err, gc.ErrorMatches, "cannot rename")
+	c.Assert(dirNames(c, parent), jc.DeepEquals, []string{"charm"})
+	c.Assert(dirNames(c, dir), jc.DeepEquals, []string{"existing"})
+}
+
+func (s *ExpandSuite) TestExpandAtomicFailure(c *gc.C) {
+	archive, err := charm.ReadCharmArchiveBytes(makeZip(c,
+		zipTestEntry{name: "hooks/install", mode: 0755, data: "#!/bin/sh\n"},
+		zipTestEntry{name: "hooks/start", mode: os.ModeSymlink | 0777, data: "../../target"},
+	))
+	c.Assert(err, jc.ErrorIsNil)
+	parent := c.MkDir()
+	dir := filepath.Join(parent, "charm")
+
+	// A failed expansion leaves nothing behind.
+	err = archive.ExpandToWithOptions(dir, charm.ExpandOptions{Atomic: true})
+	c.Assert(err, gc.FitsTypeOf, &charm.UnsafeSymlinkError{})
+	c.Assert(dirNames(c, parent), gc.HasLen, 0)
+
+	// Nor does it touch an earlier expansion.
+	good, err := charm.ReadCharmArchive(archivePath(c, readCharmDir(c, "dummy")))
+	c.Assert(err, jc.ErrorIsNil)
+	err = good.ExpandToWithOptions(dir, charm.ExpandOptions{Atomic: true})
+	c.Assert(err, jc.ErrorIsNil)
+	target := checkAtomicDir(c, dir)
+	err = archive.ExpandToWithOptions(dir, charm.ExpandOptions{Atomic: true})
+	c.Assert(err, gc.FitsTypeOf, &charm.UnsafeSymlinkError{})
+	c.Assert(checkAtomicDir(c, dir), gc.Equals, target)
+	_, err = charm.ReadCharmDir(dir)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Whereas a plain expansion leaves a partial charm.
+	err = archive.ExpandTo(filepath.Join(parent, "partial"))
+	c.Assert(err, gc.FitsTypeOf, &charm.UnsafeSymlinkError{})
+	c.Assert(dirNames(c, filepath.Join(parent, "partial")), jc.SameContents, []string{"metadata.yaml", "hooks"})
+}
+
+func (s *ExpandSuite) TestBundleExpandAtomic(c *gc.C) {
+	archive, err := charm.ReadBundleArchive(archivePath(c, readBundleDir(c, "wordpress-simple")))
+	c.Assert(err, jc.ErrorIsNil)
+	parent := c.MkDir()
+	dir := filepath.Join(parent, "bundle")
+	err = archive.ExpandToWithOptions(dir, charm.ExpandOptions{Atomic: true})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = charm.ReadBundleDir(dir)
+	c.Assert(err, jc.ErrorIsNil)
+	checkAtomicDir(c, dir)
+}
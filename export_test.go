@@ -14,6 +14,10 @@ var (
 	ExtraBindingsSchema       = extraBindingsSchema
 	ValidateMetaExtraBindings = validateMetaExtraBindings
 	ParseResourceMeta         = parseResourceMeta
+
+	KnownReleases = &releases
+	TimeNow       = &timeNow
+	Rename        = &rename
 )
 
 func MissingSeriesError() error {
@@ -25,7 +25,13 @@ type ExtraBinding struct {
 // Endpoint names are strings and must not match existing relation names from
 // the Provides, Requires, or Peers metadata sections. The values beside each
 // endpoint name must be left out (i.e. "foo": <anything> is invalid).
-var extraBindingsSchema = schema.Map(schema.NonEmptyString("binding name"), schema.Nil(""))
+var extraBindingsSchema = describedC{
+	schema.Map(schema.NonEmptyString("binding name"), schema.Nil("")),
+	map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": map[string]interface{}{"type": "null"},
+	},
+}
 
 func parseMetaExtraBindings(data interface{}) (map[string]ExtraBinding, error) {
 	if data == nil {
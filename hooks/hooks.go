@@ -108,3 +108,13 @@ func (kind Kind) IsStorage() bool {
 	}
 	return false
 }
+
+// IsUnit returns whether the Kind represents a unit hook.
+func (kind Kind) IsUnit() bool {
+	for _, k := range unitHooks {
+		if kind == k {
+			return true
+		}
+	}
+	return false
+}
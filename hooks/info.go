@@ -0,0 +1,94 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package hooks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Info identifies a hook by its kind and, for relation
+// and storage hooks, the name of the relation or store
+// that it is associated with.
+type Info struct {
+	Kind Kind
+
+	// RelationName holds the name of the relation
+	// for relation hooks. It is empty otherwise.
+	RelationName string
+
+	// StorageName holds the name of the store for
+	// storage hooks. It is empty otherwise.
+	StorageName string
+}
+
+// Name returns the name of the file that implements the hook,
+// for example "install", "db-relation-joined" or
+// "data-storage-attached".
+func (info Info) Name() string {
+	switch {
+	case info.Kind.IsRelation():
+		return info.RelationName + "-" + string(info.Kind)
+	case info.Kind.IsStorage():
+		return info.StorageName + "-" + string(info.Kind)
+	}
+	return string(info.Kind)
+}
+
+// String implements fmt.Stringer by returning the hook's name.
+func (info Info) String() string {
+	return info.Name()
+}
+
+// Validate checks that the info describes a valid hook.
+func (info Info) Validate() error {
+	switch {
+	case info.Kind.IsRelation():
+		if info.RelationName == "" {
+			return fmt.Errorf("%q hook requires a relation name", info.Kind)
+		}
+		if info.StorageName != "" {
+			return fmt.Errorf("%q hook cannot have a storage name", info.Kind)
+		}
+	case info.Kind.IsStorage():
+		if info.StorageName == "" {
+			return fmt.Errorf("%q hook requires a storage name", info.Kind)
+		}
+		if info.RelationName != "" {
+			return fmt.Errorf("%q hook cannot have a relation name", info.Kind)
+		}
+	case info.Kind.IsUnit():
+		if info.RelationName != "" || info.StorageName != "" {
+			return fmt.Errorf("%q hook cannot have a relation or storage name", info.Kind)
+		}
+	default:
+		return fmt.Errorf("unknown hook kind %q", info.Kind)
+	}
+	return nil
+}
+
+// ParseName parses the name of a hook file. Relation and storage
+// hooks are recognised by the kind at the end of their name, so the
+// name of the relation or store may itself contain hyphens.
+//
+// ParseName cannot tell whether the relation or store is declared
+// by any particular charm; charm.Meta.HookInfo also checks that.
+func ParseName(name string) (Info, error) {
+	for _, kind := range unitHooks {
+		if name == string(kind) {
+			return Info{Kind: kind}, nil
+		}
+	}
+	for _, kind := range relationHooks {
+		if prefix := strings.TrimSuffix(name, "-"+string(kind)); prefix != name && prefix != "" {
+			return Info{Kind: kind, RelationName: prefix}, nil
+		}
+	}
+	for _, kind := range storageHooks {
+		if prefix := strings.TrimSuffix(name, "-"+string(kind)); prefix != name && prefix != "" {
+			return Info{Kind: kind, StorageName: prefix}, nil
+		}
+	}
+	return Info{}, fmt.Errorf("unknown hook name %q", name)
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package hooks_test
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6/hooks"
+)
+
+type InfoSuite struct{}
+
+var _ = gc.Suite(&InfoSuite{})
+
+var parseNameTests = []struct {
+	name   string
+	expect hooks.Info
+	err    string
+}{{
+	name:   "install",
+	expect: hooks.Info{Kind: hooks.Install},
+}, {
+	name:   "leader-settings-changed",
+	expect: hooks.Info{Kind: hooks.LeaderSettingsChanged},
+}, {
+	name:   "db-relation-joined",
+	expect: hooks.Info{Kind: hooks.RelationJoined, RelationName: "db"},
+}, {
+	name:   "db-admin-relation-broken",
+	expect: hooks.Info{Kind: hooks.RelationBroken, RelationName: "db-admin"},
+}, {
+	name:   "my-relation-relation-changed",
+	expect: hooks.Info{Kind: hooks.RelationChanged, RelationName: "my-relation"},
+}, {
+	name:   "data-storage-attached",
+	expect: hooks.Info{Kind: hooks.StorageAttached, StorageName: "data"},
+}, {
+	name:   "shared-fs-storage-detaching",
+	expect: hooks.Info{Kind: hooks.StorageDetaching, StorageName: "shared-fs"},
+}, {
+	name: "relation-joined",
+	err:  `unknown hook name "relation-joined"`,
+}, {
+	name: "-storage-attached",
+	err:  `unknown hook name "-storage-attached"`,
+}, {
+	name: "action",
+	err:  `unknown hook name "action"`,
+}, {
+	name: "db-relation-exploded",
+	err:  `unknown hook name "db-relation-exploded"`,
+}}
+
+func (s *InfoSuite) TestParseName(c *gc.C) {
+	for i, test := range parseNameTests {
+		c.Logf("test %d: %s", i, test.name)
+		info, err := hooks.ParseName(test.name)
+		if test.err != "" {
+			c.Assert(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(info, jc.DeepEquals, test.expect)
+		c.Assert(info.Validate(), jc.ErrorIsNil)
+		c.Assert(info.Name(), gc.Equals, test.name)
+	}
+}
+
+var validateTests = []struct {
+	info hooks.Info
+	err  string
+}{{
+	info: hooks.Info{Kind: hooks.RelationJoined},
+	err:  `"relation-joined" hook requires a relation name`,
+}, {
+	info: hooks.Info{Kind: hooks.RelationJoined, RelationName: "db", StorageName: "data"},
+	err:  `"relation-joined" hook cannot have a storage name`,
+}, {
+	info: hooks.Info{Kind: hooks.StorageAttached},
+	err:  `"storage-attached" hook requires a storage name`,
+}, {
+	info: hooks.Info{Kind: hooks.StorageAttached, StorageName: "data", RelationName: "db"},
+	err:  `"storage-attached" hook cannot have a relation name`,
+}, {
+	info: hooks.Info{Kind: hooks.Install, RelationName: "db"},
+	err:  `"install" hook cannot have a relation or storage name`,
+}, {
+	info: hooks.Info{Kind: "explode"},
+	err:  `unknown hook kind "explode"`,
+}}
+
+func (s *InfoSuite) TestValidate(c *gc.C) {
+	for i, test := range validateTests {
+		c.Logf("test %d: %#v", i, test.info)
+		c.Assert(test.info.Validate(), gc.ErrorMatches, test.err)
+	}
+}
@@ -0,0 +1,411 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/juju/schema"
+
+	"gopkg.in/juju/charm.v6/resource"
+)
+
+// jsonSchemaDraft identifies the version of JSON Schema that the
+// generated documents conform to. It is the same draft that action
+// parameters are written in.
+const jsonSchemaDraft = "http://json-schema.org/draft-04/schema#"
+
+// The JSON Schema documents returned by the functions below are
+// generated from the same definitions that ReadMeta, ReadConfig,
+// ReadActionsYaml and ReadBundleData use, so that tools that are not
+// written in Go (editors, linters) can validate charm and bundle files
+// without reimplementing those rules.
+//
+// The documents describe values in their canonical form. The Go
+// readers are a little more lenient: for example an int may be written
+// as a numeric string.
+
+// MetaJSONSchema returns a JSON Schema document describing the
+// metadata.yaml files accepted by ReadMeta.
+func MetaJSONSchema() map[string]interface{} {
+	doc := checkerJSONSchema(charmSchema)
+	doc["$schema"] = jsonSchemaDraft
+	doc["title"] = "metadata.yaml"
+	return doc
+}
+
+// ConfigJSONSchema returns a JSON Schema document describing the
+// config.yaml files accepted by ReadConfig.
+func ConfigJSONSchema() map[string]interface{} {
+	types := make([]string, 0, len(optionTypeCheckers))
+	for t := range optionTypeCheckers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	// The type of an option's default depends on the option's
+	// type, which is "string" when it is not specified.
+	var variants []interface{}
+	for _, t := range types {
+		variant := map[string]interface{}{
+			"properties": map[string]interface{}{
+				"type": map[string]interface{}{
+					"enum": []string{t},
+				},
+				"default": map[string]interface{}{
+					"anyOf": []interface{}{
+						checkerJSONSchema(optionTypeCheckers[t]),
+						map[string]interface{}{"type": "null"},
+					},
+				},
+			},
+		}
+		if t != "string" {
+			variant["required"] = []string{"type"}
+		}
+		variants = append(variants, variant)
+	}
+	return map[string]interface{}{
+		"$schema":  jsonSchemaDraft,
+		"title":    "config.yaml",
+		"type":     "object",
+		"required": []string{"options"},
+		"properties": map[string]interface{}{
+			"options": map[string]interface{}{
+				"type": []string{"object", "null"},
+				"additionalProperties": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"type": map[string]interface{}{
+							"enum": types,
+						},
+						"description": map[string]interface{}{
+							"type": "string",
+						},
+						"default": map[string]interface{}{},
+					},
+					"anyOf": variants,
+				},
+			},
+		},
+	}
+}
+
+// ActionsJSONSchema returns a JSON Schema document describing the
+// actions.yaml files accepted by ReadActionsYaml.
+func ActionsJSONSchema() map[string]interface{} {
+	action := map[string]interface{}{
+		"type": []string{"object", "null"},
+		"properties": map[string]interface{}{
+			"description": map[string]interface{}{"type": "string"},
+			"title":       map[string]interface{}{"type": "string"},
+			"required":    map[string]interface{}{"type": "array"},
+			"params": map[string]interface{}{
+				// Each parameter is itself described by
+				// a JSON Schema.
+				"type": "object",
+				"additionalProperties": map[string]interface{}{
+					"type": "object",
+				},
+			},
+		},
+	}
+	// Names reserved by reservedName are rejected.
+	reserved := map[string]interface{}{"not": map[string]interface{}{}}
+	return map[string]interface{}{
+		"$schema": jsonSchemaDraft,
+		"title":   "actions.yaml",
+		"type":    "object",
+		"properties": map[string]interface{}{
+			"juju": reserved,
+		},
+		"patternProperties": map[string]interface{}{
+			actionNameRule.String(): action,
+			"^juju-":                reserved,
+		},
+		"additionalProperties": false,
+	}
+}
+
+// BundleJSONSchema returns a JSON Schema document describing the
+// bundle.yaml files accepted by ReadBundleData.
+func BundleJSONSchema() map[string]interface{} {
+	doc := goTypeJSONSchema(reflect.TypeOf(legacyBundleData{}))
+	// The legacy "services" field may be used in place of
+	// "applications", but not alongside it.
+	doc["not"] = map[string]interface{}{
+		"required": []string{"applications", "services"},
+		"properties": map[string]interface{}{
+			"applications": map[string]interface{}{"minProperties": 1},
+			"services":     map[string]interface{}{"minProperties": 1},
+		},
+	}
+	doc["$schema"] = jsonSchemaDraft
+	doc["title"] = "bundle.yaml"
+	return doc
+}
+
+// jsonSchemaer is implemented by schema checkers that can describe
+// the values they accept as a JSON Schema.
+type jsonSchemaer interface {
+	jsonSchema() map[string]interface{}
+}
+
+// checkerJSONSchema returns the JSON Schema describing the values
+// accepted by the given checker. Checkers that cannot describe
+// themselves are taken to accept any value.
+func checkerJSONSchema(c schema.Checker) map[string]interface{} {
+	if d, ok := c.(jsonSchemaer); ok {
+		return d.jsonSchema()
+	}
+	return map[string]interface{}{}
+}
+
+// describedC attaches a JSON Schema description to a checker
+// from the schema package, which cannot describe itself.
+type describedC struct {
+	schema.Checker
+	doc map[string]interface{}
+}
+
+func (c describedC) jsonSchema() map[string]interface{} {
+	return copyJSONValue(c.doc).(map[string]interface{})
+}
+
+// copyJSONValue returns a deep copy of v, so that documents
+// returned to callers do not share any maps with the definitions.
+func copyJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[key] = copyJSONValue(value)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			out[i] = copyJSONValue(value)
+		}
+		return out
+	case []string:
+		return append([]string(nil), v...)
+	}
+	return v
+}
+
+func stringSchema() schema.Checker {
+	return describedC{schema.String(), map[string]interface{}{"type": "string"}}
+}
+
+func intSchema() schema.Checker {
+	return describedC{schema.Int(), map[string]interface{}{"type": "integer"}}
+}
+
+func floatSchema() schema.Checker {
+	return describedC{schema.Float(), map[string]interface{}{"type": "number"}}
+}
+
+func boolSchema() schema.Checker {
+	return describedC{schema.Bool(), map[string]interface{}{"type": "boolean"}}
+}
+
+// enumSchema returns a checker that accepts only the given strings.
+func enumSchema(values ...string) schema.Checker {
+	options := make([]schema.Checker, len(values))
+	for i, value := range values {
+		options[i] = schema.Const(value)
+	}
+	return describedC{schema.OneOf(options...), map[string]interface{}{"enum": values}}
+}
+
+// listSchema is like schema.List, except that the returned
+// checker describes itself.
+func listSchema(elem schema.Checker) schema.Checker {
+	return listC{elem}
+}
+
+type listC struct {
+	elem schema.Checker
+}
+
+func (c listC) Coerce(v interface{}, path []string) (interface{}, error) {
+	return schema.List(c.elem).Coerce(v, path)
+}
+
+func (c listC) jsonSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":  "array",
+		"items": checkerJSONSchema(c.elem),
+	}
+}
+
+// fieldMapSchema is like schema.FieldMap, except that the returned
+// checker describes itself.
+func fieldMapSchema(fields schema.Fields, defaults schema.Defaults) schema.Checker {
+	return fieldMapC{fields, defaults}
+}
+
+type fieldMapC struct {
+	fields   schema.Fields
+	defaults schema.Defaults
+}
+
+func (c fieldMapC) Coerce(v interface{}, path []string) (interface{}, error) {
+	return schema.FieldMap(c.fields, c.defaults).Coerce(v, path)
+}
+
+func (c fieldMapC) jsonSchema() map[string]interface{} {
+	return fieldMapJSONSchema(c.fields, c.defaults)
+}
+
+func (c collectingFieldMapC) jsonSchema() map[string]interface{} {
+	return fieldMapJSONSchema(c.fields, c.defaults)
+}
+
+func (c collectingStringMapC) jsonSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": checkerJSONSchema(c.value),
+	}
+}
+
+// fieldMapJSONSchema returns the JSON Schema describing the maps
+// accepted by a field map checker. Fields without defaults are
+// required; keys that are not fields are ignored by the checker,
+// so they are allowed.
+func fieldMapJSONSchema(fields schema.Fields, defaults schema.Defaults) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	for name, checker := range fields {
+		property := checkerJSONSchema(checker)
+		if value, ok := defaults[name]; !ok {
+			required = append(required, name)
+		} else if value != schema.Omit && value != nil {
+			property["default"] = value
+		}
+		properties[name] = property
+	}
+	doc := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		doc["required"] = required
+	}
+	return doc
+}
+
+func (c ifaceExpC) jsonSchema() map[string]interface{} {
+	iface := checkerJSONSchema(ifaceSchema)
+	// The expander supplies the limit when it is missing.
+	var required []string
+	for _, name := range iface["required"].([]string) {
+		if name != "limit" {
+			required = append(required, name)
+		}
+	}
+	iface["required"] = required
+	if c.limit != nil {
+		properties := iface["properties"].(map[string]interface{})
+		properties["limit"].(map[string]interface{})["default"] = c.limit
+	}
+	return map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			iface,
+		},
+	}
+}
+
+func (c storageCountC) jsonSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "integer", "minimum": 1},
+			map[string]interface{}{"type": "string", "pattern": storageCountRE.String()},
+		},
+	}
+}
+
+func (c storageSizeC) jsonSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "string"}
+}
+
+func (c propertiesC) jsonSchema() map[string]interface{} {
+	return map[string]interface{}{"enum": []string{"transient"}}
+}
+
+// resourceTypes holds the names of the resource types
+// that may be declared in the metadata.
+var resourceTypes = []string{
+	resource.TypeFile.String(),
+	resource.TypeContainerImage.String(),
+}
+
+// goTypeJSONSchema returns the JSON Schema describing the YAML
+// that the yaml package decodes into a value of type t.
+func goTypeJSONSchema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return goTypeJSONSchema(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": goTypeJSONSchema(t.Elem()),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": goTypeJSONSchema(t.Elem()),
+		}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		addStructJSONSchema(properties, t)
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// addStructJSONSchema adds a property to properties for each field
+// of the struct type t, named as the yaml package names it.
+func addStructJSONSchema(properties map[string]interface{}, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		name, flags := tag[0], tag[1:]
+		if name == "-" {
+			continue
+		}
+		inline := false
+		for _, flag := range flags {
+			inline = inline || flag == "inline"
+		}
+		switch {
+		case inline:
+			addStructJSONSchema(properties, field.Type)
+			continue
+		case field.PkgPath != "":
+			// Unexported fields are not decoded.
+			continue
+		case name == "":
+			name = strings.ToLower(field.Name)
+		}
+		properties[name] = goTypeJSONSchema(field.Type)
+	}
+}
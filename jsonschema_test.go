@@ -0,0 +1,244 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	gjs "github.com/juju/gojsonschema"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+	"gopkg.in/yaml.v2"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type JSONSchemaSuite struct{}
+
+var _ = gc.Suite(&JSONSchemaSuite{})
+
+func propertyNames(doc map[string]interface{}) []string {
+	var names []string
+	for name := range doc["properties"].(map[string]interface{}) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func property(doc map[string]interface{}, name string) map[string]interface{} {
+	return doc["properties"].(map[string]interface{})[name].(map[string]interface{})
+}
+
+func (s *JSONSchemaSuite) TestMetaJSONSchema(c *gc.C) {
+	doc := charm.MetaJSONSchema()
+	c.Assert(doc["$schema"], gc.Equals, "http://json-schema.org/draft-04/schema#")
+	c.Assert(doc["type"], gc.Equals, "object")
+	c.Assert(doc["required"], jc.DeepEquals, []string{"description", "name", "summary"})
+	c.Assert(propertyNames(doc), jc.DeepEquals, []string{
+		"assumes", "bases", "categories", "containers", "deployment", "description",
+		"extra-bindings", "format", "min-juju-version", "name", "payloads",
+		"peers", "provides", "requires", "resources", "revision", "series",
+		"storage", "subordinate", "summary", "tags", "terms",
+	})
+	for _, name := range propertyNames(doc) {
+		c.Check(property(doc, name), gc.Not(gc.HasLen), 0, gc.Commentf("property %q", name))
+	}
+	c.Assert(property(doc, "series"), jc.DeepEquals, map[string]interface{}{
+		"type":  "array",
+		"items": map[string]interface{}{"type": "string"},
+	})
+
+	requires := property(doc, "requires")["additionalProperties"].(map[string]interface{})
+	c.Assert(requires, jc.DeepEquals, map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{
+				"type":     "object",
+				"required": []string{"interface"},
+				"properties": map[string]interface{}{
+					"interface": map[string]interface{}{"type": "string"},
+					"limit": map[string]interface{}{
+						"type":    []string{"integer", "null"},
+						"default": int64(1),
+					},
+					"scope": map[string]interface{}{
+						"enum":    []string{"global", "container"},
+						"default": "global",
+					},
+					"optional": map[string]interface{}{
+						"type":    "boolean",
+						"default": false,
+					},
+				},
+			},
+		},
+	})
+	provides := property(doc, "provides")["additionalProperties"].(map[string]interface{})
+	iface := provides["oneOf"].([]interface{})[1].(map[string]interface{})
+	c.Assert(property(iface, "limit"), jc.DeepEquals, map[string]interface{}{
+		"type": []string{"integer", "null"},
+	})
+
+	storage := property(doc, "storage")["additionalProperties"].(map[string]interface{})
+	c.Assert(storage["required"], jc.DeepEquals, []string{"type"})
+	c.Assert(property(storage, "type"), jc.DeepEquals, map[string]interface{}{
+		"enum": []string{"block", "filesystem"},
+	})
+	c.Assert(property(property(storage, "multiple"), "range")["oneOf"], gc.HasLen, 2)
+	c.Assert(property(storage, "properties"), jc.DeepEquals, map[string]interface{}{
+		"type":  "array",
+		"items": map[string]interface{}{"enum": []string{"transient"}},
+	})
+
+	resources := property(doc, "resources")["additionalProperties"].(map[string]interface{})
+	c.Assert(property(resources, "type"), jc.DeepEquals, map[string]interface{}{
+		"enum":    []string{"file", "oci-image"},
+		"default": "file",
+	})
+	c.Assert(property(doc, "extra-bindings"), jc.DeepEquals, map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": map[string]interface{}{"type": "null"},
+	})
+}
+
+func (s *JSONSchemaSuite) TestMetaJSONSchemaIsCopied(c *gc.C) {
+	doc := charm.MetaJSONSchema()
+	property(doc, "name")["type"] = "integer"
+	c.Assert(property(charm.MetaJSONSchema(), "name")["type"], gc.Equals, "string")
+}
+
+func (s *JSONSchemaSuite) TestConfigJSONSchema(c *gc.C) {
+	doc := charm.ConfigJSONSchema()
+	c.Assert(doc["required"], jc.DeepEquals, []string{"options"})
+	option := property(doc, "options")["additionalProperties"].(map[string]interface{})
+	c.Assert(property(option, "type"), jc.DeepEquals, map[string]interface{}{
+		"enum": []string{"boolean", "float", "int", "string"},
+	})
+	variants := option["anyOf"].([]interface{})
+	c.Assert(variants, gc.HasLen, 4)
+	c.Assert(variants[2], jc.DeepEquals, map[string]interface{}{
+		"required": []string{"type"},
+		"properties": map[string]interface{}{
+			"type": map[string]interface{}{"enum": []string{"int"}},
+			"default": map[string]interface{}{
+				"anyOf": []interface{}{
+					map[string]interface{}{"type": "integer"},
+					map[string]interface{}{"type": "null"},
+				},
+			},
+		},
+	})
+	// An option without a type is a string option.
+	c.Assert(variants[3].(map[string]interface{})["required"], gc.IsNil)
+}
+
+func (s *JSONSchemaSuite) TestActionsJSONSchema(c *gc.C) {
+	doc := charm.ActionsJSONSchema()
+	c.Assert(doc["additionalProperties"], gc.Equals, false)
+	patterns := doc["patternProperties"].(map[string]interface{})
+	c.Assert(patterns, gc.HasLen, 2)
+	c.Assert(patterns["^juju-"], jc.DeepEquals, map[string]interface{}{"not": map[string]interface{}{}})
+	action := patterns["^[a-z0-9](?:[a-z0-9-]*[a-z0-9])?$"].(map[string]interface{})
+	c.Assert(propertyNames(action), jc.DeepEquals, []string{"description", "params", "required", "title"})
+	c.Assert(property(doc, "juju"), jc.DeepEquals, map[string]interface{}{"not": map[string]interface{}{}})
+}
+
+func (s *JSONSchemaSuite) TestBundleJSONSchema(c *gc.C) {
+	doc := charm.BundleJSONSchema()
+	c.Assert(propertyNames(doc), jc.DeepEquals, []string{
+		"applications", "description", "machines", "relations", "series", "services", "tags",
+	})
+	c.Assert(property(doc, "relations"), jc.DeepEquals, map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"type": "string"},
+		},
+	})
+	application := property(doc, "applications")["additionalProperties"].(map[string]interface{})
+	c.Assert(propertyNames(application), jc.DeepEquals, []string{
+		"annotations", "bindings", "channel", "charm", "constraints", "expose", "num_units",
+		"options", "plan", "resources", "series", "storage", "to",
+	})
+	c.Assert(property(application, "num_units"), jc.DeepEquals, map[string]interface{}{"type": "integer"})
+	c.Assert(property(application, "options"), jc.DeepEquals, map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": map[string]interface{}{},
+	})
+	c.Assert(property(doc, "services"), jc.DeepEquals, property(doc, "applications"))
+	c.Assert(propertyNames(property(doc, "machines")["additionalProperties"].(map[string]interface{})), jc.DeepEquals, []string{
+		"annotations", "constraints", "series",
+	})
+}
+
+func (s *JSONSchemaSuite) TestJSONSchemasMarshal(c *gc.C) {
+	for i, doc := range []map[string]interface{}{
+		charm.MetaJSONSchema(),
+		charm.ConfigJSONSchema(),
+		charm.ActionsJSONSchema(),
+		charm.BundleJSONSchema(),
+	} {
+		c.Logf("test %d: %s", i, doc["title"])
+		_, err := json.Marshal(doc)
+		c.Assert(err, jc.ErrorIsNil)
+	}
+}
+
+var jsonSchemaFixtureTests = []struct {
+	path   string
+	schema map[string]interface{}
+}{
+	{"quantal/dummy/metadata.yaml", charm.MetaJSONSchema()},
+	{"quantal/dummy/config.yaml", charm.ConfigJSONSchema()},
+	{"quantal/dummy/actions.yaml", charm.ActionsJSONSchema()},
+	{"quantal/wordpress/metadata.yaml", charm.MetaJSONSchema()},
+	{"quantal/mysql/metadata.yaml", charm.MetaJSONSchema()},
+	{"quantal/all-hooks/metadata.yaml", charm.MetaJSONSchema()},
+	{"quantal/upgrade1/metadata.yaml", charm.MetaJSONSchema()},
+	{"quantal/upgrade1/config.yaml", charm.ConfigJSONSchema()},
+	{"bundle/wordpress-simple/bundle.yaml", charm.BundleJSONSchema()},
+	{"bundle/wordpress-legacy/bundle.yaml", charm.BundleJSONSchema()},
+	{"bundle/openstack/bundle.yaml", charm.BundleJSONSchema()},
+}
+
+func (s *JSONSchemaSuite) TestJSONSchemasAcceptFixtures(c *gc.C) {
+	for i, test := range jsonSchemaFixtureTests {
+		c.Logf("test %d: %s", i, test.path)
+		data, err := ioutil.ReadFile(filepath.Join("internal/test-charm-repo", test.path))
+		c.Assert(err, jc.ErrorIsNil)
+		var v interface{}
+		err = yaml.Unmarshal(data, &v)
+		c.Assert(err, jc.ErrorIsNil)
+		schema, err := gjs.NewSchema(gjs.NewGoLoader(test.schema))
+		c.Assert(err, jc.ErrorIsNil)
+		result, err := schema.Validate(gjs.NewGoLoader(jsonValue(v)))
+		c.Assert(err, jc.ErrorIsNil)
+		for _, verr := range result.Errors() {
+			c.Errorf("%s", verr.String())
+		}
+		c.Assert(result.Valid(), jc.IsTrue)
+	}
+}
+
+// jsonValue converts a value unmarshaled from YAML
+// into one that can be marshaled as JSON.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{})
+		for key, value := range v {
+			m[key.(string)] = jsonValue(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = jsonValue(value)
+		}
+	}
+	return v
+}
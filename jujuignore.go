@@ -0,0 +1,192 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFileName is the name of the file, in the root of a charm or
+// bundle directory, that holds patterns matching the files to leave
+// out of its archive. The patterns have the same syntax as those in
+// a .gitignore file:
+//
+//	# Comments and blank lines are ignored.
+//	venv/           a directory named venv, at any depth
+//	/tests          tests in the root directory only
+//	*.pyc           files with a .pyc suffix, at any depth
+//	data/**/*.bin   .bin files anywhere below data
+//	!keep.pyc       do not leave out keep.pyc after all
+//
+// As with git, a file cannot be included again with a negated
+// pattern if a directory that holds it has been left out.
+const ignoreFileName = ".jujuignore"
+
+// defaultIgnorePatterns holds the patterns that apply before those in
+// the ignore file: the hidden files and the build directory at the
+// root of the charm or bundle.
+var defaultIgnorePatterns = []string{
+	"/.*",
+	"/build/",
+}
+
+// ignoreRule holds a single pattern from an ignore file.
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negated bool
+	dirOnly bool
+}
+
+// ignoreRules holds the patterns that determine which files
+// are left out of an archive. A later pattern takes precedence
+// over an earlier one.
+type ignoreRules []ignoreRule
+
+// readIgnoreRules returns the default rules followed by the rules in
+// the ignore file in the given directory, if there is one.
+func readIgnoreRules(dir string) (ignoreRules, error) {
+	rules, err := parseIgnoreRules(strings.NewReader(strings.Join(defaultIgnorePatterns, "\n")))
+	if err != nil {
+		panic(err)
+	}
+	path := filepath.Join(dir, ignoreFileName)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return rules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fileRules, err := parseIgnoreRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %v", ignoreFileName, err)
+	}
+	return append(rules, fileRules...), nil
+}
+
+// parseIgnoreRules parses the patterns read from r.
+func parseIgnoreRules(r io.Reader) (ignoreRules, error) {
+	var rules ignoreRules
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		pattern := trimIgnorePattern(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		rule, err := parseIgnoreRule(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid pattern %q: %v", line, pattern, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// trimIgnorePattern removes trailing spaces from the
+// given pattern, unless they are escaped.
+func trimIgnorePattern(pattern string) string {
+	for strings.HasSuffix(pattern, " ") && !strings.HasSuffix(pattern, `\ `) {
+		pattern = pattern[:len(pattern)-1]
+	}
+	return strings.TrimRight(pattern, "\r")
+}
+
+func parseIgnoreRule(pattern string) (ignoreRule, error) {
+	var rule ignoreRule
+	if strings.HasPrefix(pattern, "!") {
+		rule.negated = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if pattern == "" {
+		return ignoreRule{}, fmt.Errorf("empty pattern")
+	}
+	// A pattern that holds a slash other than at its end
+	// is relative to the root; otherwise it matches at
+	// any depth.
+	prefix := "(?:.*/)?"
+	if strings.Contains(pattern, "/") {
+		prefix = ""
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+	expr, err := globToRegexp(pattern)
+	if err != nil {
+		return ignoreRule{}, err
+	}
+	rule.re, err = regexp.Compile("^" + prefix + expr + "$")
+	if err != nil {
+		return ignoreRule{}, err
+	}
+	return rule, nil
+}
+
+// globToRegexp returns a regular expression equivalent
+// to the given slash-separated glob pattern.
+func globToRegexp(pattern string) (string, error) {
+	var buf bytes.Buffer
+	for i := 0; i < len(pattern); i++ {
+		atStart := i == 0 || pattern[i-1] == '/'
+		switch c := pattern[i]; {
+		case atStart && strings.HasPrefix(pattern[i:], "**/"):
+			buf.WriteString("(?:.*/)?")
+			i += 2
+		case atStart && pattern[i:] == "**":
+			buf.WriteString(".*")
+			i++
+		case c == '*':
+			buf.WriteString("[^/]*")
+		case c == '?':
+			buf.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\':
+			if i+1 == len(pattern) {
+				return "", fmt.Errorf("trailing backslash")
+			}
+			i++
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	return buf.String(), nil
+}
+
+// ignored reports whether the file or directory at the given
+// slash-separated path, relative to the root, is left out.
+func (rules ignoreRules) ignored(path string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.re.MatchString(path) {
+			ignored = !rule.negated
+		}
+	}
+	return ignored
+}
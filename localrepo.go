@@ -0,0 +1,353 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// LocalRepository indexes the charms and bundles stored in a directory
+// tree with the classic local repository layout, in which each charm
+// is a directory or ".charm" archive held in a directory named after
+// a series, and each bundle is a directory held in a directory named
+// "bundle":
+//
+//	repo/quantal/mysql
+//	repo/quantal/wordpress.charm
+//	repo/bundle/wordpress-simple
+//
+// Charms are known by the name in their metadata and the revision in
+// their revision file, and bundles by the name of their directory.
+// Bundles have no revisions, so they are all given revision 0.
+type LocalRepository struct {
+	path string
+
+	// entries holds the charms and bundles found,
+	// keyed by series and then by name.
+	entries map[string]map[string][]*localEntry
+}
+
+type localEntry struct {
+	url    *URL
+	path   string
+	charm  Charm
+	bundle Bundle
+}
+
+// NewLocalRepository returns a repository that indexes the charms and
+// bundles found under the given directory. Charms and bundles that
+// cannot be read are logged and left out.
+func NewLocalRepository(path string) (*LocalRepository, error) {
+	seriesDirs, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, errors.Annotate(err, "cannot read local repository")
+	}
+	repo := &LocalRepository{
+		path:    path,
+		entries: make(map[string]map[string][]*localEntry),
+	}
+	for _, seriesDir := range seriesDirs {
+		series := seriesDir.Name()
+		if !seriesDir.IsDir() || !IsValidSeries(series) {
+			continue
+		}
+		infos, err := ioutil.ReadDir(filepath.Join(path, series))
+		if err != nil {
+			return nil, errors.Annotate(err, "cannot read local repository")
+		}
+		for _, info := range infos {
+			entryPath := filepath.Join(path, series, info.Name())
+			entry, err := readLocalEntry(series, entryPath, info)
+			if err != nil {
+				logger.Warningf("ignoring %q in local repository: %v", entryPath, err)
+				continue
+			}
+			if entry != nil {
+				repo.add(entry)
+			}
+		}
+	}
+	return repo, nil
+}
+
+// readLocalEntry reads the charm or bundle at the given path. It
+// returns nil if the path does not look like a charm or bundle.
+func readLocalEntry(series, path string, info os.FileInfo) (*localEntry, error) {
+	if strings.HasPrefix(info.Name(), ".") {
+		return nil, nil
+	}
+	entry := &localEntry{
+		path: path,
+		url: &URL{
+			Schema: "local",
+			Series: series,
+		},
+	}
+	switch {
+	case series == "bundle" && info.IsDir():
+		bundle, err := ReadBundleDir(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := ValidateName(info.Name()); err != nil {
+			return nil, err
+		}
+		entry.bundle = bundle
+		entry.url.Name = info.Name()
+		entry.url.Revision = 0
+	case series == "bundle":
+		return nil, nil
+	case info.IsDir():
+		ch, err := ReadCharmDir(path)
+		if err != nil {
+			return nil, err
+		}
+		entry.charm = ch
+	case strings.HasSuffix(info.Name(), ".charm"):
+		ch, err := ReadCharmArchive(path)
+		if err != nil {
+			return nil, err
+		}
+		entry.charm = ch
+	default:
+		return nil, nil
+	}
+	if entry.charm != nil {
+		entry.url.Name = entry.charm.Meta().Name
+		entry.url.Revision = entry.charm.Revision()
+	}
+	return entry, nil
+}
+
+func (repo *LocalRepository) add(entry *localEntry) {
+	byName := repo.entries[entry.url.Series]
+	if byName == nil {
+		byName = make(map[string][]*localEntry)
+		repo.entries[entry.url.Series] = byName
+	}
+	byName[entry.url.Name] = append(byName[entry.url.Name], entry)
+}
+
+// Path returns the directory holding the repository.
+func (repo *LocalRepository) Path() string {
+	return repo.path
+}
+
+// URLs returns the URLs of all the charms and bundles
+// in the repository, in string order.
+func (repo *LocalRepository) URLs() []*URL {
+	seen := make(map[string]bool)
+	var urls []*URL
+	for _, byName := range repo.entries {
+		for _, entries := range byName {
+			for _, entry := range entries {
+				if s := entry.url.String(); !seen[s] {
+					seen[s] = true
+					urls = append(urls, entry.url)
+				}
+			}
+		}
+	}
+	sort.Sort(urlsByString(urls))
+	return urls
+}
+
+type urlsByString []*URL
+
+func (urls urlsByString) Len() int           { return len(urls) }
+func (urls urlsByString) Swap(i, j int)      { urls[i], urls[j] = urls[j], urls[i] }
+func (urls urlsByString) Less(i, j int) bool { return urls[i].String() < urls[j].String() }
+
+// Collisions returns an error for each URL that
+// refers to more than one charm or bundle in the
+// repository, in URL order.
+func (repo *LocalRepository) Collisions() []*CollisionError {
+	var collisions []*CollisionError
+	for _, url := range repo.URLs() {
+		if err := repo.collision(url); err != nil {
+			collisions = append(collisions, err)
+		}
+	}
+	return collisions
+}
+
+func (repo *LocalRepository) collision(url *URL) *CollisionError {
+	var paths []string
+	for _, entry := range repo.entries[url.Series][url.Name] {
+		if entry.url.Revision == url.Revision {
+			paths = append(paths, entry.path)
+		}
+	}
+	if len(paths) < 2 {
+		return nil
+	}
+	return &CollisionError{URL: url, Paths: paths}
+}
+
+// CollisionError is returned when a URL refers to more
+// than one charm or bundle in a local repository.
+type CollisionError struct {
+	URL   *URL
+	Paths []string
+}
+
+func (e *CollisionError) Error() string {
+	return fmt.Sprintf("%s is ambiguous: found at %s", e.URL, strings.Join(e.Paths, ", "))
+}
+
+// Resolve returns the URL of the charm or bundle in the repository
+// that the given local URL refers to. The URL must have a series. If
+// it has no revision, the highest revision available is used.
+//
+// If the URL refers to more than one charm or bundle, Resolve returns
+// a *CollisionError. If there are charms or bundles with the URL's
+// name and series but none with its revision, it returns a
+// *ResolveError.
+func (repo *LocalRepository) Resolve(url *URL) (*URL, error) {
+	if !isLocalSchema(url.Schema) {
+		return nil, errors.Errorf("cannot resolve %q: not a local charm or bundle URL", url)
+	}
+	if url.Series == "" {
+		return nil, errors.Errorf("cannot resolve %q: series not specified", url)
+	}
+	entries := repo.entries[url.Series][url.Name]
+	if len(entries) == 0 {
+		return nil, errors.NotFoundf("charm or bundle %q", url)
+	}
+	ref := &Reference{
+		URL:       url.WithRevision(-1),
+		Revisions: AnyRevision,
+	}
+	if url.Revision >= 0 {
+		ref.Revisions = PinnedRevision(url.Revision)
+	}
+	available := make([]*URL, len(entries))
+	for i, entry := range entries {
+		available[i] = entry.url.WithRevision(entry.url.Revision)
+		available[i].Schema = url.Schema
+	}
+	resolved, err := ref.Resolve(available)
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.collision(resolved); err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+func (repo *LocalRepository) entry(url *URL) (*localEntry, *URL, error) {
+	resolved, err := repo.Resolve(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range repo.entries[resolved.Series][resolved.Name] {
+		if entry.url.Revision == resolved.Revision {
+			return entry, resolved, nil
+		}
+	}
+	return nil, nil, errors.NotFoundf("charm or bundle %q", resolved)
+}
+
+// Charm returns the charm that the given local URL refers
+// to, as resolved by Resolve, along with its full URL.
+func (repo *LocalRepository) Charm(url *URL) (Charm, *URL, error) {
+	entry, resolved, err := repo.entry(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry.charm == nil {
+		return nil, nil, errors.Errorf("%q is not a charm", resolved)
+	}
+	return entry.charm, resolved, nil
+}
+
+// Bundle returns the bundle that the given local URL refers
+// to, as resolved by Resolve, along with its full URL.
+func (repo *LocalRepository) Bundle(url *URL) (Bundle, *URL, error) {
+	entry, resolved, err := repo.entry(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry.bundle == nil {
+		return nil, nil, errors.Errorf("%q is not a bundle", resolved)
+	}
+	return entry.bundle, resolved, nil
+}
+
+// BundleCharms returns the charms required by the given bundle that
+// are in the repository, keyed by their charm URLs as written in the
+// bundle, for use with BundleData.VerifyWithCharms. Only local charm
+// URLs are looked up. A charm URL without a series is looked up in
+// the series of its application or, failing that, of the bundle or
+// defaultSeries.
+//
+// Charms that are not in the repository, that are referred to by
+// path, or that come from a charm store are left out.
+func (repo *LocalRepository) BundleCharms(bd *BundleData, defaultSeries string) (map[string]Charm, error) {
+	if bd.Series != "" {
+		defaultSeries = bd.Series
+	}
+	names := make([]string, 0, len(bd.Applications))
+	for name := range bd.Applications {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	charms := make(map[string]Charm)
+	for _, name := range names {
+		app := bd.Applications[name]
+		if app == nil || charms[app.Charm] != nil {
+			continue
+		}
+		if strings.HasPrefix(app.Charm, ".") || filepath.IsAbs(app.Charm) {
+			continue
+		}
+		curl, err := ParseURL(app.Charm)
+		if err != nil || !isLocalSchema(curl.Schema) {
+			continue
+		}
+		url := curl.WithRevision(curl.Revision)
+		if url.Series == "" {
+			url.Series = app.Series
+		}
+		if url.Series == "" {
+			url.Series = defaultSeries
+		}
+		if url.Series == "" {
+			continue
+		}
+		ch, _, err := repo.Charm(url)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Annotatef(err, "cannot get charm for application %q", name)
+		}
+		charms[app.Charm] = ch
+	}
+	return charms, nil
+}
+
+// VerifyBundle is a convenience method that calls
+// bd.VerifyWithCharms with the charms returned by
+// BundleCharms.
+func (repo *LocalRepository) VerifyBundle(
+	bd *BundleData,
+	defaultSeries string,
+	verifyConstraints func(c string) error,
+	verifyStorage func(s string) error,
+) error {
+	charms, err := repo.BundleCharms(bd, defaultSeries)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return bd.VerifyWithCharms(verifyConstraints, verifyStorage, charms)
+}
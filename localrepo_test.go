@@ -0,0 +1,217 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/utils/fs"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type LocalRepositorySuite struct {
+	testing.IsolationSuite
+	repo *charm.LocalRepository
+}
+
+var _ = gc.Suite(&LocalRepositorySuite{})
+
+const localRepoPath = "internal/test-charm-repo"
+
+func (s *LocalRepositorySuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	repo, err := charm.NewLocalRepository(localRepoPath)
+	c.Assert(err, jc.ErrorIsNil)
+	s.repo = repo
+}
+
+func (s *LocalRepositorySuite) TestNewLocalRepositoryNotFound(c *gc.C) {
+	_, err := charm.NewLocalRepository(filepath.Join(c.MkDir(), "missing"))
+	c.Assert(err, gc.ErrorMatches, `cannot read local repository: .*`)
+}
+
+func (s *LocalRepositorySuite) TestURLs(c *gc.C) {
+	urls := make(map[string]bool)
+	for _, url := range s.repo.URLs() {
+		urls[url.String()] = true
+	}
+	for _, url := range []string{
+		"local:quantal/categories-0",
+		"local:quantal/upgrade-1",
+		"local:quantal/upgrade-2",
+		"local:quantal/wordpress-3",
+		"local:bundle/wordpress-simple-0",
+	} {
+		c.Check(urls[url], jc.IsTrue, gc.Commentf("url %s", url))
+	}
+	// The bad charm has no metadata, so it is left out.
+	c.Assert(urls["local:quantal/bad-1"], jc.IsFalse)
+}
+
+var localRepositoryResolveTests = []struct {
+	url      string
+	expected string
+	err      string
+}{{
+	url:      "local:quantal/upgrade",
+	expected: "local:quantal/upgrade-2",
+}, {
+	url:      "local:quantal/upgrade-1",
+	expected: "local:quantal/upgrade-1",
+}, {
+	url:      "local:quantal/categories",
+	expected: "local:quantal/categories-0",
+}, {
+	url:      "local:bundle/wordpress-simple",
+	expected: "local:bundle/wordpress-simple-0",
+}, {
+	url: "local:quantal/upgrade-3",
+	err: `cannot resolve "local:quantal/upgrade-3": .*revision 2 does not satisfy 3.*`,
+}, {
+	url: "local:quantal/missing",
+	err: `charm or bundle "local:quantal/missing" not found`,
+}, {
+	url: "local:trusty/upgrade",
+	err: `charm or bundle "local:trusty/upgrade" not found`,
+}, {
+	url: "local:upgrade",
+	err: `cannot resolve "local:upgrade": series not specified`,
+}, {
+	url: "cs:quantal/upgrade",
+	err: `cannot resolve "cs:quantal/upgrade": not a local charm or bundle URL`,
+}}
+
+func (s *LocalRepositorySuite) TestResolve(c *gc.C) {
+	for i, test := range localRepositoryResolveTests {
+		c.Logf("test %d: %s", i, test.url)
+		url, err := s.repo.Resolve(charm.MustParseURL(test.url))
+		if test.err != "" {
+			c.Assert(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(url.String(), gc.Equals, test.expected)
+	}
+}
+
+func (s *LocalRepositorySuite) TestResolveNotFound(c *gc.C) {
+	_, err := s.repo.Resolve(charm.MustParseURL("local:quantal/missing"))
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *LocalRepositorySuite) TestCharm(c *gc.C) {
+	ch, url, err := s.repo.Charm(charm.MustParseURL("local:quantal/upgrade"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(url.String(), gc.Equals, "local:quantal/upgrade-2")
+	c.Assert(ch.Meta().Name, gc.Equals, "upgrade")
+	c.Assert(ch.Revision(), gc.Equals, 2)
+
+	_, _, err = s.repo.Charm(charm.MustParseURL("local:bundle/wordpress-simple"))
+	c.Assert(err, gc.ErrorMatches, `"local:bundle/wordpress-simple-0" is not a charm`)
+}
+
+func (s *LocalRepositorySuite) TestBundle(c *gc.C) {
+	b, url, err := s.repo.Bundle(charm.MustParseURL("local:bundle/wordpress-simple"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(url.String(), gc.Equals, "local:bundle/wordpress-simple-0")
+	c.Assert(b.Data().Applications, gc.HasLen, 2)
+
+	_, _, err = s.repo.Bundle(charm.MustParseURL("local:quantal/wordpress"))
+	c.Assert(err, gc.ErrorMatches, `"local:quantal/wordpress-3" is not a bundle`)
+}
+
+func (s *LocalRepositorySuite) TestArchives(c *gc.C) {
+	path := c.MkDir()
+	err := os.Mkdir(filepath.Join(path, "quantal"), 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	dir := readCharmDir(c, "riak")
+	f, err := os.Create(filepath.Join(path, "quantal", "riak.charm"))
+	c.Assert(err, jc.ErrorIsNil)
+	defer f.Close()
+	err = dir.ArchiveTo(f)
+	c.Assert(err, jc.ErrorIsNil)
+
+	repo, err := charm.NewLocalRepository(path)
+	c.Assert(err, jc.ErrorIsNil)
+	ch, url, err := repo.Charm(charm.MustParseURL("local:quantal/riak"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(url.String(), gc.Equals, "local:quantal/riak-7")
+	c.Assert(ch, gc.FitsTypeOf, &charm.CharmArchive{})
+}
+
+func (s *LocalRepositorySuite) TestCollisions(c *gc.C) {
+	path := cloneDir(c, localRepoPath)
+	err := fs.Copy(
+		filepath.Join(path, "quantal", "upgrade2"),
+		filepath.Join(path, "quantal", "upgrade3"),
+	)
+	c.Assert(err, jc.ErrorIsNil)
+	repo, err := charm.NewLocalRepository(path)
+	c.Assert(err, jc.ErrorIsNil)
+
+	collisions := repo.Collisions()
+	c.Assert(collisions, gc.HasLen, 1)
+	c.Assert(collisions[0].URL.String(), gc.Equals, "local:quantal/upgrade-2")
+	c.Assert(collisions[0].Paths, jc.SameContents, []string{
+		filepath.Join(path, "quantal", "upgrade2"),
+		filepath.Join(path, "quantal", "upgrade3"),
+	})
+
+	_, err = repo.Resolve(charm.MustParseURL("local:quantal/upgrade"))
+	c.Assert(err, gc.FitsTypeOf, &charm.CollisionError{})
+	c.Assert(err, gc.ErrorMatches, `local:quantal/upgrade-2 is ambiguous: found at .*upgrade2, .*upgrade3`)
+
+	// Other revisions can still be resolved.
+	url, err := repo.Resolve(charm.MustParseURL("local:quantal/upgrade-1"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(url.String(), gc.Equals, "local:quantal/upgrade-1")
+}
+
+// readLocalBundleData returns the data of the named test bundle
+// with its charm URLs changed to local ones.
+func readLocalBundleData(c *gc.C, name string) *charm.BundleData {
+	bd := readBundleDir(c, name).Data()
+	for _, app := range bd.Applications {
+		app.Charm = "local:" + app.Charm
+	}
+	return bd
+}
+
+func (s *LocalRepositorySuite) TestBundleCharms(c *gc.C) {
+	bd := readLocalBundleData(c, "wordpress-simple")
+	charms, err := s.repo.BundleCharms(bd, "quantal")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(charms, gc.HasLen, 2)
+	c.Assert(charms["local:wordpress"].Meta().Name, gc.Equals, "wordpress")
+	c.Assert(charms["local:mysql"].Meta().Name, gc.Equals, "mysql")
+
+	// Without a series the charms cannot be found.
+	charms, err = s.repo.BundleCharms(bd, "")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(charms, gc.HasLen, 0)
+}
+
+func (s *LocalRepositorySuite) TestBundleCharmsIgnoresStoreCharms(c *gc.C) {
+	bd := readBundleDir(c, "wordpress-simple").Data()
+	bd.Applications["mysql"].Charm = "cs:quantal/mysql"
+	charms, err := s.repo.BundleCharms(bd, "quantal")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(charms, gc.HasLen, 0)
+}
+
+func (s *LocalRepositorySuite) TestVerifyBundle(c *gc.C) {
+	bd := readLocalBundleData(c, "wordpress-simple")
+	err := s.repo.VerifyBundle(bd, "quantal", nil, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	bd.Applications["mysql"].Charm = "local:quantal/missing"
+	err = s.repo.VerifyBundle(bd, "quantal", nil, nil)
+	c.Assert(err, gc.ErrorMatches, `.*application "mysql" refers to non-existent charm "local:quantal/missing".*`)
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"io/ioutil"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -18,6 +19,7 @@ import (
 	"gopkg.in/juju/names.v2"
 	"gopkg.in/yaml.v2"
 
+	"gopkg.in/juju/charm.v6/assumes"
 	"gopkg.in/juju/charm.v6/hooks"
 	"gopkg.in/juju/charm.v6/resource"
 )
@@ -194,41 +196,87 @@ type Meta struct {
 	Categories     []string                 `bson:"categories,omitempty" json:"Categories,omitempty"`
 	Tags           []string                 `bson:"tags,omitempty" json:"Tags,omitempty"`
 	Series         []string                 `bson:"series,omitempty" json:"SupportedSeries,omitempty"`
+	Bases          []Base                   `bson:"bases,omitempty" json:"Bases,omitempty"`
 	Storage        map[string]Storage       `bson:"storage,omitempty" json:"Storage,omitempty"`
 	PayloadClasses map[string]PayloadClass  `bson:"payloadclasses,omitempty" json:"PayloadClasses,omitempty"`
 	Resources      map[string]resource.Meta `bson:"resources,omitempty" json:"Resources,omitempty"`
 	Terms          []string                 `bson:"terms,omitempty" json:"Terms,omitempty"`
 	MinJujuVersion version.Number           `bson:"min-juju-version,omitempty" json:"min-juju-version,omitempty"`
+	Containers     map[string]Container     `bson:"containers,omitempty" json:"Containers,omitempty"`
+	Deployment     *Deployment              `bson:"deployment,omitempty" json:"Deployment,omitempty"`
+	Assumes        *assumes.ExpressionTree  `bson:"assumes,omitempty" json:"Assumes,omitempty"`
 }
 
-func generateRelationHooks(relName string, allHooks map[string]bool) {
-	for _, hookName := range hooks.RelationHooks() {
-		allHooks[fmt.Sprintf("%s-%s", relName, hookName)] = true
+func generateRelationHooks(relName string, allHooks map[string]hooks.Kind) {
+	for _, kind := range hooks.RelationHooks() {
+		allHooks[fmt.Sprintf("%s-%s", relName, kind)] = kind
+	}
+}
+
+func generateStorageHooks(storageName string, allHooks map[string]hooks.Kind) {
+	for _, kind := range hooks.StorageHooks() {
+		allHooks[fmt.Sprintf("%s-%s", storageName, kind)] = kind
 	}
 }
 
 // Hooks returns a map of all possible valid hooks, taking relations
-// into account. It's a map to enable fast lookups, and the value is
-// always true.
+// and storage into account. It's a map to enable fast lookups, and
+// the value is always true.
 func (m Meta) Hooks() map[string]bool {
 	allHooks := make(map[string]bool)
+	for hookName := range m.HookKinds() {
+		allHooks[hookName] = true
+	}
+	return allHooks
+}
+
+// HookKinds returns a map from the name of each possible valid hook,
+// taking relations and storage into account, to its kind. Relation
+// and storage hook names are prefixed with the name of the relation
+// or store, so the kind tells them apart from each other and from
+// the unit hooks.
+func (m Meta) HookKinds() map[string]hooks.Kind {
+	allHooks := make(map[string]hooks.Kind)
 	// Unit hooks
-	for _, hookName := range hooks.UnitHooks() {
-		allHooks[string(hookName)] = true
+	for _, kind := range hooks.UnitHooks() {
+		allHooks[string(kind)] = kind
 	}
 	// Relation hooks
-	for hookName := range m.Provides {
-		generateRelationHooks(hookName, allHooks)
+	for relName := range m.Provides {
+		generateRelationHooks(relName, allHooks)
+	}
+	for relName := range m.Requires {
+		generateRelationHooks(relName, allHooks)
 	}
-	for hookName := range m.Requires {
-		generateRelationHooks(hookName, allHooks)
+	for relName := range m.Peers {
+		generateRelationHooks(relName, allHooks)
 	}
-	for hookName := range m.Peers {
-		generateRelationHooks(hookName, allHooks)
+	// Storage hooks
+	for storageName := range m.Storage {
+		generateStorageHooks(storageName, allHooks)
 	}
 	return allHooks
 }
 
+// HookInfo returns the details of the named hook. The names of
+// relations and stores are taken from the metadata, so an error
+// is returned if the hook is not one of those returned by HookKinds.
+func (m Meta) HookInfo(name string) (hooks.Info, error) {
+	kind, ok := m.HookKinds()[name]
+	if !ok {
+		return hooks.Info{}, fmt.Errorf("charm %q has no hook %q", m.Name, name)
+	}
+	info := hooks.Info{Kind: kind}
+	prefix := strings.TrimSuffix(name, "-"+string(kind))
+	switch {
+	case kind.IsRelation():
+		info.RelationName = prefix
+	case kind.IsStorage():
+		info.StorageName = prefix
+	}
+	return info, nil
+}
+
 // Used for parsing Categories and Tags.
 func parseStringList(list interface{}) []string {
 	if list == nil {
@@ -371,7 +419,9 @@ func MustParseTerm(s string) *TermsId {
 }
 
 // ReadMeta reads the content of a metadata.yaml file and returns
-// its representation.
+// its representation. Invalid metadata is reported with a
+// *MetaValidationError that holds the position of each problem;
+// YAML that cannot be parsed is reported with a *ParseError.
 func ReadMeta(r io.Reader) (*Meta, error) {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -380,7 +430,14 @@ func ReadMeta(r io.Reader) (*Meta, error) {
 	var meta Meta
 	err = yaml.Unmarshal(data, &meta)
 	if err != nil {
-		return nil, err
+		src := newYAMLSource(data)
+		if verr, ok := err.(*MetaValidationError); ok {
+			for _, e := range verr.Errors {
+				e.Position = src.pathPosition(e.Path)
+			}
+			return nil, verr
+		}
+		return nil, src.yamlError(err)
 	}
 	return &meta, nil
 }
@@ -391,18 +448,33 @@ func (meta *Meta) UnmarshalYAML(f func(interface{}) error) error {
 	if err != nil {
 		return err
 	}
+	// Carry on past schema problems so that we report
+	// as many of the charm's problems as we can at once.
+	var errs metaErrors
 	v, err := charmSchema.Coerce(raw, nil)
-	if err != nil {
+	if verr, ok := err.(*MetaValidationError); ok {
+		for _, e := range verr.Errors {
+			if e.Path == "" || strings.Contains(e.Err.Error(), e.Path) {
+				e.Err = errors.Annotate(e.Err, "metadata")
+			} else {
+				e.Err = errors.Annotatef(e.Err, "metadata: %s", e.Path)
+			}
+		}
+		errs.add("", verr)
+	} else if err != nil {
 		return errors.New("metadata: " + err.Error())
 	}
 
 	m := v.(map[string]interface{})
 	meta1, err := parseMeta(m)
 	if err != nil {
-		return err
+		errs.add("", err)
 	}
 
 	if err := meta1.Check(); err != nil {
+		errs.add("", err)
+	}
+	if err := errs.err(); err != nil {
 		return err
 	}
 
@@ -410,47 +482,74 @@ func (meta *Meta) UnmarshalYAML(f func(interface{}) error) error {
 	return nil
 }
 
+// parseMeta parses the metadata held in m, which must have been
+// coerced by charmSchema. Any problems found are returned as
+// a *MetaValidationError alongside the metadata that could be
+// parsed.
 func parseMeta(m map[string]interface{}) (*Meta, error) {
 	var meta Meta
-	var err error
-
-	meta.Name = m["name"].(string)
-	// Schema decodes as int64, but the int range should be good
-	// enough for revisions.
-	meta.Summary = m["summary"].(string)
-	meta.Description = m["description"].(string)
+	var errs metaErrors
+
+	// The name, summary and description are always present unless
+	// they failed to coerce, in which case the error has already
+	// been reported.
+	meta.Name, _ = m["name"].(string)
+	meta.Summary, _ = m["summary"].(string)
+	meta.Description, _ = m["description"].(string)
 	meta.Provides = parseRelations(m["provides"], RoleProvider)
 	meta.Requires = parseRelations(m["requires"], RoleRequirer)
 	meta.Peers = parseRelations(m["peers"], RolePeer)
-	meta.ExtraBindings, err = parseMetaExtraBindings(m["extra-bindings"])
+	extraBindings, err := parseMetaExtraBindings(m["extra-bindings"])
 	if err != nil {
-		return nil, err
+		errs.add("extra-bindings", err)
 	}
+	meta.ExtraBindings = extraBindings
 	meta.Categories = parseStringList(m["categories"])
 	meta.Tags = parseStringList(m["tags"])
 	if subordinate := m["subordinate"]; subordinate != nil {
 		meta.Subordinate = subordinate.(bool)
 	}
 	meta.Series = parseStringList(m["series"])
+	bases, err := parseBases(m["bases"])
+	if err != nil {
+		errs.add("bases", err)
+	}
+	meta.Bases = bases
 	meta.Storage = parseStorage(m["storage"])
 	meta.PayloadClasses = parsePayloadClasses(m["payloads"])
 
 	if ver := m["min-juju-version"]; ver != nil {
 		minver, err := version.Parse(ver.(string))
 		if err != nil {
-			return &meta, errors.Annotate(err, "invalid min-juju-version")
+			errs.add("min-juju-version", errors.Annotate(err, "invalid min-juju-version"))
+		} else {
+			meta.MinJujuVersion = minver
 		}
-		meta.MinJujuVersion = minver
 	}
 	meta.Terms = parseStringList(m["terms"])
 
 	resources, err := parseMetaResources(m["resources"])
 	if err != nil {
-		return nil, err
+		errs.add("resources", err)
 	}
 	meta.Resources = resources
+	meta.Containers = parseContainers(m["containers"])
+	meta.Deployment = parseDeployment(m["deployment"])
 
-	return &meta, nil
+	if data := m["assumes"]; data != nil {
+		tree, err := assumes.Parse(data)
+		if err != nil {
+			path := "assumes"
+			if perr, ok := err.(*assumes.ParseError); ok {
+				path += perr.Path
+			}
+			errs.add(path, err)
+		} else {
+			meta.Assumes = tree
+		}
+	}
+
+	return &meta, errs.err()
 }
 
 // MarshalYAML implements yaml.Marshaler (yaml.v2).
@@ -459,6 +558,11 @@ func (m Meta) MarshalYAML() (interface{}, error) {
 	if m.MinJujuVersion != version.Zero {
 		minver = m.MinJujuVersion.String()
 	}
+	var deployment *marshaledDeployment
+	if m.Deployment != nil {
+		d := marshaledDeployment(*m.Deployment)
+		deployment = &d
+	}
 
 	return struct {
 		Name           string                           `yaml:"name"`
@@ -472,9 +576,15 @@ func (m Meta) MarshalYAML() (interface{}, error) {
 		Tags           []string                         `yaml:"tags,omitempty"`
 		Subordinate    bool                             `yaml:"subordinate,omitempty"`
 		Series         []string                         `yaml:"series,omitempty"`
+		Bases          []marshaledBase                  `yaml:"bases,omitempty"`
 		Terms          []string                         `yaml:"terms,omitempty"`
+		Storage        map[string]marshaledStorage      `yaml:"storage,omitempty"`
+		PayloadClasses map[string]marshaledPayloadClass `yaml:"payloads,omitempty"`
 		MinJujuVersion string                           `yaml:"min-juju-version,omitempty"`
 		Resources      map[string]marshaledResourceMeta `yaml:"resources,omitempty"`
+		Containers     map[string]marshaledContainer    `yaml:"containers,omitempty"`
+		Deployment     *marshaledDeployment             `yaml:"deployment,omitempty"`
+		Assumes        *assumes.ExpressionTree          `yaml:"assumes,omitempty"`
 	}{
 		Name:           m.Name,
 		Summary:        m.Summary,
@@ -487,14 +597,20 @@ func (m Meta) MarshalYAML() (interface{}, error) {
 		Tags:           m.Tags,
 		Subordinate:    m.Subordinate,
 		Series:         m.Series,
+		Bases:          marshaledBases(m.Bases),
 		Terms:          m.Terms,
+		Storage:        marshaledStorages(m.Storage),
+		PayloadClasses: marshaledPayloadClasses(m.PayloadClasses),
 		MinJujuVersion: minver,
 		Resources:      marshaledResources(m.Resources),
+		Containers:     marshaledContainers(m.Containers),
+		Deployment:     deployment,
+		Assumes:        m.Assumes,
 	}, nil
 }
 
 type marshaledResourceMeta struct {
-	Path        string `yaml:"filename"` // TODO(ericsnow) Change to "path"?
+	Path        string `yaml:"filename,omitempty"` // TODO(ericsnow) Change to "path"?
 	Type        string `yaml:"type,omitempty"`
 	Description string `yaml:"description,omitempty"`
 }
@@ -514,6 +630,64 @@ func marshaledResources(rs map[string]resource.Meta) map[string]marshaledResourc
 	return rs1
 }
 
+type marshaledStorage struct {
+	Type        string                 `yaml:"type"`
+	Description string                 `yaml:"description,omitempty"`
+	Shared      bool                   `yaml:"shared,omitempty"`
+	ReadOnly    bool                   `yaml:"read-only,omitempty"`
+	Multiple    *marshaledStorageCount `yaml:"multiple,omitempty"`
+	MinimumSize string                 `yaml:"minimum-size,omitempty"`
+	Location    string                 `yaml:"location,omitempty"`
+	Properties  []string               `yaml:"properties,omitempty"`
+}
+
+type marshaledStorageCount struct {
+	Range interface{} `yaml:"range"`
+}
+
+func marshaledStorages(stores map[string]Storage) map[string]marshaledStorage {
+	marshaled := make(map[string]marshaledStorage, len(stores))
+	for name, store := range stores {
+		s := marshaledStorage{
+			Type:        string(store.Type),
+			Description: store.Description,
+			Shared:      store.Shared,
+			ReadOnly:    store.ReadOnly,
+			Location:    store.Location,
+			Properties:  store.Properties,
+		}
+		// See storageCountC for the accepted range forms.
+		// Singleton stores are the default, so leave them out.
+		switch {
+		case store.CountMin == 1 && store.CountMax == 1:
+		case store.CountMin == store.CountMax:
+			s.Multiple = &marshaledStorageCount{store.CountMin}
+		case store.CountMax == -1:
+			s.Multiple = &marshaledStorageCount{fmt.Sprintf("%d+", store.CountMin)}
+		default:
+			s.Multiple = &marshaledStorageCount{fmt.Sprintf("%d-%d", store.CountMin, store.CountMax)}
+		}
+		if store.MinimumSize > 0 {
+			// MinimumSize is held in MiB; see storageSizeC.
+			s.MinimumSize = fmt.Sprintf("%dM", store.MinimumSize)
+		}
+		marshaled[name] = s
+	}
+	return marshaled
+}
+
+type marshaledPayloadClass struct {
+	Type string `yaml:"type"`
+}
+
+func marshaledPayloadClasses(classes map[string]PayloadClass) map[string]marshaledPayloadClass {
+	marshaled := make(map[string]marshaledPayloadClass, len(classes))
+	for name, class := range classes {
+		marshaled[name] = marshaledPayloadClass{class.Type}
+	}
+	return marshaled
+}
+
 func marshaledRelations(relations map[string]Relation) map[string]marshaledRelation {
 	marshaled := make(map[string]marshaledRelation)
 	for name, relation := range relations {
@@ -561,55 +735,82 @@ func marshaledExtraBindings(bindings map[string]ExtraBinding) map[string]interfa
 	return marshaled
 }
 
+// CheckWithWarnings is like Check, but also returns a warning
+// for each declared series that is not in the release table or
+// that has reached end of life. Such series are not treated as
+// errors, as the charm may support releases made after this
+// package was built.
+func (meta Meta) CheckWithWarnings() ([]ReadWarning, error) {
+	return meta.seriesWarnings(), meta.Check()
+}
+
+// seriesWarnings returns the warnings about the declared
+// series described by CheckWithWarnings.
+func (meta Meta) seriesWarnings() []ReadWarning {
+	series := make(map[string]string)
+	for i, s := range meta.Series {
+		series[fmt.Sprintf("series[%d]", i)] = s
+	}
+	return seriesWarnings(series)
+}
+
 // Check checks that the metadata is well-formed.
+// If it is not, Check returns a *MetaValidationError
+// describing all the problems found.
 func (meta Meta) Check() error {
+	var errs metaErrors
+
 	// Check for duplicate or forbidden relation names or interfaces.
 	names := map[string]bool{}
-	checkRelations := func(src map[string]Relation, role RelationRole) error {
-		for name, rel := range src {
+	checkRelations := func(src map[string]Relation, role RelationRole, section string) {
+		relNames := make([]string, 0, len(src))
+		for name := range src {
+			relNames = append(relNames, name)
+		}
+		sort.Strings(relNames)
+		for _, name := range relNames {
+			rel := src[name]
+			path := section + "." + name
 			if rel.Name != name {
-				return fmt.Errorf("charm %q has mismatched relation name %q; expected %q", meta.Name, rel.Name, name)
+				errs.addf(path, "charm %q has mismatched relation name %q; expected %q", meta.Name, rel.Name, name)
+				continue
 			}
 			if rel.Role != role {
-				return fmt.Errorf("charm %q has mismatched role %q; expected %q", meta.Name, rel.Role, role)
+				errs.addf(path, "charm %q has mismatched role %q; expected %q", meta.Name, rel.Role, role)
+				continue
 			}
 			// Container-scoped require relations on subordinates are allowed
 			// to use the otherwise-reserved juju-* namespace.
 			if !meta.Subordinate || role != RoleRequirer || rel.Scope != ScopeContainer {
 				if reserved, _ := reservedName(name); reserved {
-					return fmt.Errorf("charm %q using a reserved relation name: %q", meta.Name, name)
+					errs.addf(path, "charm %q using a reserved relation name: %q", meta.Name, name)
 				}
 			}
 			if role != RoleRequirer {
 				if reserved, _ := reservedName(rel.Interface); reserved {
-					return fmt.Errorf("charm %q relation %q using a reserved interface: %q", meta.Name, name, rel.Interface)
+					errs.addf(path+".interface", "charm %q relation %q using a reserved interface: %q", meta.Name, name, rel.Interface)
 				}
 			}
 			if names[name] {
-				return fmt.Errorf("charm %q using a duplicated relation name: %q", meta.Name, name)
+				errs.addf(path, "charm %q using a duplicated relation name: %q", meta.Name, name)
 			}
 			names[name] = true
 		}
-		return nil
-	}
-	if err := checkRelations(meta.Provides, RoleProvider); err != nil {
-		return err
-	}
-	if err := checkRelations(meta.Requires, RoleRequirer); err != nil {
-		return err
-	}
-	if err := checkRelations(meta.Peers, RolePeer); err != nil {
-		return err
 	}
+	checkRelations(meta.Provides, RoleProvider, "provides")
+	checkRelations(meta.Requires, RoleRequirer, "requires")
+	checkRelations(meta.Peers, RolePeer, "peers")
+	relationsOK := len(errs) == 0
 
 	if err := validateMetaExtraBindings(meta); err != nil {
-		return fmt.Errorf("charm %q has invalid extra bindings: %v", meta.Name, err)
+		errs.addf("extra-bindings", "charm %q has invalid extra bindings: %v", meta.Name, err)
 	}
 
 	// Subordinate charms must have at least one relation that
 	// has container scope, otherwise they can't relate to the
-	// principal.
-	if meta.Subordinate {
+	// principal. There's no point in checking this if the
+	// relations themselves are broken.
+	if meta.Subordinate && relationsOK {
 		valid := false
 		if meta.Requires != nil {
 			for _, relationData := range meta.Requires {
@@ -620,56 +821,78 @@ func (meta Meta) Check() error {
 			}
 		}
 		if !valid {
-			return fmt.Errorf("subordinate charm %q lacks \"requires\" relation with container scope", meta.Name)
+			errs.addf("subordinate", "subordinate charm %q lacks \"requires\" relation with container scope", meta.Name)
 		}
 	}
 
-	for _, series := range meta.Series {
+	for i, series := range meta.Series {
 		if !IsValidSeries(series) {
-			return fmt.Errorf("charm %q declares invalid series: %q", meta.Name, series)
+			errs.addf(fmt.Sprintf("series[%d]", i), "charm %q declares invalid series: %q", meta.Name, series)
+		}
+	}
+
+	for i, base := range meta.Bases {
+		if err := base.Validate(); err != nil {
+			errs.addf(fmt.Sprintf("bases[%d]", i), "charm %q declares invalid base: %v", meta.Name, err)
 		}
 	}
 
-	names = make(map[string]bool)
-	for name, store := range meta.Storage {
+	storeNames := make([]string, 0, len(meta.Storage))
+	for name := range meta.Storage {
+		storeNames = append(storeNames, name)
+	}
+	sort.Strings(storeNames)
+	for _, name := range storeNames {
+		store := meta.Storage[name]
+		path := "storage." + name
 		if store.Location != "" && store.Type != StorageFilesystem {
-			return fmt.Errorf(`charm %q storage %q: location may not be specified for "type: %s"`, meta.Name, name, store.Type)
+			errs.addf(path+".location", `charm %q storage %q: location may not be specified for "type: %s"`, meta.Name, name, store.Type)
 		}
 		if store.Type == "" {
-			return fmt.Errorf("charm %q storage %q: type must be specified", meta.Name, name)
+			errs.addf(path+".type", "charm %q storage %q: type must be specified", meta.Name, name)
 		}
 		if store.CountMin < 0 {
-			return fmt.Errorf("charm %q storage %q: invalid minimum count %d", meta.Name, name, store.CountMin)
+			errs.addf(path+".multiple.range", "charm %q storage %q: invalid minimum count %d", meta.Name, name, store.CountMin)
 		}
 		if store.CountMax == 0 || store.CountMax < -1 {
-			return fmt.Errorf("charm %q storage %q: invalid maximum count %d", meta.Name, name, store.CountMax)
-		}
-		if names[name] {
-			return fmt.Errorf("charm %q storage %q: duplicated storage name", meta.Name, name)
+			errs.addf(path+".multiple.range", "charm %q storage %q: invalid maximum count %d", meta.Name, name, store.CountMax)
 		}
-		names[name] = true
 	}
 
-	for name, payloadClass := range meta.PayloadClasses {
+	classNames := make([]string, 0, len(meta.PayloadClasses))
+	for name := range meta.PayloadClasses {
+		classNames = append(classNames, name)
+	}
+	sort.Strings(classNames)
+	for _, name := range classNames {
+		payloadClass := meta.PayloadClasses[name]
 		if payloadClass.Name != name {
-			return fmt.Errorf("mismatch on payload class name (%q != %q)", payloadClass.Name, name)
+			errs.addf("payloads."+name, "mismatch on payload class name (%q != %q)", payloadClass.Name, name)
+			continue
 		}
 		if err := payloadClass.Validate(); err != nil {
-			return err
+			errs.add("payloads."+name, err)
 		}
 	}
 
 	if err := validateMetaResources(meta.Resources); err != nil {
-		return err
+		errs.add("resources", err)
 	}
 
-	for _, term := range meta.Terms {
+	if err := validateMetaContainers(meta); err != nil {
+		errs.add("containers", err)
+	}
+	if err := validateMetaDeployment(meta); err != nil {
+		errs.add("deployment", err)
+	}
+
+	for i, term := range meta.Terms {
 		if _, terr := ParseTerm(term); terr != nil {
-			return errors.Trace(terr)
+			errs.add(fmt.Sprintf("terms[%d]", i), errors.Trace(terr))
 		}
 	}
 
-	return nil
+	return errs.err()
 }
 
 func reservedName(name string) (reserved bool, reason string) {
@@ -780,12 +1003,15 @@ func (c ifaceExpC) Coerce(v interface{}, path []string) (newv interface{}, err e
 	return ifaceSchema.Coerce(m, path)
 }
 
-var ifaceSchema = schema.FieldMap(
+var ifaceSchema = fieldMapSchema(
 	schema.Fields{
-		"interface": schema.String(),
-		"limit":     schema.OneOf(schema.Const(nil), schema.Int()),
-		"scope":     schema.OneOf(schema.Const(string(ScopeGlobal)), schema.Const(string(ScopeContainer))),
-		"optional":  schema.Bool(),
+		"interface": stringSchema(),
+		"limit": describedC{
+			schema.OneOf(schema.Const(nil), schema.Int()),
+			map[string]interface{}{"type": []string{"integer", "null"}},
+		},
+		"scope":    enumSchema(string(ScopeGlobal), string(ScopeContainer)),
+		"optional": boolSchema(),
 	},
 	schema.Defaults{
 		"scope":    string(ScopeGlobal),
@@ -832,21 +1058,21 @@ func parseStorage(stores interface{}) map[string]Storage {
 	return result
 }
 
-var storageSchema = schema.FieldMap(
+var storageSchema = collectingFieldMap(
 	schema.Fields{
-		"type":      schema.OneOf(schema.Const(string(StorageBlock)), schema.Const(string(StorageFilesystem))),
-		"shared":    schema.Bool(),
-		"read-only": schema.Bool(),
-		"multiple": schema.FieldMap(
+		"type":      enumSchema(string(StorageBlock), string(StorageFilesystem)),
+		"shared":    boolSchema(),
+		"read-only": boolSchema(),
+		"multiple": collectingFieldMap(
 			schema.Fields{
 				"range": storageCountC{}, // m, m-n, m+, m-
 			},
 			schema.Defaults{},
 		),
 		"minimum-size": storageSizeC{},
-		"location":     schema.String(),
-		"description":  schema.String(),
-		"properties":   schema.List(propertiesC{}),
+		"location":     stringSchema(),
+		"description":  stringSchema(),
+		"properties":   listSchema(propertiesC{}),
 	},
 	schema.Defaults{
 		"shared":       false,
@@ -913,26 +1139,30 @@ func (c propertiesC) Coerce(v interface{}, path []string) (newv interface{}, err
 	return schema.OneOf(schema.Const("transient")).Coerce(v, path)
 }
 
-var charmSchema = schema.FieldMap(
+var charmSchema = collectingFieldMap(
 	schema.Fields{
-		"name":             schema.String(),
-		"summary":          schema.String(),
-		"description":      schema.String(),
-		"peers":            schema.StringMap(ifaceExpander(int64(1))),
-		"provides":         schema.StringMap(ifaceExpander(nil)),
-		"requires":         schema.StringMap(ifaceExpander(int64(1))),
+		"name":             stringSchema(),
+		"summary":          stringSchema(),
+		"description":      stringSchema(),
+		"peers":            collectingStringMap(ifaceExpander(int64(1))),
+		"provides":         collectingStringMap(ifaceExpander(nil)),
+		"requires":         collectingStringMap(ifaceExpander(int64(1))),
 		"extra-bindings":   extraBindingsSchema,
-		"revision":         schema.Int(), // Obsolete
-		"format":           schema.Int(), // Obsolete
-		"subordinate":      schema.Bool(),
-		"categories":       schema.List(schema.String()),
-		"tags":             schema.List(schema.String()),
-		"series":           schema.List(schema.String()),
-		"storage":          schema.StringMap(storageSchema),
-		"payloads":         schema.StringMap(payloadClassSchema),
-		"resources":        schema.StringMap(resourceSchema),
-		"terms":            schema.List(schema.String()),
-		"min-juju-version": schema.String(),
+		"revision":         intSchema(), // Obsolete
+		"format":           intSchema(), // Obsolete
+		"subordinate":      boolSchema(),
+		"categories":       listSchema(stringSchema()),
+		"tags":             listSchema(stringSchema()),
+		"series":           listSchema(stringSchema()),
+		"bases":            listSchema(baseSchema),
+		"storage":          collectingStringMap(storageSchema),
+		"payloads":         collectingStringMap(payloadClassSchema),
+		"resources":        collectingStringMap(resourceSchema),
+		"terms":            listSchema(stringSchema()),
+		"min-juju-version": stringSchema(),
+		"containers":       collectingStringMap(containerSchema),
+		"deployment":       deploymentSchema,
+		"assumes":          listSchema(schema.Any()),
 	},
 	schema.Defaults{
 		"provides":         schema.Omit,
@@ -945,10 +1175,14 @@ var charmSchema = schema.FieldMap(
 		"categories":       schema.Omit,
 		"tags":             schema.Omit,
 		"series":           schema.Omit,
+		"bases":            schema.Omit,
 		"storage":          schema.Omit,
 		"payloads":         schema.Omit,
 		"resources":        schema.Omit,
 		"terms":            schema.Omit,
 		"min-juju-version": schema.Omit,
+		"containers":       schema.Omit,
+		"deployment":       schema.Omit,
+		"assumes":          schema.Omit,
 	},
 )
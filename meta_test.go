@@ -19,6 +19,8 @@ import (
 	yamlv2 "gopkg.in/yaml.v2"
 
 	"gopkg.in/juju/charm.v6"
+	"gopkg.in/juju/charm.v6/assumes"
+	"gopkg.in/juju/charm.v6/hooks"
 	"gopkg.in/juju/charm.v6/resource"
 )
 
@@ -126,15 +128,15 @@ func (s *MetaSuite) TestCheckTerms(c *gc.C) {
 	}, {
 		about:       "revision not a number",
 		terms:       []string{"term/1", "term/a"},
-		expectError: `wrong term name format "a"`,
+		expectError: `terms\[1\]: wrong term name format "a"`,
 	}, {
 		about:       "negative revision",
 		terms:       []string{"term/-1"},
-		expectError: "negative term revision",
+		expectError: `terms\[0\]: negative term revision`,
 	}, {
 		about:       "wrong format",
 		terms:       []string{"term/1", "foobar/term/abc/1"},
-		expectError: `unknown term id format "foobar/term/abc/1"`,
+		expectError: `terms\[1\]: unknown term id format "foobar/term/abc/1"`,
 	}, {
 		about: "term with owner",
 		terms: []string{"term/1", "term/abc/1"},
@@ -144,35 +146,35 @@ func (s *MetaSuite) TestCheckTerms(c *gc.C) {
 	}, {
 		about:       "term may not contain spaces",
 		terms:       []string{"term/1", "term about a term"},
-		expectError: `wrong term name format "term about a term"`,
+		expectError: `terms\[1\]: wrong term name format "term about a term"`,
 	}, {
 		about:       "term name must start with lowercase letter",
 		terms:       []string{"Term/1"},
-		expectError: `wrong term name format "Term"`,
+		expectError: `terms\[0\]: wrong term name format "Term"`,
 	}, {
 		about:       "term name cannot contain capital letters",
 		terms:       []string{"owner/foO-Bar"},
-		expectError: `wrong term name format "foO-Bar"`,
+		expectError: `terms\[0\]: wrong term name format "foO-Bar"`,
 	}, {
 		about:       "term name cannot contain underscores, that's what dashes are for",
 		terms:       []string{"owner/foo_bar"},
-		expectError: `wrong term name format "foo_bar"`,
+		expectError: `terms\[0\]: wrong term name format "foo_bar"`,
 	}, {
 		about:       "term name can't end with a dash",
 		terms:       []string{"o-/1"},
-		expectError: `wrong term name format "o-"`,
+		expectError: `terms\[0\]: wrong term name format "o-"`,
 	}, {
 		about:       "term name can't contain consecutive dashes",
 		terms:       []string{"o-oo--ooo---o/1"},
-		expectError: `wrong term name format "o-oo--ooo---o"`,
+		expectError: `terms\[0\]: wrong term name format "o-oo--ooo---o"`,
 	}, {
 		about:       "term name more than a single char",
 		terms:       []string{"z/1"},
-		expectError: `wrong term name format "z"`,
+		expectError: `terms\[0\]: wrong term name format "z"`,
 	}, {
 		about:       "term name match the regexp",
 		terms:       []string{"term_123-23aAf/1"},
-		expectError: `wrong term name format "term_123-23aAf"`,
+		expectError: `terms\[0\]: wrong term name format "term_123-23aAf"`,
 	},
 	}
 	for i, test := range tests {
@@ -296,7 +298,7 @@ terms: ["!!!/abc"]
 func (s *MetaSuite) TestReadInvalidTerms(c *gc.C) {
 	reader := strings.NewReader(metaDataWithInvalidTermsId)
 	_, err := charm.ReadMeta(reader)
-	c.Assert(err, gc.ErrorMatches, `wrong owner format "!!!"`)
+	c.Assert(err, gc.ErrorMatches, `terms\[0\]: wrong owner format "!!!"`)
 }
 
 func (s *MetaSuite) TestReadTags(c *gc.C) {
@@ -441,43 +443,43 @@ var relationsConstraintsTests = []struct {
 }{
 	{
 		"provides:\n  foo: ping\nrequires:\n  foo: pong",
-		`charm "a" using a duplicated relation name: "foo"`,
+		`requires.foo: charm "a" using a duplicated relation name: "foo"`,
 	}, {
 		"requires:\n  foo: ping\npeers:\n  foo: pong",
-		`charm "a" using a duplicated relation name: "foo"`,
+		`peers.foo: charm "a" using a duplicated relation name: "foo"`,
 	}, {
 		"peers:\n  foo: ping\nprovides:\n  foo: pong",
-		`charm "a" using a duplicated relation name: "foo"`,
+		`peers.foo: charm "a" using a duplicated relation name: "foo"`,
 	}, {
 		"provides:\n  juju: blob",
-		`charm "a" using a reserved relation name: "juju"`,
+		`provides.juju: charm "a" using a reserved relation name: "juju"`,
 	}, {
 		"requires:\n  juju: blob",
-		`charm "a" using a reserved relation name: "juju"`,
+		`requires.juju: charm "a" using a reserved relation name: "juju"`,
 	}, {
 		"peers:\n  juju: blob",
-		`charm "a" using a reserved relation name: "juju"`,
+		`peers.juju: charm "a" using a reserved relation name: "juju"`,
 	}, {
 		"provides:\n  juju-snap: blub",
-		`charm "a" using a reserved relation name: "juju-snap"`,
+		`provides.juju-snap: charm "a" using a reserved relation name: "juju-snap"`,
 	}, {
 		"requires:\n  juju-crackle: blub",
-		`charm "a" using a reserved relation name: "juju-crackle"`,
+		`requires.juju-crackle: charm "a" using a reserved relation name: "juju-crackle"`,
 	}, {
 		"peers:\n  juju-pop: blub",
-		`charm "a" using a reserved relation name: "juju-pop"`,
+		`peers.juju-pop: charm "a" using a reserved relation name: "juju-pop"`,
 	}, {
 		"provides:\n  innocuous: juju",
-		`charm "a" relation "innocuous" using a reserved interface: "juju"`,
+		`provides.innocuous.interface: charm "a" relation "innocuous" using a reserved interface: "juju"`,
 	}, {
 		"peers:\n  innocuous: juju",
-		`charm "a" relation "innocuous" using a reserved interface: "juju"`,
+		`peers.innocuous.interface: charm "a" relation "innocuous" using a reserved interface: "juju"`,
 	}, {
 		"provides:\n  innocuous: juju-snap",
-		`charm "a" relation "innocuous" using a reserved interface: "juju-snap"`,
+		`provides.innocuous.interface: charm "a" relation "innocuous" using a reserved interface: "juju-snap"`,
 	}, {
 		"peers:\n  innocuous: juju-snap",
-		`charm "a" relation "innocuous" using a reserved interface: "juju-snap"`,
+		`peers.innocuous.interface: charm "a" relation "innocuous" using a reserved interface: "juju-snap"`,
 	},
 }
 
@@ -530,15 +532,111 @@ func (s *MetaSuite) TestSeries(c *gc.C) {
 	c.Assert(meta.Series, gc.DeepEquals, []string{"precise", "trusty", "plan9"})
 }
 
+func (s *MetaSuite) TestUnknownSeries(c *gc.C) {
+	for _, seriesName := range []string{"plan9", "xenail", "bundle"} {
+		meta, err := charm.ReadMeta(strings.NewReader(
+			fmt.Sprintf("%s\nseries:\n    - xenial\n    - %s\n", dummyMetadata, seriesName)))
+		c.Assert(err, jc.ErrorIsNil)
+		warnings, err := meta.CheckWithWarnings()
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(warnings, jc.DeepEquals, []charm.ReadWarning{{
+			Path:    "series[0]",
+			Message: `series "xenial" reached end of life on 2021-04-30`,
+		}, {
+			Path:    "series[1]",
+			Message: fmt.Sprintf("unknown series %q", seriesName),
+		}})
+	}
+}
+
+func (s *MetaSuite) TestCheckWithWarningsInvalidSeries(c *gc.C) {
+	meta := charm.Meta{
+		Name:   "a",
+		Series: []string{"jammy", "Bad"},
+	}
+	warnings, err := meta.CheckWithWarnings()
+	c.Assert(err, gc.ErrorMatches, `series\[1\]: charm "a" declares invalid series: "Bad"`)
+	c.Assert(warnings, gc.HasLen, 0)
+}
+
 func (s *MetaSuite) TestInvalidSeries(c *gc.C) {
 	for _, seriesName := range []string{"pre-c1se", "pre^cise", "cp/m", "OpenVMS"} {
 		_, err := charm.ReadMeta(strings.NewReader(
 			fmt.Sprintf("%s\nseries:\n    - %s\n", dummyMetadata, seriesName)))
 		c.Assert(err, gc.NotNil)
-		c.Check(err, gc.ErrorMatches, `charm "a" declares invalid series: .*`)
+		c.Check(err, gc.ErrorMatches, `series\[0\]: charm "a" declares invalid series: .*`)
+	}
+}
+
+func (s *MetaSuite) TestBases(c *gc.C) {
+	meta, err := charm.ReadMeta(strings.NewReader(dummyMetadata + `
+bases:
+    - name: ubuntu
+      channel: "22.04"
+      architectures: [amd64]
+    - name: ubuntu
+      channel: 20.04/candidate
+`))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(meta.Bases, jc.DeepEquals, []charm.Base{{
+		Name:          "ubuntu",
+		Channel:       charm.Channel{Track: "22.04", Risk: charm.Stable},
+		Architectures: []string{"amd64"},
+	}, {
+		Name:    "ubuntu",
+		Channel: charm.Channel{Track: "20.04", Risk: charm.Candidate},
+	}})
+}
+
+var invalidBasesTests = []struct {
+	about string
+	bases string
+	err   string
+}{{
+	about: "missing name",
+	bases: "    - channel: \"22.04\"\n",
+	err:   `metadata: bases\[0\]\.name: expected string, got nothing`,
+}, {
+	about: "bad risk",
+	bases: "    - name: ubuntu\n      channel: 22.04/risky\n",
+	err:   `bases\[0\]\.channel: invalid channel "22.04/risky": unknown risk "risky"`,
+}, {
+	about: "no track",
+	bases: "    - name: ubuntu\n      channel: stable\n",
+	err:   `bases\[0\]: charm "a" declares invalid base: base "ubuntu": channel must include a track`,
+}, {
+	about: "bad architecture",
+	bases: "    - name: ubuntu\n      channel: \"22.04\"\n      architectures: [z80]\n",
+	err:   `bases\[0\]: charm "a" declares invalid base: base "ubuntu": unsupported architecture "z80"`,
+}}
+
+func (s *MetaSuite) TestInvalidBases(c *gc.C) {
+	for i, test := range invalidBasesTests {
+		c.Logf("test %d: %s", i, test.about)
+		_, err := charm.ReadMeta(strings.NewReader(dummyMetadata + "\nbases:\n" + test.bases))
+		c.Check(err, gc.ErrorMatches, test.err)
 	}
 }
 
+func (s *MetaSuite) TestComputedBases(c *gc.C) {
+	meta := &charm.Meta{Series: []string{"xenial", "jammy"}}
+	bases, err := meta.ComputedBases()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bases, jc.DeepEquals, []charm.Base{
+		charm.MustParseBase("ubuntu@16.04"),
+		charm.MustParseBase("ubuntu@22.04"),
+	})
+
+	meta.Bases = []charm.Base{charm.MustParseBase("ubuntu@20.04")}
+	bases, err = meta.ComputedBases()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bases, jc.DeepEquals, meta.Bases)
+
+	meta = &charm.Meta{Series: []string{"plan9"}}
+	_, err = meta.ComputedBases()
+	c.Assert(err, gc.ErrorMatches, `unknown series "plan9"`)
+}
+
 func (s *MetaSuite) TestMinJujuVersion(c *gc.C) {
 	// series not specified
 	meta, err := charm.ReadMeta(strings.NewReader(dummyMetadata))
@@ -586,7 +684,7 @@ func (s *MetaSuite) TestCheckMismatchedRelationName(c *gc.C) {
 		},
 	}
 	err := meta.Check()
-	c.Assert(err, gc.ErrorMatches, `charm "foo" has mismatched role "peer"; expected "provider"`)
+	c.Assert(err, gc.ErrorMatches, `provides.foo: charm "foo" has mismatched role "peer"; expected "provider"`)
 }
 
 func (s *MetaSuite) TestCheckMismatchedRole(c *gc.C) {
@@ -604,7 +702,7 @@ func (s *MetaSuite) TestCheckMismatchedRole(c *gc.C) {
 		},
 	}
 	err := meta.Check()
-	c.Assert(err, gc.ErrorMatches, `charm "foo" has mismatched relation name ""; expected "foo"`)
+	c.Assert(err, gc.ErrorMatches, `provides.foo: charm "foo" has mismatched relation name ""; expected "foo"`)
 }
 
 func (s *MetaSuite) TestCheckMismatchedExtraBindingName(c *gc.C) {
@@ -615,7 +713,7 @@ func (s *MetaSuite) TestCheckMismatchedExtraBindingName(c *gc.C) {
 		},
 	}
 	err := meta.Check()
-	c.Assert(err, gc.ErrorMatches, `charm "foo" has invalid extra bindings: mismatched extra binding name: got "bar", expected "foo"`)
+	c.Assert(err, gc.ErrorMatches, `extra-bindings: charm "foo" has invalid extra bindings: mismatched extra binding name: got "bar", expected "foo"`)
 }
 
 func (s *MetaSuite) TestCheckEmptyNameKeyOrEmptyExtraBindingName(c *gc.C) {
@@ -624,7 +722,7 @@ func (s *MetaSuite) TestCheckEmptyNameKeyOrEmptyExtraBindingName(c *gc.C) {
 		ExtraBindings: map[string]charm.ExtraBinding{"": {Name: "bar"}},
 	}
 	err := meta.Check()
-	expectedError := `charm "foo" has invalid extra bindings: missing binding name`
+	expectedError := `extra-bindings: charm "foo" has invalid extra bindings: missing binding name`
 	c.Assert(err, gc.ErrorMatches, expectedError)
 
 	meta.ExtraBindings = map[string]charm.ExtraBinding{"bar": {Name: ""}}
@@ -722,6 +820,66 @@ func (s *MetaSuite) TestMetaHooks(c *gc.C) {
 	c.Assert(hooks, jc.DeepEquals, expectedHooks)
 }
 
+func (s *MetaSuite) TestMetaHookKinds(c *gc.C) {
+	meta, err := charm.ReadMeta(strings.NewReader(`
+name: a
+summary: b
+description: c
+requires:
+    db: mysql
+storage:
+    data:
+        type: filesystem
+`))
+	c.Assert(err, gc.IsNil)
+	kinds := meta.HookKinds()
+	expectedKinds := map[string]hooks.Kind{
+		"db-relation-joined":     hooks.RelationJoined,
+		"db-relation-changed":    hooks.RelationChanged,
+		"db-relation-departed":   hooks.RelationDeparted,
+		"db-relation-broken":     hooks.RelationBroken,
+		"data-storage-attached":  hooks.StorageAttached,
+		"data-storage-detaching": hooks.StorageDetaching,
+	}
+	for _, kind := range hooks.UnitHooks() {
+		expectedKinds[string(kind)] = kind
+	}
+	c.Assert(kinds, jc.DeepEquals, expectedKinds)
+
+	allHooks := meta.Hooks()
+	c.Assert(allHooks, gc.HasLen, len(expectedKinds))
+	for name := range expectedKinds {
+		c.Check(allHooks[name], jc.IsTrue, gc.Commentf("hook %q", name))
+	}
+}
+
+func (s *MetaSuite) TestMetaHookInfo(c *gc.C) {
+	meta, err := charm.ReadMeta(strings.NewReader(`
+name: a
+summary: b
+description: c
+provides:
+    db-admin: mysql-root
+storage:
+    shared-fs:
+        type: filesystem
+`))
+	c.Assert(err, gc.IsNil)
+	for name, expect := range map[string]hooks.Info{
+		"install":                    {Kind: hooks.Install},
+		"db-admin-relation-departed": {Kind: hooks.RelationDeparted, RelationName: "db-admin"},
+		"shared-fs-storage-attached": {Kind: hooks.StorageAttached, StorageName: "shared-fs"},
+	} {
+		info, err := meta.HookInfo(name)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(info, jc.DeepEquals, expect)
+		c.Check(info.Name(), gc.Equals, name)
+	}
+
+	_, err = meta.HookInfo("db-relation-joined")
+	c.Assert(err, gc.ErrorMatches, `charm "a" has no hook "db-relation-joined"`)
+}
+
 func (s *MetaSuite) TestCodecRoundTripEmpty(c *gc.C) {
 	for i, codec := range codecs {
 		c.Logf("codec %d", i)
@@ -775,9 +933,61 @@ func (s *MetaSuite) TestCodecRoundTrip(c *gc.C) {
 			"b1": {Name: "b1"},
 			"b2": {Name: "b2"},
 		},
+		Bases: []charm.Base{
+			charm.MustParseBase("ubuntu@22.04"),
+			charm.MustParseBase("centos@7/edge"),
+		},
 		Categories: []string{"quxxxx", "quxxxxx"},
 		Tags:       []string{"openstack", "storage"},
 		Terms:      []string{"test-term/1", "test-term/2"},
+		Storage: map[string]charm.Storage{
+			"data": {
+				Name:     "data",
+				Type:     charm.StorageFilesystem,
+				CountMin: 1,
+				CountMax: 1,
+			},
+		},
+		Resources: map[string]resource.Meta{
+			"image": {
+				Name: "image",
+				Type: resource.TypeContainerImage,
+			},
+		},
+		Containers: map[string]charm.Container{
+			"web": {
+				Resource: "image",
+				Mounts: []charm.Mount{{
+					Storage:  "data",
+					Location: "/srv/data",
+				}},
+			},
+		},
+		Deployment: &charm.Deployment{
+			DeploymentType: charm.DeploymentStateful,
+			DeploymentMode: charm.ModeWorkload,
+			ServiceType:    charm.ServiceLoadBalancer,
+			MinVersion:     "1.15",
+		},
+		Assumes: &assumes.ExpressionTree{
+			Expression: assumes.CompositeExpression{
+				ExprType: assumes.ExpressionAllOf,
+				SubExpressions: []assumes.Expression{
+					assumes.FeatureExpression{
+						Name:       "juju",
+						Constraint: assumes.VersionAtLeast,
+						Version:    version.MustParse("2.9.0"),
+					},
+					assumes.CompositeExpression{
+						ExprType: assumes.ExpressionAnyOf,
+						SubExpressions: []assumes.Expression{
+							assumes.FeatureExpression{Name: "k8s-api"},
+							assumes.FeatureExpression{Name: "lxd"},
+						},
+					},
+				},
+			},
+		},
 	}
 	for i, codec := range codecs {
 		c.Logf("codec %d", i)
@@ -894,6 +1104,105 @@ resources:
         filename: 'y.tgz'
         type: file
 `,
+}, {
+	about: "charm with storage and payloads",
+	yaml: `
+name: stored
+description: d
+summary: s
+storage:
+    singleton:
+        type: block
+    described:
+        type: filesystem
+        description: a place for things
+        shared: true
+        read-only: true
+        location: /srv/things
+        minimum-size: 10G
+        properties: [transient]
+    fixed:
+        type: block
+        multiple:
+            range: 3
+    bounded:
+        type: filesystem
+        multiple:
+            range: 0-5
+    unbounded:
+        type: block
+        multiple:
+            range: 2+
+    unbounded-alt:
+        type: block
+        multiple:
+            range: 1-
+payloads:
+    monitor:
+        type: docker
+    kvm-guest:
+        type: kvm
+min-juju-version: 2.0.0
+terms: [term1/1, term2]
+`,
+}, {
+	about: "charm with containers",
+	yaml: `
+name: k8s
+description: d
+summary: s
+storage:
+    data:
+        type: filesystem
+        location: /srv/data
+resources:
+    web-image:
+        type: oci-image
+        description: the web server image
+    sidecar-image:
+        type: oci-image
+containers:
+    web:
+        resource: web-image
+        mounts:
+            - storage: data
+              location: /var/lib/web
+            - storage: data
+    sidecar:
+        resource: sidecar-image
+deployment:
+    type: stateful
+    mode: workload
+    service: loadbalancer
+    min-version: "1.15"
+`,
+}, {
+	about: "charm with assumes",
+	yaml: `
+name: assuming
+description: d
+summary: s
+assumes:
+    - juju >= 2.9
+    - any-of:
+        - k8s-api
+        - all-of:
+            - lxd
+            - lxd-profile < 2.0
+`,
+}, {
+	about: "charm with bases",
+	yaml: `
+name: based
+description: d
+summary: s
+bases:
+    - name: ubuntu
+      channel: "22.04"
+      architectures: [amd64, arm64]
+    - name: ubuntu
+      channel: "20.04/edge"
+`,
 }}
 
 func (s *MetaSuite) TestYAMLMarshal(c *gc.C) {
@@ -922,6 +1231,59 @@ func (s *MetaSuite) TestYAMLMarshalV2(c *gc.C) {
 	}
 }
 
+func (s *MetaSuite) TestYAMLMarshalRoundTripTestRepo(c *gc.C) {
+	// Every charm in the testing repository must survive a
+	// trip through MarshalYAML and back without loss.
+	paths, err := filepath.Glob("internal/test-charm-repo/*/*/metadata.yaml")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(paths, gc.Not(gc.HasLen), 0)
+	for i, path := range paths {
+		c.Logf("test %d: %s", i, path)
+		data, err := ioutil.ReadFile(path)
+		c.Assert(err, jc.ErrorIsNil)
+		ch, err := charm.ReadMeta(bytes.NewReader(data))
+		c.Assert(err, jc.ErrorIsNil)
+		gotYAML, err := yaml.Marshal(ch)
+		c.Assert(err, jc.ErrorIsNil)
+		gotCh, err := charm.ReadMeta(bytes.NewReader(gotYAML))
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(gotCh, jc.DeepEquals, ch, gc.Commentf("marshaled: %s", gotYAML))
+	}
+}
+
+func (s *MetaSuite) TestYAMLMarshalStorageRoundTrip(c *gc.C) {
+	// Check all the interesting storage forms, not just
+	// the ones that happen to be easy to write in YAML.
+	counts := [][2]int{{1, 1}, {0, 1}, {0, -1}, {1, -1}, {2, 2}, {2, 7}}
+	sizes := []uint64{0, 1, 1023, 1024, 10 * 1024 * 1024}
+	for i, count := range counts {
+		for j, size := range sizes {
+			c.Logf("test %d.%d: count %v, size %d", i, j, count, size)
+			meta := &charm.Meta{
+				Name:        "a",
+				Summary:     "b",
+				Description: "c",
+				Storage: map[string]charm.Storage{
+					"store": {
+						Name:        "store",
+						Type:        charm.StorageFilesystem,
+						CountMin:    count[0],
+						CountMax:    count[1],
+						MinimumSize: size,
+						Location:    "/srv",
+					},
+				},
+			}
+			c.Assert(meta.Check(), jc.ErrorIsNil)
+			gotYAML, err := yaml.Marshal(meta)
+			c.Assert(err, jc.ErrorIsNil)
+			gotMeta, err := charm.ReadMeta(bytes.NewReader(gotYAML))
+			c.Assert(err, jc.ErrorIsNil)
+			c.Assert(gotMeta, jc.DeepEquals, meta, gc.Commentf("marshaled: %s", gotYAML))
+		}
+	}
+}
+
 func (s *MetaSuite) TestYAMLMarshalSimpleRelationOrExtraBinding(c *gc.C) {
 	// Check that a simple relation / extra-binding gets marshaled as a string.
 	chYAML := `
@@ -1033,15 +1395,15 @@ storage:
 	}, {
 		desc: "location cannot be specified for block type storage",
 		yaml: "  type: block\n  location: /dev/sdc",
-		err:  `charm "a" storage "store-bad": location may not be specified for "type: block"`,
+		err:  `storage.store-bad.location: charm "a" storage "store-bad": location may not be specified for "type: block"`,
 	}, {
 		desc: "minimum size must parse correctly",
 		yaml: "  type: block\n  minimum-size: foo",
-		err:  `metadata: expected a non-negative number, got "foo"`,
+		err:  `metadata: storage.store-bad.minimum-size: expected a non-negative number, got "foo"`,
 	}, {
 		desc: "minimum size must have valid suffix",
 		yaml: "  type: block\n  minimum-size: 10Q",
-		err:  `metadata: invalid multiplier suffix "Q", expected one of MGTPEZY`,
+		err:  `metadata: storage.store-bad.minimum-size: invalid multiplier suffix "Q", expected one of MGTPEZY`,
 	}, {
 		desc: "properties must contain valid values",
 		yaml: "  type: block\n  properties: [transient, foo]",
@@ -1056,6 +1418,217 @@ storage:
 	}
 }
 
+func (s *MetaSuite) TestReadMetaReportsAllErrors(c *gc.C) {
+	_, err := charm.ReadMeta(strings.NewReader(`
+name: a
+summary: 42
+description: c
+provides:
+    juju: http
+    good: http
+storage:
+    data:
+        type: filesystem
+        multiple:
+            range: lots
+    logs:
+        type: block
+        location: /var/log
+    cache:
+        type: filesystem
+payloads:
+    monitor:
+        kind: docker
+resources:
+    blob:
+        type: tarball
+        filename: blob.tgz
+min-juju-version: not-a-version
+`))
+	c.Assert(err, gc.FitsTypeOf, &charm.MetaValidationError{})
+	verr := err.(*charm.MetaValidationError)
+	var paths []string
+	for _, e := range verr.Errors {
+		c.Logf("%s: %v", e.Path, e)
+		paths = append(paths, e.Path)
+	}
+	c.Assert(paths, jc.DeepEquals, []string{
+		"payloads.monitor.type",
+		"storage.data.multiple.range",
+		"summary",
+		"min-juju-version",
+		"resources.blob.type",
+		"provides.juju",
+		"storage.logs.location",
+	})
+	c.Assert(verr.Errors[1], gc.ErrorMatches, `metadata: storage.data.multiple.range: value "lots" does not match 'm', 'm-n', or 'm\+'`)
+	c.Assert(err, gc.ErrorMatches, `metadata: payloads.monitor.type: .* \(and 6 more errors\)`)
+}
+
+func (s *MetaSuite) TestCheckReportsAllErrors(c *gc.C) {
+	meta := charm.Meta{
+		Name: "a",
+		Provides: map[string]charm.Relation{
+			"juju-foo": {
+				Name:      "juju-foo",
+				Role:      charm.RoleProvider,
+				Interface: "juju-bar",
+			},
+		},
+		Series: []string{"precise", "Bad"},
+		Storage: map[string]charm.Storage{
+			"data": {
+				Name:     "data",
+				CountMin: -1,
+				CountMax: 0,
+			},
+		},
+		PayloadClasses: map[string]charm.PayloadClass{
+			"monitor": {Name: "monitor"},
+		},
+		Terms: []string{"term/1", "!!!"},
+	}
+	err := meta.Check()
+	c.Assert(err, gc.FitsTypeOf, &charm.MetaValidationError{})
+	verr := err.(*charm.MetaValidationError)
+	var paths []string
+	for _, e := range verr.Errors {
+		c.Logf("%s: %v", e.Path, e)
+		paths = append(paths, e.Path)
+	}
+	c.Assert(paths, jc.DeepEquals, []string{
+		"provides.juju-foo",
+		"provides.juju-foo.interface",
+		"series[1]",
+		"storage.data.type",
+		"storage.data.multiple.range",
+		"storage.data.multiple.range",
+		"payloads.monitor",
+		"terms[1]",
+	})
+	c.Assert(verr.Errors[2], gc.ErrorMatches, `series\[1\]: charm "a" declares invalid series: "Bad"`)
+}
+
+var containersCheckTests = []struct {
+	about string
+	yaml  string
+	paths []string
+	err   string
+}{{
+	about: "valid containers",
+	yaml: `
+containers:
+    web:
+        resource: image
+        mounts:
+            - storage: data
+              location: /srv
+`,
+}, {
+	about: "missing resource",
+	yaml: `
+containers:
+    web:
+        mounts:
+            - storage: data
+`,
+	paths: []string{"containers.web.resource"},
+	err:   `containers.web.resource: container "web": resource must be specified`,
+}, {
+	about: "unknown resource",
+	yaml: `
+containers:
+    web:
+        resource: nope
+`,
+	paths: []string{"containers.web.resource"},
+	err:   `containers.web.resource: container "web": resource "nope" not found`,
+}, {
+	about: "resource is not an image",
+	yaml: `
+containers:
+    web:
+        resource: blob
+`,
+	paths: []string{"containers.web.resource"},
+	err:   `containers.web.resource: container "web": resource "blob" is not of type "oci-image"`,
+}, {
+	about: "bad mounts",
+	yaml: `
+containers:
+    web:
+        resource: image
+        mounts:
+            - storage: nope
+            - storage: disk
+              location: relative/path
+`,
+	paths: []string{
+		"containers.web.mounts[0].storage",
+		"containers.web.mounts[1].storage",
+		"containers.web.mounts[1].location",
+	},
+	err: `containers.web.mounts\[0\].storage: container "web": storage "nope" not found \(and 2 more errors\)`,
+}, {
+	about: "bad deployment",
+	yaml: `
+deployment:
+    type: sometimes
+    mode: workload
+    service: everywhere
+`,
+	paths: []string{"deployment.type", "deployment.service"},
+	err:   `deployment.type: invalid deployment type "sometimes" \(and 1 more errors\)`,
+}}
+
+func (s *MetaSuite) TestContainersCheck(c *gc.C) {
+	for i, test := range containersCheckTests {
+		c.Logf("test %d: %s", i, test.about)
+		_, err := charm.ReadMeta(strings.NewReader(`
+name: a
+summary: b
+description: c
+storage:
+    data:
+        type: filesystem
+    disk:
+        type: block
+resources:
+    image:
+        type: oci-image
+    blob:
+        type: file
+        filename: blob.tgz
+` + test.yaml))
+		if test.err == "" {
+			c.Assert(err, jc.ErrorIsNil)
+			continue
+		}
+		c.Assert(err, gc.ErrorMatches, test.err)
+		var paths []string
+		for _, e := range err.(*charm.MetaValidationError).Errors {
+			paths = append(paths, e.Path)
+		}
+		c.Assert(paths, jc.DeepEquals, test.paths)
+	}
+}
+
+func (s *MetaSuite) TestAssumesErrorPath(c *gc.C) {
+	_, err := charm.ReadMeta(strings.NewReader(`
+name: a
+summary: b
+description: c
+assumes:
+    - juju >= 2.9
+    - any-of:
+        - Bad
+`))
+	c.Assert(err, gc.ErrorMatches, `assumes\[1\].any-of\[0\]: invalid feature expression "Bad"`)
+	verr := err.(*charm.MetaValidationError)
+	c.Assert(verr.Errors[0].Path, gc.Equals, "assumes[1].any-of[0]")
+	c.Assert(verr.Errors[0].Line, gc.Equals, 8)
+}
+
 func (s *MetaSuite) TestStorageCount(c *gc.C) {
 	testStorageCount := func(count string, min, max int) {
 		meta, err := charm.ReadMeta(strings.NewReader(fmt.Sprintf(`
@@ -1307,7 +1880,7 @@ func (s *MetaSuite) TestParseResourceMetaEmptyType(c *gc.C) {
 	}
 	_, err := charm.ParseResourceMeta(name, data)
 
-	c.Check(err, gc.ErrorMatches, `unsupported resource type .*`)
+	c.Check(err, gc.ErrorMatches, `resources.my-resource.type: unsupported resource type .*`)
 }
 
 func (s *MetaSuite) TestParseResourceMetaUnknownType(c *gc.C) {
@@ -1319,7 +1892,7 @@ func (s *MetaSuite) TestParseResourceMetaUnknownType(c *gc.C) {
 	}
 	_, err := charm.ParseResourceMeta(name, data)
 
-	c.Check(err, gc.ErrorMatches, `unsupported resource type .*`)
+	c.Check(err, gc.ErrorMatches, `resources.my-resource.type: unsupported resource type .*`)
 }
 
 func (s *MetaSuite) TestParseResourceMetaMissingPath(c *gc.C) {
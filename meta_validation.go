@@ -0,0 +1,189 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/juju/schema"
+)
+
+// MetaError describes a single problem found in charm metadata.
+type MetaError struct {
+	// Path holds the YAML path of the offending key, for
+	// example "storage.data.multiple.range". It is empty if the
+	// problem does not relate to any single key.
+	Path string
+
+	// Position holds the location of the offending key when
+	// the metadata was read with ReadMeta.
+	Position
+
+	// Err holds the problem itself.
+	Err error
+}
+
+// Error implements error. The message is prefixed with the path
+// of the offending key unless the underlying error already
+// names it, as errors from the schema checkers do.
+func (e *MetaError) Error() string {
+	msg := e.Err.Error()
+	if e.Path == "" || strings.Contains(msg, e.Path) {
+		return msg
+	}
+	return e.Path + ": " + msg
+}
+
+// MetaValidationError is returned when reading or checking charm
+// metadata fails. It holds all the problems found rather than just
+// the first one.
+type MetaValidationError struct {
+	Errors []*MetaError
+}
+
+// Error implements error.
+func (err *MetaValidationError) Error() string {
+	switch len(err.Errors) {
+	case 0:
+		return "no validation errors!"
+	case 1:
+		return err.Errors[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", err.Errors[0], len(err.Errors)-1)
+}
+
+// metaErrors accumulates metadata problems.
+type metaErrors []*MetaError
+
+// addf records a problem with the key at the given YAML path.
+func (errs *metaErrors) addf(path string, f string, a ...interface{}) {
+	errs.add(path, fmt.Errorf(f, a...))
+}
+
+// add records err against the key at the given YAML path. If err
+// already holds a set of problems, they are all recorded unchanged.
+func (errs *metaErrors) add(path string, err error) {
+	if verr, ok := err.(*MetaValidationError); ok {
+		*errs = append(*errs, verr.Errors...)
+		return
+	}
+	*errs = append(*errs, &MetaError{
+		Path: path,
+		Err:  err,
+	})
+}
+
+// err returns a *MetaValidationError holding all the recorded
+// problems, or nil if there are none.
+func (errs metaErrors) err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &MetaValidationError{Errors: errs}
+}
+
+// sortedKeys returns the keys of m in sorted order, so
+// that problems are reported in a predictable order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// schemaPath returns the YAML path represented by a schema
+// checker path.
+func schemaPath(path []string) string {
+	return strings.TrimPrefix(strings.Join(path, ""), ".")
+}
+
+// collectingFieldMap is like schema.FieldMap, except that it does not
+// stop at the first field that fails to coerce. The fields that coerce
+// successfully are returned along with a *MetaValidationError that holds
+// a problem for each field that does not.
+func collectingFieldMap(fields schema.Fields, defaults schema.Defaults) schema.Checker {
+	return collectingFieldMapC{fields, defaults}
+}
+
+type collectingFieldMapC struct {
+	fields   schema.Fields
+	defaults schema.Defaults
+}
+
+func (c collectingFieldMapC) Coerce(v interface{}, path []string) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map {
+		// Let the schema package produce its usual error.
+		return schema.FieldMap(c.fields, c.defaults).Coerce(v, path)
+	}
+	keys := make([]string, 0, len(c.fields))
+	for key := range c.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs metaErrors
+	out := make(map[string]interface{})
+	for _, key := range keys {
+		var value interface{}
+		if vv := rv.MapIndex(reflect.ValueOf(key)); vv.IsValid() {
+			value = vv.Interface()
+		} else if dflt, ok := c.defaults[key]; ok {
+			if dflt == schema.Omit {
+				continue
+			}
+			value = dflt
+		}
+		vpath := append(path[:len(path):len(path)], ".", key)
+		newv, err := c.fields[key].Coerce(value, vpath)
+		if err != nil {
+			errs.add(schemaPath(vpath), err)
+		}
+		// A collecting checker may return the part of the
+		// value that it could coerce along with its errors.
+		if err == nil || newv != nil {
+			out[key] = newv
+		}
+	}
+	return out, errs.err()
+}
+
+// collectingStringMap is like schema.StringMap, except that it does not
+// stop at the first value that fails to coerce. Entries with values that
+// coerce successfully are returned along with a *MetaValidationError that
+// holds the problems found with the others.
+func collectingStringMap(value schema.Checker) schema.Checker {
+	return collectingStringMapC{value}
+}
+
+type collectingStringMapC struct {
+	value schema.Checker
+}
+
+func (c collectingStringMapC) Coerce(v interface{}, path []string) (interface{}, error) {
+	mv, err := schema.StringMap(schema.Any()).Coerce(v, path)
+	if err != nil {
+		return nil, err
+	}
+	m := mv.(map[string]interface{})
+	var errs metaErrors
+	out := make(map[string]interface{})
+	for _, key := range sortedKeys(m) {
+		vpath := append(path[:len(path):len(path)], ".", key)
+		newv, err := c.value.Coerce(m[key], vpath)
+		if err != nil {
+			// Partially coerced values are of no use to
+			// the caller, so drop the whole entry.
+			errs.add(schemaPath(vpath), err)
+			continue
+		}
+		out[key] = newv
+	}
+	return out, errs.err()
+}
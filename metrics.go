@@ -74,15 +74,17 @@ type Metrics struct {
 	Plan    *Plan             `yaml:"plan,omitempty"`
 }
 
-// ReadMetrics reads a MetricsDeclaration in YAML format.
+// ReadMetrics reads a MetricsDeclaration in YAML format. Problems that
+// can be traced to a location in the YAML are reported with a *ParseError.
 func ReadMetrics(r io.Reader) (*Metrics, error) {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
+	src := newYAMLSource(data)
 	var metrics Metrics
 	if err := goyaml.Unmarshal(data, &metrics); err != nil {
-		return nil, err
+		return nil, src.yamlError(err)
 	}
 	if metrics.Metrics == nil {
 		return &metrics, nil
@@ -90,17 +92,17 @@ func ReadMetrics(r io.Reader) (*Metrics, error) {
 	for name, metric := range metrics.Metrics {
 		if IsBuiltinMetric(name) {
 			if metric.Type != MetricType("") || metric.Description != "" {
-				return nil, fmt.Errorf("metric %q is using a prefix reserved for built-in metrics: it should not have type or description specification", name)
+				return nil, src.pathErrorf("metrics."+name, "metric %q is using a prefix reserved for built-in metrics: it should not have type or description specification", name)
 			}
 			continue
 		}
 		switch metric.Type {
 		case MetricTypeGauge, MetricTypeAbsolute:
 		default:
-			return nil, fmt.Errorf("invalid metrics declaration: metric %q has unknown type %q", name, metric.Type)
+			return nil, src.pathErrorf("metrics."+name+".type", "invalid metrics declaration: metric %q has unknown type %q", name, metric.Type)
 		}
 		if metric.Description == "" {
-			return nil, fmt.Errorf("invalid metrics declaration: metric %q lacks description", name)
+			return nil, src.pathErrorf("metrics."+name+".description", "invalid metrics declaration: metric %q lacks description", name)
 		}
 	}
 	return &metrics, nil
@@ -10,9 +10,9 @@ import (
 	"gopkg.in/juju/names.v2"
 )
 
-var payloadClassSchema = schema.FieldMap(
+var payloadClassSchema = collectingFieldMap(
 	schema.Fields{
-		"type": schema.String(),
+		"type": stringSchema(),
 	},
 	schema.Defaults{},
 )
@@ -0,0 +1,241 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Position describes a location within a YAML file.
+type Position struct {
+	// File holds the name of the file. It is empty when
+	// the YAML was read from an unnamed source.
+	File string
+
+	// Line and Column hold the 1-based location within the
+	// file. They are zero when the location is not known.
+	Line   int
+	Column int
+}
+
+// String returns the position in the conventional
+// "file:line:column" form, omitting any unknown parts.
+func (p Position) String() string {
+	s := p.File
+	if p.Line > 0 {
+		if s != "" {
+			s += ":"
+		}
+		s += strconv.Itoa(p.Line)
+		if p.Column > 0 {
+			s += ":" + strconv.Itoa(p.Column)
+		}
+	}
+	return s
+}
+
+// ParseError describes a problem found when reading a YAML file
+// that can be traced to a particular location within it.
+//
+// The error message is that of the underlying error; the location
+// is available from the Position field so that tools may report
+// it in whatever form suits them.
+type ParseError struct {
+	Position
+
+	// Path holds the YAML path of the offending key, for
+	// example "options.title.default". It is empty if the
+	// problem does not relate to any single key.
+	Path string
+
+	// Err holds the problem itself.
+	Err error
+}
+
+// Error implements error.
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+// setErrorFile records the given file name as the source of any
+// positioned errors in err, and returns err.
+func setErrorFile(err error, file string) error {
+	switch err := err.(type) {
+	case *ParseError:
+		err.File = file
+	case *MetaValidationError:
+		for _, e := range err.Errors {
+			e.File = file
+		}
+	}
+	return err
+}
+
+// yamlSource holds the content of a YAML file so that problems
+// found when reading it may be given a position. The content is
+// only parsed into nodes when a position is first asked for.
+type yamlSource struct {
+	data   []byte
+	parsed bool
+	root   *yamlv3.Node
+}
+
+func newYAMLSource(data []byte) *yamlSource {
+	return &yamlSource{data: data}
+}
+
+// rootNode returns the top level node of the document, or nil
+// if it cannot be parsed.
+func (s *yamlSource) rootNode() *yamlv3.Node {
+	if !s.parsed {
+		s.parsed = true
+		var doc yamlv3.Node
+		if err := yamlv3.Unmarshal(s.data, &doc); err == nil && len(doc.Content) > 0 {
+			s.root = doc.Content[0]
+		}
+	}
+	return s.root
+}
+
+// pathError returns a *ParseError for a problem with the key at
+// the given YAML path.
+func (s *yamlSource) pathError(path string, err error) error {
+	return &ParseError{
+		Position: s.pathPosition(path),
+		Path:     path,
+		Err:      err,
+	}
+}
+
+// pathErrorf is like pathError but formats the problem itself.
+func (s *yamlSource) pathErrorf(path string, f string, a ...interface{}) error {
+	return s.pathError(path, fmt.Errorf(f, a...))
+}
+
+// positionWarnings sets the position of each of the given
+// warnings from its YAML path, and returns them.
+func (s *yamlSource) positionWarnings(warnings []ReadWarning) []ReadWarning {
+	for i := range warnings {
+		warnings[i].Position = s.pathPosition(warnings[i].Path)
+	}
+	return warnings
+}
+
+// yamlError returns a *ParseError for an error returned
+// by the YAML package when unmarshaling the source.
+func (s *yamlSource) yamlError(err error) error {
+	return &ParseError{
+		Position: s.errorPosition(err),
+		Err:      err,
+	}
+}
+
+// pathPosition returns the position of the key at the given YAML
+// path, which holds mapping keys separated by dots and sequence
+// indexes in brackets, for example "series[1]". Map entries are
+// located by their key. If the path cannot be followed all the way,
+// the position of the deepest node found is returned.
+func (s *yamlSource) pathPosition(path string) Position {
+	node := s.rootNode()
+	if node == nil {
+		return Position{}
+	}
+	pos := nodePosition(node)
+	for path != "" {
+		for node.Kind == yamlv3.AliasNode && node.Alias != nil {
+			node = node.Alias
+		}
+		var next *yamlv3.Node
+		switch {
+		case strings.HasPrefix(path, "["):
+			end := strings.Index(path, "]")
+			if end < 0 || node.Kind != yamlv3.SequenceNode {
+				return pos
+			}
+			i, err := strconv.Atoi(path[1:end])
+			if err != nil || i < 0 || i >= len(node.Content) {
+				return pos
+			}
+			next, path = node.Content[i], path[end+1:]
+			pos = nodePosition(next)
+		case node.Kind == yamlv3.MappingNode:
+			// Keys may themselves contain dots, so
+			// choose the longest one that matches.
+			var key *yamlv3.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				k := node.Content[i]
+				if pathHasPrefix(path, k.Value) && (key == nil || len(k.Value) > len(key.Value)) {
+					key, next = k, node.Content[i+1]
+				}
+			}
+			if key == nil {
+				return pos
+			}
+			path = path[len(key.Value):]
+			pos = nodePosition(key)
+		default:
+			return pos
+		}
+		path = strings.TrimPrefix(path, ".")
+		node = next
+	}
+	return pos
+}
+
+// pathHasPrefix reports whether the given YAML path starts
+// with the given mapping key.
+func pathHasPrefix(path, key string) bool {
+	if !strings.HasPrefix(path, key) {
+		return false
+	}
+	rest := path[len(key):]
+	return rest == "" || rest[0] == '.' || rest[0] == '['
+}
+
+var yamlErrorLine = regexp.MustCompile(`line ([0-9]+)`)
+
+// errorPosition returns the position of an error returned by the
+// YAML package. The package only reports line numbers, in the text
+// of its errors, so the column is taken from the first node found
+// on that line.
+func (s *yamlSource) errorPosition(err error) Position {
+	m := yamlErrorLine.FindStringSubmatch(err.Error())
+	if m == nil {
+		return Position{}
+	}
+	line, _ := strconv.Atoi(m[1])
+	if node := firstNodeOnLine(s.rootNode(), line); node != nil {
+		return nodePosition(node)
+	}
+	return Position{Line: line}
+}
+
+// firstNodeOnLine returns the first node, in document order,
+// that starts on the given line.
+func firstNodeOnLine(node *yamlv3.Node, line int) *yamlv3.Node {
+	if node == nil || node.Line > line {
+		return nil
+	}
+	if node.Line == line {
+		return node
+	}
+	for _, child := range node.Content {
+		if n := firstNodeOnLine(child, line); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+func nodePosition(node *yamlv3.Node) Position {
+	return Position{
+		Line:   node.Line,
+		Column: node.Column,
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type PositionSuite struct{}
+
+var _ = gc.Suite(&PositionSuite{})
+
+var positionStringTests = []struct {
+	pos    charm.Position
+	expect string
+}{{
+	pos:    charm.Position{},
+	expect: "",
+}, {
+	pos:    charm.Position{File: "config.yaml"},
+	expect: "config.yaml",
+}, {
+	pos:    charm.Position{Line: 3},
+	expect: "3",
+}, {
+	pos:    charm.Position{File: "config.yaml", Line: 3, Column: 5},
+	expect: "config.yaml:3:5",
+}}
+
+func (s *PositionSuite) TestPositionString(c *gc.C) {
+	for i, test := range positionStringTests {
+		c.Logf("test %d: %#v", i, test.pos)
+		c.Check(test.pos.String(), gc.Equals, test.expect)
+	}
+}
+
+func (s *PositionSuite) TestReadMetaPositions(c *gc.C) {
+	_, err := charm.ReadMeta(strings.NewReader(`
+name: dummy
+summary: 42
+description: d
+provides:
+  juju:
+    interface: http
+storage:
+  data:
+    type: filesystem
+    multiple:
+      range: a-b
+series:
+  - precise
+  - "bad series"
+`))
+	verr, ok := err.(*charm.MetaValidationError)
+	c.Assert(ok, jc.IsTrue, gc.Commentf("got %#v", err))
+	var got []string
+	for _, e := range verr.Errors {
+		got = append(got, e.Path+" "+e.Position.String())
+	}
+	c.Assert(got, jc.SameContents, []string{
+		"storage.data.multiple.range 12:7",
+		"summary 3:1",
+		"provides.juju 6:3",
+		"series[1] 15:5",
+	})
+}
+
+func (s *PositionSuite) TestReadMetaSyntaxErrorPosition(c *gc.C) {
+	_, err := charm.ReadMeta(strings.NewReader("name: dummy\nsummary: [oops\n"))
+	perr, ok := err.(*charm.ParseError)
+	c.Assert(ok, jc.IsTrue, gc.Commentf("got %#v", err))
+	c.Assert(perr.Line, gc.Not(gc.Equals), 0)
+}
+
+var parseErrorPositionTests = []struct {
+	about  string
+	read   func(string) error
+	yaml   string
+	path   string
+	line   int
+	column int
+	err    string
+}{{
+	about: "config option with unknown type",
+	read:  readConfig,
+	yaml: `
+options:
+  title:
+    type: string
+  colour:
+    type: colour
+`,
+	path:   "options.colour.type",
+	line:   6,
+	column: 5,
+	err:    `invalid config: option "colour" has unknown type "colour"`,
+}, {
+	about: "config option with bad default",
+	read:  readConfig,
+	yaml: `
+options:
+  count:
+    type: int
+    default: lots
+`,
+	path:   "options.count.default",
+	line:   5,
+	column: 5,
+	err:    `invalid config default: option "count" expected int, got "lots"`,
+}, {
+	about: "config YAML type error",
+	read:  readConfig,
+	yaml: `
+options:
+  - title
+`,
+	line:   3,
+	column: 3,
+	err:    `yaml: unmarshal errors:\n.*`,
+}, {
+	about: "bad action name",
+	read:  readActions,
+	yaml: `
+snapshot:
+  description: Take a snapshot.
+Bad_Name:
+  description: Bad.
+`,
+	path:   "Bad_Name",
+	line:   4,
+	column: 1,
+	err:    `bad action name Bad_Name`,
+}, {
+	about: "action with a non-string description",
+	read:  readActions,
+	yaml: `
+snapshot:
+  description: [1, 2]
+`,
+	path:   "snapshot.description",
+	line:   3,
+	column: 3,
+	err:    `value for schema key "description" must be a string`,
+}, {
+	about: "metric without a description",
+	read:  readMetrics,
+	yaml: `
+metrics:
+  pings:
+    type: gauge
+`,
+	path:   "metrics.pings.description",
+	line:   3,
+	column: 3,
+	err:    `invalid metrics declaration: metric "pings" lacks description`,
+}, {
+	about: "metric with unknown type",
+	read:  readMetrics,
+	yaml: `
+metrics:
+  pings:
+    type: sometimes
+    description: Pings.
+`,
+	path:   "metrics.pings.type",
+	line:   4,
+	column: 5,
+	err:    `invalid metrics declaration: metric "pings" has unknown type "sometimes"`,
+}, {
+	about: "bundle YAML type error",
+	read:  readBundleData,
+	yaml: `
+applications:
+  wordpress:
+    charm: wordpress
+    num_units: many
+`,
+	line:   5,
+	column: 5,
+	err:    `cannot unmarshal bundle data: yaml: unmarshal errors:\n.*`,
+}, {
+	about: "bundle with both applications and services",
+	read:  readBundleData,
+	yaml: `
+applications:
+  wordpress:
+    charm: wordpress
+services:
+  mysql:
+    charm: mysql
+`,
+	path:   "services",
+	line:   5,
+	column: 1,
+	err:    `cannot unmarshal bundle data: cannot specify both applications and services`,
+}}
+
+func readConfig(s string) error {
+	_, err := charm.ReadConfig(strings.NewReader(s))
+	return err
+}
+
+func readActions(s string) error {
+	_, err := charm.ReadActionsYaml(strings.NewReader(s))
+	return err
+}
+
+func readMetrics(s string) error {
+	_, err := charm.ReadMetrics(strings.NewReader(s))
+	return err
+}
+
+func readBundleData(s string) error {
+	_, err := charm.ReadBundleData(strings.NewReader(s))
+	return err
+}
+
+func (s *PositionSuite) TestParseErrorPositions(c *gc.C) {
+	for i, test := range parseErrorPositionTests {
+		c.Logf("test %d: %s", i, test.about)
+		err := test.read(test.yaml)
+		c.Assert(err, gc.ErrorMatches, test.err)
+		perr, ok := err.(*charm.ParseError)
+		c.Assert(ok, jc.IsTrue, gc.Commentf("got %#v", err))
+		c.Check(perr.Path, gc.Equals, test.path)
+		c.Check(perr.Line, gc.Equals, test.line)
+		c.Check(perr.Column, gc.Equals, test.column)
+		c.Check(perr.File, gc.Equals, "")
+	}
+}
+
+func (s *PositionSuite) TestReadCharmDirErrorFile(c *gc.C) {
+	path := cloneDir(c, charmDirPath(c, "dummy"))
+	configPath := filepath.Join(path, "config.yaml")
+	err := ioutil.WriteFile(configPath, []byte("options:\n  t:\n    type: foo\n"), 0644)
+	c.Assert(err, gc.IsNil)
+
+	_, err = charm.ReadCharmDir(path)
+	c.Assert(err, gc.ErrorMatches, `invalid config: option "t" has unknown type "foo"`)
+	perr, ok := err.(*charm.ParseError)
+	c.Assert(ok, jc.IsTrue, gc.Commentf("got %#v", err))
+	c.Assert(perr.Position, jc.DeepEquals, charm.Position{
+		File:   configPath,
+		Line:   3,
+		Column: 5,
+	})
+}
+
+func (s *PositionSuite) TestReadCharmDirMetaErrorFile(c *gc.C) {
+	path := cloneDir(c, charmDirPath(c, "dummy"))
+	metaPath := filepath.Join(path, "metadata.yaml")
+	err := ioutil.WriteFile(metaPath, []byte("name: dummy\nsummary: 42\ndescription: d\n"), 0644)
+	c.Assert(err, gc.IsNil)
+
+	_, err = charm.ReadCharmDir(path)
+	verr, ok := err.(*charm.MetaValidationError)
+	c.Assert(ok, jc.IsTrue, gc.Commentf("got %#v", err))
+	c.Assert(verr.Errors, gc.HasLen, 1)
+	c.Assert(verr.Errors[0].Position, jc.DeepEquals, charm.Position{
+		File:   metaPath,
+		Line:   2,
+		Column: 1,
+	})
+}
+
+func (s *PositionSuite) TestReadCharmArchiveErrorFile(c *gc.C) {
+	path := cloneDir(c, charmDirPath(c, "dummy"))
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, gc.IsNil)
+	// Break the metrics after the directory has been read
+	// so that it can still be archived.
+	err = ioutil.WriteFile(filepath.Join(path, "metrics.yaml"), []byte("metrics:\n  pings:\n    type: gauge\n"), 0644)
+	c.Assert(err, gc.IsNil)
+
+	_, err = charm.ReadCharmArchive(archivePath(c, dir))
+	c.Assert(err, gc.ErrorMatches, `invalid metrics declaration: metric "pings" lacks description`)
+	perr, ok := err.(*charm.ParseError)
+	c.Assert(ok, jc.IsTrue, gc.Commentf("got %#v", err))
+	c.Assert(perr.Position, jc.DeepEquals, charm.Position{
+		File:   "metrics.yaml",
+		Line:   2,
+		Column: 3,
+	})
+}
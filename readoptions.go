@@ -0,0 +1,300 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ReadOptions controls how strictly charm metadata and bundle
+// data are read by ReadMetaWithOptions and related functions.
+type ReadOptions struct {
+	// Strict causes keys that are not part of the file format,
+	// such as misspelled ones, to be reported as errors rather
+	// than as warnings.
+	Strict bool
+}
+
+// ReadWarning describes something that was accepted when reading
+// a file but that is probably not what its author intended, such
+// as an obsolete key.
+type ReadWarning struct {
+	Position
+
+	// Path holds the YAML path of the key concerned,
+	// for example "revision".
+	Path string
+
+	// Message describes the problem.
+	Message string
+}
+
+// String returns the warning, preceded by its
+// position when that is known.
+func (w ReadWarning) String() string {
+	if pos := w.Position.String(); pos != "" {
+		return pos + ": " + w.Message
+	}
+	return w.Message
+}
+
+// deprecatedMetaKeys holds the metadata keys that are still
+// accepted, and the warning given for each.
+var deprecatedMetaKeys = map[string]string{
+	"revision": `metadata key "revision" is deprecated: use the revision file instead`,
+	"format":   `metadata key "format" is deprecated and ignored`,
+}
+
+// deprecatedBundleKeys holds the bundle keys that are still
+// accepted, and the warning given for each.
+var deprecatedBundleKeys = map[string]string{
+	"services": `bundle key "services" is deprecated: use "applications" instead`,
+}
+
+// ReadMetaWithOptions is like ReadMeta, but also returns warnings
+// about deprecated and unknown keys in the metadata, and about
+// series that are unknown or have reached end of life. When
+// opts.Strict is set, unknown keys are reported in a
+// *MetaValidationError instead.
+func ReadMetaWithOptions(r io.Reader, opts ReadOptions) (*Meta, []ReadWarning, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta, err := ReadMeta(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	src := newYAMLSource(data)
+	warnings, unknown, err := checkKeys(data, src, MetaJSONSchema(), deprecatedMetaKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	warnings = append(warnings, src.positionWarnings(meta.seriesWarnings())...)
+	if !opts.Strict {
+		return meta, append(warnings, unknownKeyWarnings(src, unknown)...), nil
+	}
+	if len(unknown) > 0 {
+		var errs metaErrors
+		for _, path := range unknown {
+			errs.addf(path, "metadata: unknown key %q", path)
+		}
+		for _, e := range errs {
+			e.Position = src.pathPosition(e.Path)
+		}
+		return nil, nil, errs.err()
+	}
+	return meta, warnings, nil
+}
+
+// ReadBundleDataWithOptions is like ReadBundleData, but also
+// returns warnings about deprecated and unknown keys in the
+// bundle data, and about series that are unknown or have reached
+// end of life. When opts.Strict is set, unknown keys are reported
+// in a *VerificationError holding a *ParseError for each key
+// instead.
+func ReadBundleDataWithOptions(r io.Reader, opts ReadOptions) (*BundleData, []ReadWarning, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	bd, err := ReadBundleData(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	src := newYAMLSource(data)
+	warnings, unknown, err := checkKeys(data, src, BundleJSONSchema(), deprecatedBundleKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	warnings = append(warnings, src.positionWarnings(seriesWarnings(bundleSeries(bd)))...)
+	if !opts.Strict {
+		return bd, append(warnings, unknownKeyWarnings(src, unknown)...), nil
+	}
+	if len(unknown) > 0 {
+		errs := make([]error, len(unknown))
+		for i, path := range unknown {
+			errs[i] = src.pathErrorf(path, "bundle: unknown key %q", path)
+		}
+		return nil, nil, &VerificationError{errs}
+	}
+	return bd, warnings, nil
+}
+
+// checkKeys returns warnings for the deprecated top level keys found
+// in the YAML data, and the paths of the keys that are not described
+// by the given JSON Schema, both in sorted order.
+func checkKeys(data []byte, src *yamlSource, doc map[string]interface{}, deprecated map[string]string) ([]ReadWarning, []string, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, nil, src.yamlError(err)
+	}
+	var warnings []ReadWarning
+	if m, ok := raw.(map[interface{}]interface{}); ok {
+		var keys []string
+		for key := range deprecated {
+			if _, ok := m[key]; ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			warnings = append(warnings, ReadWarning{
+				Position: src.pathPosition(key),
+				Path:     key,
+				Message:  deprecated[key],
+			})
+		}
+	}
+	return warnings, unknownKeys(doc, raw, ""), nil
+}
+
+// bundleSeries returns the series named in the bundle
+// data, keyed by their YAML paths.
+func bundleSeries(bd *BundleData) map[string]string {
+	series := make(map[string]string)
+	if bd.Series != "" {
+		series["series"] = bd.Series
+	}
+	for id, m := range bd.Machines {
+		if m != nil && m.Series != "" {
+			series["machines."+id+".series"] = m.Series
+		}
+	}
+	applicationsKey := "applications"
+	if bd.unmarshaledWithServices {
+		applicationsKey = "services"
+	}
+	for name, app := range bd.Applications {
+		if app != nil && app.Series != "" {
+			series[applicationsKey+"."+name+".series"] = app.Series
+		}
+	}
+	return series
+}
+
+// seriesWarnings returns warnings, in path order, for the series
+// that are not known or have reached end of life. The series are
+// keyed by the YAML paths they were found at. Series that are not
+// valid at all are left for Check and Verify to report as errors.
+func seriesWarnings(series map[string]string) []ReadWarning {
+	paths := make([]string, 0, len(series))
+	for path := range series {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	now := timeNow()
+	var warnings []ReadWarning
+	for _, path := range paths {
+		if !IsValidSeries(series[path]) {
+			continue
+		}
+		if err := CheckSeries(series[path], now); err != nil {
+			warnings = append(warnings, ReadWarning{
+				Path:    path,
+				Message: err.Error(),
+			})
+		}
+	}
+	return warnings
+}
+
+func unknownKeyWarnings(src *yamlSource, unknown []string) []ReadWarning {
+	var warnings []ReadWarning
+	for _, path := range unknown {
+		warnings = append(warnings, ReadWarning{
+			Position: src.pathPosition(path),
+			Path:     path,
+			Message:  fmt.Sprintf("unknown key %q", path),
+		})
+	}
+	return warnings
+}
+
+// unknownKeys returns the YAML paths of the mapping keys within v,
+// found at the given path, that are not described by the JSON Schema
+// doc. Parts of doc that do not name their keys accept any key.
+func unknownKeys(doc map[string]interface{}, v interface{}, path string) []string {
+	if doc["properties"] == nil && doc["additionalProperties"] == nil {
+		// Look for a variant that describes mappings,
+		// as when a relation is written in full.
+		for _, variant := range docVariants(doc) {
+			if variant["properties"] != nil || variant["additionalProperties"] != nil {
+				doc = variant
+				break
+			}
+		}
+	}
+	var unknown []string
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		properties, _ := doc["properties"].(map[string]interface{})
+		additional, _ := doc["additionalProperties"].(map[string]interface{})
+		if properties == nil && additional == nil {
+			return nil
+		}
+		keys := make([]string, 0, rv.Len())
+		for _, key := range rv.MapKeys() {
+			if s, ok := key.Interface().(string); ok {
+				keys = append(keys, s)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			value := rv.MapIndex(reflect.ValueOf(key)).Interface()
+			if property, ok := properties[key].(map[string]interface{}); ok {
+				unknown = append(unknown, unknownKeys(property, value, keyPath)...)
+			} else if additional != nil {
+				unknown = append(unknown, unknownKeys(additional, value, keyPath)...)
+			} else {
+				unknown = append(unknown, keyPath)
+			}
+		}
+	case reflect.Slice:
+		items, ok := doc["items"].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for i := 0; i < rv.Len(); i++ {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			unknown = append(unknown, unknownKeys(items, rv.Index(i).Interface(), itemPath)...)
+		}
+	}
+	return unknown
+}
+
+// docVariants returns the alternatives allowed by the JSON Schema doc.
+func docVariants(doc map[string]interface{}) []map[string]interface{} {
+	var variants []map[string]interface{}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		alternatives, _ := doc[key].([]interface{})
+		for _, alternative := range alternatives {
+			if variant, ok := alternative.(map[string]interface{}); ok {
+				variants = append(variants, variant)
+			}
+		}
+	}
+	return variants
+}
+
+// setWarningsFile records the given file name as the
+// source of the warnings, and returns them.
+func setWarningsFile(warnings []ReadWarning, file string) []ReadWarning {
+	for i := range warnings {
+		warnings[i].File = file
+	}
+	return warnings
+}
@@ -0,0 +1,304 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type ReadOptionsSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&ReadOptionsSuite{})
+
+const untidyMeta = `
+name: dummy
+summary: s
+description: d
+revision: 3
+format: 2
+requries:
+  db: mysql
+provides:
+  website: http
+  db:
+    interface: mysql
+    scpoe: container
+storage:
+  data:
+    type: filesystem
+    multiple:
+      range: 1-2
+      max: 2
+`
+
+func (s *ReadOptionsSuite) TestReadMetaWithOptionsWarnings(c *gc.C) {
+	meta, warnings, err := charm.ReadMetaWithOptions(strings.NewReader(untidyMeta), charm.ReadOptions{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(meta.Name, gc.Equals, "dummy")
+	c.Assert(warnings, jc.DeepEquals, []charm.ReadWarning{{
+		Position: charm.Position{Line: 6, Column: 1},
+		Path:     "format",
+		Message:  `metadata key "format" is deprecated and ignored`,
+	}, {
+		Position: charm.Position{Line: 5, Column: 1},
+		Path:     "revision",
+		Message:  `metadata key "revision" is deprecated: use the revision file instead`,
+	}, {
+		Position: charm.Position{Line: 13, Column: 5},
+		Path:     "provides.db.scpoe",
+		Message:  `unknown key "provides.db.scpoe"`,
+	}, {
+		Position: charm.Position{Line: 7, Column: 1},
+		Path:     "requries",
+		Message:  `unknown key "requries"`,
+	}, {
+		Position: charm.Position{Line: 19, Column: 7},
+		Path:     "storage.data.multiple.max",
+		Message:  `unknown key "storage.data.multiple.max"`,
+	}})
+	c.Assert(warnings[3].String(), gc.Equals, `7:1: unknown key "requries"`)
+
+	// The default remains lenient.
+	_, err = charm.ReadMeta(strings.NewReader(untidyMeta))
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *ReadOptionsSuite) TestReadMetaWithOptionsStrict(c *gc.C) {
+	_, _, err := charm.ReadMetaWithOptions(strings.NewReader(untidyMeta), charm.ReadOptions{Strict: true})
+	verr, ok := err.(*charm.MetaValidationError)
+	c.Assert(ok, jc.IsTrue, gc.Commentf("got %#v", err))
+	var got []string
+	for _, e := range verr.Errors {
+		got = append(got, e.Position.String()+" "+e.Error())
+	}
+	c.Assert(got, jc.DeepEquals, []string{
+		`13:5 metadata: unknown key "provides.db.scpoe"`,
+		`7:1 metadata: unknown key "requries"`,
+		`19:7 metadata: unknown key "storage.data.multiple.max"`,
+	})
+
+	// Deprecated keys are still only warned about.
+	meta, warnings, err := charm.ReadMetaWithOptions(strings.NewReader(`
+name: dummy
+summary: s
+description: d
+revision: 3
+`), charm.ReadOptions{Strict: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(meta.Name, gc.Equals, "dummy")
+	c.Assert(warnings, gc.HasLen, 1)
+	c.Assert(warnings[0].Path, gc.Equals, "revision")
+}
+
+func (s *ReadOptionsSuite) TestReadMetaWithOptionsEOLSeries(c *gc.C) {
+	s.PatchValue(charm.TimeNow, func() time.Time {
+		return time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	})
+	meta, warnings, err := charm.ReadMetaWithOptions(strings.NewReader(dummyMetadata+`
+series:
+    - xenial
+    - focal
+    - trusty
+`), charm.ReadOptions{Strict: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(meta.Series, gc.HasLen, 3)
+	c.Assert(warnings, jc.DeepEquals, []charm.ReadWarning{{
+		Position: charm.Position{Line: 5, Column: 7},
+		Path:     "series[0]",
+		Message:  `series "xenial" reached end of life on 2021-04-30`,
+	}, {
+		Position: charm.Position{Line: 7, Column: 7},
+		Path:     "series[2]",
+		Message:  `series "trusty" reached end of life on 2019-04-25`,
+	}})
+}
+
+func (s *ReadOptionsSuite) TestReadBundleDataWithOptionsEOLSeries(c *gc.C) {
+	s.PatchValue(charm.TimeNow, func() time.Time {
+		return time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	})
+	_, warnings, err := charm.ReadBundleDataWithOptions(strings.NewReader(`
+series: xenial
+machines:
+    0:
+        series: focal
+    1:
+        series: trusty
+services:
+    wordpress:
+        charm: wordpress
+        series: xenial
+        to: [0, 1]
+`), charm.ReadOptions{})
+	c.Assert(err, jc.ErrorIsNil)
+	var got []string
+	for _, w := range warnings {
+		got = append(got, w.String())
+	}
+	c.Assert(got, jc.DeepEquals, []string{
+		`8:1: bundle key "services" is deprecated: use "applications" instead`,
+		`7:9: series "trusty" reached end of life on 2019-04-25`,
+		`2:1: series "xenial" reached end of life on 2021-04-30`,
+		`11:9: series "xenial" reached end of life on 2021-04-30`,
+	})
+}
+
+func (s *ReadOptionsSuite) TestReadMetaWithOptionsUnknownSeries(c *gc.C) {
+	meta, warnings, err := charm.ReadMetaWithOptions(strings.NewReader(dummyMetadata+`
+series: [jammy, kubernetes]
+`), charm.ReadOptions{Strict: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(meta.Series, jc.DeepEquals, []string{"jammy", "kubernetes"})
+	c.Assert(warnings, jc.DeepEquals, []charm.ReadWarning{{
+		Position: charm.Position{Line: 4, Column: 17},
+		Path:     "series[1]",
+		Message:  `unknown series "kubernetes"`,
+	}})
+}
+
+func (s *ReadOptionsSuite) TestReadBundleDataWithOptionsUnknownSeries(c *gc.C) {
+	bd, warnings, err := charm.ReadBundleDataWithOptions(strings.NewReader(`
+series: xenail
+machines:
+    0:
+        series: bundle
+applications:
+    wordpress:
+        charm: wordpress
+        series: foo
+        num_units: 1
+        to: [0]
+`), charm.ReadOptions{Strict: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bd.Verify(nil, nil), jc.ErrorIsNil)
+	var got []string
+	for _, w := range warnings {
+		got = append(got, w.String())
+	}
+	c.Assert(got, jc.DeepEquals, []string{
+		`9:9: unknown series "foo"`,
+		`5:9: unknown series "bundle"`,
+		`2:1: unknown series "xenail"`,
+	})
+}
+
+func (s *ReadOptionsSuite) TestReadMetaWithOptionsInvalid(c *gc.C) {
+	_, _, err := charm.ReadMetaWithOptions(strings.NewReader("name: dummy\n"), charm.ReadOptions{})
+	c.Assert(err, gc.ErrorMatches, `metadata: description: expected string, got nothing.*`)
+}
+
+func (s *ReadOptionsSuite) TestReadCharmDirWithOptions(c *gc.C) {
+	_, warnings, err := charm.ReadCharmDirWithOptions(charmDirPath(c, "dummy"), charm.ReadOptions{Strict: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(warnings, gc.HasLen, 0)
+
+	path := cloneDir(c, charmDirPath(c, "dummy"))
+	metaPath := filepath.Join(path, "metadata.yaml")
+	err = ioutil.WriteFile(metaPath, []byte(untidyMeta), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	dir, warnings, err := charm.ReadCharmDirWithOptions(path, charm.ReadOptions{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(dir.Meta().Name, gc.Equals, "dummy")
+	c.Assert(warnings, gc.HasLen, 5)
+	c.Assert(warnings[0].String(), gc.Equals, metaPath+`:6:1: metadata key "format" is deprecated and ignored`)
+
+	_, _, err = charm.ReadCharmDirWithOptions(path, charm.ReadOptions{Strict: true})
+	verr, ok := err.(*charm.MetaValidationError)
+	c.Assert(ok, jc.IsTrue, gc.Commentf("got %#v", err))
+	c.Assert(verr.Errors[0].File, gc.Equals, metaPath)
+
+	// Archives refer to the file within the archive.
+	archive := archivePath(c, dir)
+	_, warnings, err = charm.ReadCharmArchiveWithOptions(archive, charm.ReadOptions{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(warnings, gc.HasLen, 5)
+	c.Assert(warnings[2].String(), gc.Equals, `metadata.yaml:13:5: unknown key "provides.db.scpoe"`)
+
+	_, _, err = charm.ReadCharmArchiveWithOptions(archive, charm.ReadOptions{Strict: true})
+	c.Assert(err, gc.ErrorMatches, `metadata: unknown key "provides.db.scpoe" \(and 2 more errors\)`)
+}
+
+const untidyBundle = `
+services:
+  wordpress:
+    charm: wordpress
+    num-units: 2
+    expose: true
+  mysql:
+    charm: mysql
+    num_units: 1
+relations:
+  - ["wordpress:db", "mysql:server"]
+colour: blue
+`
+
+func (s *ReadOptionsSuite) TestReadBundleDataWithOptions(c *gc.C) {
+	bd, warnings, err := charm.ReadBundleDataWithOptions(strings.NewReader(untidyBundle), charm.ReadOptions{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bd.Applications, gc.HasLen, 2)
+	c.Assert(warnings, jc.DeepEquals, []charm.ReadWarning{{
+		Position: charm.Position{Line: 2, Column: 1},
+		Path:     "services",
+		Message:  `bundle key "services" is deprecated: use "applications" instead`,
+	}, {
+		Position: charm.Position{Line: 12, Column: 1},
+		Path:     "colour",
+		Message:  `unknown key "colour"`,
+	}, {
+		Position: charm.Position{Line: 5, Column: 5},
+		Path:     "services.wordpress.num-units",
+		Message:  `unknown key "services.wordpress.num-units"`,
+	}})
+
+	_, _, err = charm.ReadBundleDataWithOptions(strings.NewReader(untidyBundle), charm.ReadOptions{Strict: true})
+	verr, ok := err.(*charm.VerificationError)
+	c.Assert(ok, jc.IsTrue, gc.Commentf("got %#v", err))
+	c.Assert(verr.Errors, gc.HasLen, 2)
+	c.Assert(err, gc.ErrorMatches, `bundle: unknown key "colour" \(and 1 more errors\)`)
+	for i, expect := range []struct {
+		path     string
+		position charm.Position
+	}{
+		{"colour", charm.Position{Line: 12, Column: 1}},
+		{"services.wordpress.num-units", charm.Position{Line: 5, Column: 5}},
+	} {
+		perr, ok := verr.Errors[i].(*charm.ParseError)
+		c.Assert(ok, jc.IsTrue, gc.Commentf("got %#v", verr.Errors[i]))
+		c.Assert(perr.Path, gc.Equals, expect.path)
+		c.Assert(perr.Position, gc.Equals, expect.position)
+		c.Assert(perr, gc.ErrorMatches, `bundle: unknown key "`+expect.path+`"`)
+	}
+}
+
+func (s *ReadOptionsSuite) TestReadBundleDataWithOptionsFixture(c *gc.C) {
+	data, err := ioutil.ReadFile(filepath.Join(bundleDirPath(c, "openstack"), "bundle.yaml"))
+	c.Assert(err, jc.ErrorIsNil)
+	// The fixture uses an old series and has a stray
+	// quote in one of its keys.
+	_, warnings, err := charm.ReadBundleDataWithOptions(strings.NewReader(string(data)), charm.ReadOptions{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(warnings, jc.DeepEquals, []charm.ReadWarning{{
+		Position: charm.Position{Line: 1, Column: 1},
+		Path:     "series",
+		Message:  `series "precise" reached end of life on 2017-04-28`,
+	}, {
+		Position: charm.Position{Line: 52, Column: 5},
+		Path:     `applications.cinder.constraints"`,
+		Message:  `unknown key "applications.cinder.constraints\""`,
+	}})
+	_, _, err = charm.ReadBundleDataWithOptions(strings.NewReader(string(data)), charm.ReadOptions{Strict: true})
+	c.Assert(err, gc.ErrorMatches, `bundle: unknown key "applications.cinder.constraints\\""`)
+}
@@ -0,0 +1,245 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// RevisionConstraint restricts the revisions of a charm or bundle
+// that a Reference may resolve to.
+//
+// Note that the zero RevisionConstraint only allows revision 0;
+// use AnyRevision to allow any revision.
+type RevisionConstraint struct {
+	// Min holds the lowest acceptable revision.
+	Min int
+
+	// Max holds the highest acceptable revision,
+	// or -1 if there is no upper bound.
+	Max int
+}
+
+// AnyRevision allows any revision, so that the
+// latest available revision is chosen.
+var AnyRevision = RevisionConstraint{Min: 0, Max: -1}
+
+// PinnedRevision returns a constraint that only
+// allows the given revision.
+func PinnedRevision(revision int) RevisionConstraint {
+	return RevisionConstraint{Min: revision, Max: revision}
+}
+
+// ParseRevisionConstraint parses a revision constraint, which
+// takes one of the following forms:
+//
+//	latest   any revision
+//	42       revision 42 only
+//	>=20     revision 20 or later
+//	<=29     revision 29 or earlier
+//	20-29    revisions 20 to 29 inclusive
+//
+// The characters "≥", "≤" and "–" may be used in place of
+// ">=", "<=" and "-" respectively.
+func ParseRevisionConstraint(str string) (RevisionConstraint, error) {
+	s := strings.NewReplacer("≥", ">=", "≤", "<=", "–", "-").Replace(strings.TrimSpace(str))
+	var c RevisionConstraint
+	var err error
+	switch {
+	case s == "latest":
+		return AnyRevision, nil
+	case strings.HasPrefix(s, ">="):
+		c.Min, err = parseRevision(s[2:])
+		c.Max = -1
+	case strings.HasPrefix(s, "<="):
+		c.Max, err = parseRevision(s[2:])
+	case strings.Contains(s, "-"):
+		i := strings.Index(s, "-")
+		c.Min, err = parseRevision(s[:i])
+		if err == nil {
+			c.Max, err = parseRevision(s[i+1:])
+		}
+		if err == nil && c.Max < c.Min {
+			err = errors.Errorf("empty revision range")
+		}
+	default:
+		c.Min, err = parseRevision(s)
+		c.Max = c.Min
+	}
+	if err != nil {
+		return RevisionConstraint{}, errors.Annotatef(err, "invalid revision constraint %q", str)
+	}
+	return c, nil
+}
+
+func parseRevision(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	rev, err := strconv.Atoi(s)
+	if err != nil || rev < 0 || strings.HasPrefix(s, "+") {
+		return 0, errors.Errorf("invalid revision %q", s)
+	}
+	return rev, nil
+}
+
+// Allows reports whether the constraint allows the given revision.
+func (c RevisionConstraint) Allows(revision int) bool {
+	return revision >= c.Min && (c.Max < 0 || revision <= c.Max)
+}
+
+// String returns the constraint in the form
+// parsed by ParseRevisionConstraint.
+func (c RevisionConstraint) String() string {
+	switch {
+	case c.Max < 0 && c.Min <= 0:
+		return "latest"
+	case c.Max < 0:
+		return fmt.Sprintf(">=%d", c.Min)
+	case c.Min == c.Max:
+		return strconv.Itoa(c.Min)
+	case c.Min <= 0:
+		return fmt.Sprintf("<=%d", c.Max)
+	}
+	return fmt.Sprintf("%d-%d", c.Min, c.Max)
+}
+
+// Reference refers to a charm or bundle by URL, allowing
+// any revision that satisfies a constraint.
+type Reference struct {
+	// URL holds the charm or bundle URL, without a revision.
+	URL *URL
+
+	// Revisions holds the revisions that are acceptable.
+	Revisions RevisionConstraint
+}
+
+// ParseReference parses a reference, written as a charm URL optionally
+// followed by "@" and a revision constraint in the form accepted by
+// ParseRevisionConstraint, for example "cs:xenial/wordpress@>=20". A
+// URL with a revision, such as "cs:xenial/wordpress-42", is pinned to
+// that revision, and a URL without one refers to the latest revision.
+func ParseReference(s string) (*Reference, error) {
+	urlStr, constraint := s, ""
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		urlStr, constraint = s[:i], s[i+1:]
+	}
+	url, err := ParseURL(urlStr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	ref := &Reference{
+		URL:       url.WithRevision(-1),
+		Revisions: AnyRevision,
+	}
+	switch {
+	case constraint != "" && url.Revision >= 0:
+		return nil, errors.Errorf("charm or bundle reference %q has both a revision and a revision constraint", s)
+	case constraint != "":
+		ref.Revisions, err = ParseRevisionConstraint(constraint)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	case url.Revision >= 0:
+		ref.Revisions = PinnedRevision(url.Revision)
+	}
+	return ref, nil
+}
+
+// MustParseReference works like ParseReference, but panics in case of errors.
+func MustParseReference(s string) *Reference {
+	ref, err := ParseReference(s)
+	if err != nil {
+		panic(err)
+	}
+	return ref
+}
+
+// String returns the reference in the form parsed by ParseReference.
+// A reference pinned to a revision is written as a URL with that
+// revision, and a reference to the latest revision as a URL alone.
+func (r *Reference) String() string {
+	switch c := r.Revisions; {
+	case c == AnyRevision:
+		return r.URL.WithRevision(-1).String()
+	case c.Min == c.Max:
+		return r.URL.WithRevision(c.Min).String()
+	}
+	return r.URL.WithRevision(-1).String() + "@" + r.Revisions.String()
+}
+
+// Resolve returns the URL in available that best satisfies the
+// reference: the one with the highest revision of those that match.
+// An available URL matches if it has the same schema, user and name
+// as the reference, the same series and channel when the reference
+// specifies them, and a revision that satisfies the constraint. When
+// several matching URLs share the highest revision, the first is
+// returned.
+//
+// If no URL matches, Resolve returns a *ResolveError explaining
+// why each available URL was rejected.
+func (r *Reference) Resolve(available []*URL) (*URL, error) {
+	var best *URL
+	var rejected []RejectedURL
+	for _, u := range available {
+		if reason := r.mismatch(u); reason != "" {
+			rejected = append(rejected, RejectedURL{URL: u, Reason: reason})
+			continue
+		}
+		if best == nil || u.Revision > best.Revision {
+			best = u
+		}
+	}
+	if best == nil {
+		return nil, &ResolveError{Reference: r, Rejected: rejected}
+	}
+	return best, nil
+}
+
+// mismatch returns why u does not satisfy the reference,
+// or the empty string if it does.
+func (r *Reference) mismatch(u *URL) string {
+	switch {
+	case u.Schema != r.URL.Schema || u.User != r.URL.User || u.Name != r.URL.Name:
+		return "different charm or bundle"
+	case r.URL.Series != "" && u.Series != r.URL.Series:
+		return fmt.Sprintf("series %q does not match %q", u.Series, r.URL.Series)
+	case r.URL.Channel != (Channel{}) && u.Channel.Normalize() != r.URL.Channel.Normalize():
+		return fmt.Sprintf("channel %q does not match %q", u.Channel.Normalize(), r.URL.Channel.Normalize())
+	case u.Revision < 0:
+		return "no revision"
+	case !r.Revisions.Allows(u.Revision):
+		return fmt.Sprintf("revision %d does not satisfy %s", u.Revision, r.Revisions)
+	}
+	return ""
+}
+
+// RejectedURL records why a URL was not chosen by Reference.Resolve.
+type RejectedURL struct {
+	URL    *URL
+	Reason string
+}
+
+// ResolveError is returned by Reference.Resolve when none of
+// the available URLs satisfies the reference.
+type ResolveError struct {
+	Reference *Reference
+
+	// Rejected holds the available URLs,
+	// each with the reason it was rejected.
+	Rejected []RejectedURL
+}
+
+func (e *ResolveError) Error() string {
+	if len(e.Rejected) == 0 {
+		return fmt.Sprintf("cannot resolve %q: no charms or bundles available", e.Reference)
+	}
+	reasons := make([]string, len(e.Rejected))
+	for i, r := range e.Rejected {
+		reasons[i] = fmt.Sprintf("%s (%s)", r.URL, r.Reason)
+	}
+	return fmt.Sprintf("cannot resolve %q: %s", e.Reference, strings.Join(reasons, ", "))
+}
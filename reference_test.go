@@ -0,0 +1,240 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type ReferenceSuite struct{}
+
+var _ = gc.Suite(&ReferenceSuite{})
+
+var parseRevisionConstraintTests = []struct {
+	s      string
+	expect charm.RevisionConstraint
+	str    string
+	err    string
+}{{
+	s:      "latest",
+	expect: charm.AnyRevision,
+}, {
+	s:      "42",
+	expect: charm.PinnedRevision(42),
+}, {
+	s:      ">=20",
+	expect: charm.RevisionConstraint{Min: 20, Max: -1},
+}, {
+	s:      "≥ 20",
+	expect: charm.RevisionConstraint{Min: 20, Max: -1},
+	str:    ">=20",
+}, {
+	s:      "<=29",
+	expect: charm.RevisionConstraint{Min: 0, Max: 29},
+}, {
+	s:      "20-29",
+	expect: charm.RevisionConstraint{Min: 20, Max: 29},
+}, {
+	s:      "20–29",
+	expect: charm.RevisionConstraint{Min: 20, Max: 29},
+	str:    "20-29",
+}, {
+	s:   "",
+	err: `invalid revision constraint "": invalid revision ""`,
+}, {
+	s:   "newest",
+	err: `invalid revision constraint "newest": invalid revision "newest"`,
+}, {
+	s:   ">=-1",
+	err: `invalid revision constraint ">=-1": invalid revision "-1"`,
+}, {
+	s:   "29-20",
+	err: `invalid revision constraint "29-20": empty revision range`,
+}, {
+	s:   "20-",
+	err: `invalid revision constraint "20-": invalid revision ""`,
+}}
+
+func (s *ReferenceSuite) TestParseRevisionConstraint(c *gc.C) {
+	for i, test := range parseRevisionConstraintTests {
+		c.Logf("test %d: %q", i, test.s)
+		rc, err := charm.ParseRevisionConstraint(test.s)
+		if test.err != "" {
+			c.Assert(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(rc, gc.Equals, test.expect)
+		str := test.str
+		if str == "" {
+			str = test.s
+		}
+		c.Assert(rc.String(), gc.Equals, str)
+	}
+}
+
+func (s *ReferenceSuite) TestAllows(c *gc.C) {
+	rc := charm.RevisionConstraint{Min: 20, Max: 29}
+	c.Assert(rc.Allows(19), jc.IsFalse)
+	c.Assert(rc.Allows(20), jc.IsTrue)
+	c.Assert(rc.Allows(29), jc.IsTrue)
+	c.Assert(rc.Allows(30), jc.IsFalse)
+	c.Assert(charm.AnyRevision.Allows(0), jc.IsTrue)
+	c.Assert(charm.AnyRevision.Allows(1000), jc.IsTrue)
+}
+
+var parseReferenceTests = []struct {
+	s         string
+	url       string
+	revisions charm.RevisionConstraint
+	str       string
+	err       string
+}{{
+	s:         "cs:xenial/wordpress",
+	url:       "cs:xenial/wordpress",
+	revisions: charm.AnyRevision,
+}, {
+	s:         "cs:xenial/wordpress-42",
+	url:       "cs:xenial/wordpress",
+	revisions: charm.PinnedRevision(42),
+}, {
+	s:         "wordpress@>=20",
+	url:       "cs:wordpress",
+	revisions: charm.RevisionConstraint{Min: 20, Max: -1},
+	str:       "cs:wordpress@>=20",
+}, {
+	s:         "cs:~joe/edge/wordpress@20-29",
+	url:       "cs:~joe/edge/wordpress",
+	revisions: charm.RevisionConstraint{Min: 20, Max: 29},
+}, {
+	s:         "cs:wordpress@latest",
+	url:       "cs:wordpress",
+	revisions: charm.AnyRevision,
+	str:       "cs:wordpress",
+}, {
+	s:         "cs:wordpress@42",
+	url:       "cs:wordpress",
+	revisions: charm.PinnedRevision(42),
+	str:       "cs:wordpress-42",
+}, {
+	s:   "cs:wordpress-42@>=20",
+	err: `charm or bundle reference "cs:wordpress-42@>=20" has both a revision and a revision constraint`,
+}, {
+	s:   "cs:wordpress@soon",
+	err: `invalid revision constraint "soon": invalid revision "soon"`,
+}, {
+	s:   "bs:wordpress@20",
+	err: `cannot parse URL "bs:wordpress": schema "bs" not valid`,
+}}
+
+func (s *ReferenceSuite) TestParseReference(c *gc.C) {
+	for i, test := range parseReferenceTests {
+		c.Logf("test %d: %q", i, test.s)
+		ref, err := charm.ParseReference(test.s)
+		if test.err != "" {
+			c.Assert(err, gc.ErrorMatches, test.err)
+			c.Assert(func() { charm.MustParseReference(test.s) }, gc.PanicMatches, test.err)
+			continue
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(ref.URL, jc.DeepEquals, charm.MustParseURL(test.url))
+		c.Assert(ref.Revisions, gc.Equals, test.revisions)
+		str := test.str
+		if str == "" {
+			str = test.s
+		}
+		c.Assert(ref.String(), gc.Equals, str)
+	}
+}
+
+func urls(ss ...string) []*charm.URL {
+	result := make([]*charm.URL, len(ss))
+	for i, s := range ss {
+		result[i] = charm.MustParseURL(s)
+	}
+	return result
+}
+
+var resolveTests = []struct {
+	about     string
+	ref       string
+	available []*charm.URL
+	expect    string
+	err       string
+}{{
+	about:     "latest",
+	ref:       "cs:xenial/wordpress",
+	available: urls("cs:xenial/wordpress-3", "cs:xenial/wordpress-12", "cs:xenial/wordpress-7"),
+	expect:    "cs:xenial/wordpress-12",
+}, {
+	about:     "minimum revision",
+	ref:       "cs:xenial/wordpress@>=20",
+	available: urls("cs:xenial/wordpress-12", "cs:xenial/wordpress-31", "cs:xenial/wordpress-20"),
+	expect:    "cs:xenial/wordpress-31",
+}, {
+	about:     "revision range",
+	ref:       "cs:xenial/wordpress@20-29",
+	available: urls("cs:xenial/wordpress-12", "cs:xenial/wordpress-31", "cs:xenial/wordpress-25", "cs:xenial/wordpress-20"),
+	expect:    "cs:xenial/wordpress-25",
+}, {
+	about:     "pinned",
+	ref:       "cs:xenial/wordpress-42",
+	available: urls("cs:xenial/wordpress-43", "cs:xenial/wordpress-42"),
+	expect:    "cs:xenial/wordpress-42",
+}, {
+	about:     "any series",
+	ref:       "cs:wordpress",
+	available: urls("cs:trusty/wordpress-5", "cs:xenial/wordpress-8", "cs:bionic/wordpress-8"),
+	expect:    "cs:xenial/wordpress-8",
+}, {
+	about:     "channel",
+	ref:       "cs:edge/wordpress",
+	available: urls("cs:wordpress-9", "cs:stable/wordpress-10", "cs:edge/wordpress-8"),
+	expect:    "cs:edge/wordpress-8",
+}, {
+	about:     "stable channel is the default",
+	ref:       "cs:stable/wordpress",
+	available: urls("cs:wordpress-9", "cs:edge/wordpress-10"),
+	expect:    "cs:wordpress-9",
+}, {
+	about: "nothing matches",
+	ref:   "cs:stable/xenial/wordpress@20-29",
+	available: urls(
+		"cs:stable/xenial/wordpress-12",
+		"cs:trusty/wordpress-25",
+		"cs:~joe/xenial/wordpress-25",
+		"cs:edge/xenial/wordpress-25",
+		"cs:stable/xenial/wordpress",
+	),
+	err: `cannot resolve "cs:stable/xenial/wordpress@20-29": ` +
+		`cs:stable/xenial/wordpress-12 \(revision 12 does not satisfy 20-29\), ` +
+		`cs:trusty/wordpress-25 \(series "trusty" does not match "xenial"\), ` +
+		`cs:~joe/xenial/wordpress-25 \(different charm or bundle\), ` +
+		`cs:edge/xenial/wordpress-25 \(channel "edge" does not match "stable"\), ` +
+		`cs:stable/xenial/wordpress \(no revision\)`,
+}, {
+	about: "nothing available",
+	ref:   "cs:wordpress",
+	err:   `cannot resolve "cs:wordpress": no charms or bundles available`,
+}}
+
+func (s *ReferenceSuite) TestResolve(c *gc.C) {
+	for i, test := range resolveTests {
+		c.Logf("test %d: %s", i, test.about)
+		ref := charm.MustParseReference(test.ref)
+		url, err := ref.Resolve(test.available)
+		if test.err != "" {
+			c.Assert(err, gc.ErrorMatches, test.err)
+			rerr, ok := err.(*charm.ResolveError)
+			c.Assert(ok, jc.IsTrue)
+			c.Assert(rerr.Rejected, gc.HasLen, len(test.available))
+			continue
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(url.String(), gc.Equals, test.expect)
+	}
+}
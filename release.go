@@ -0,0 +1,188 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Release describes a release of an operating system
+// and the series name that denotes it.
+type Release struct {
+	// Series holds the series name, for example "xenial".
+	Series string
+
+	// OS holds the name of the operating system, for example "ubuntu".
+	OS string
+
+	// Version holds the version of the operating system,
+	// for example "16.04".
+	Version string
+
+	// LTS holds whether the release has long term support.
+	LTS bool
+
+	// Released holds the date of the release.
+	Released time.Time
+
+	// EOL holds the date on which the release reaches
+	// end of life. It is zero if that is not known.
+	EOL time.Time
+}
+
+// IsEOL reports whether the release has reached
+// end of life at the given time.
+func (r Release) IsEOL(t time.Time) bool {
+	return !r.EOL.IsZero() && !t.Before(r.EOL)
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+var (
+	releasesMu sync.RWMutex
+
+	// releases holds the known releases in the order they were
+	// released. It may be extended with RegisterRelease.
+	releases = []Release{
+		{"precise", "ubuntu", "12.04", true, date(2012, 4, 26), date(2017, 4, 28)},
+		{"quantal", "ubuntu", "12.10", false, date(2012, 10, 18), date(2014, 5, 16)},
+		{"raring", "ubuntu", "13.04", false, date(2013, 4, 25), date(2014, 1, 27)},
+		{"saucy", "ubuntu", "13.10", false, date(2013, 10, 17), date(2014, 7, 17)},
+		{"trusty", "ubuntu", "14.04", true, date(2014, 4, 17), date(2019, 4, 25)},
+		{"centos7", "centos", "7", false, date(2014, 7, 7), date(2024, 6, 30)},
+		{"utopic", "ubuntu", "14.10", false, date(2014, 10, 23), date(2015, 7, 23)},
+		{"vivid", "ubuntu", "15.04", false, date(2015, 4, 23), date(2016, 2, 4)},
+		{"wily", "ubuntu", "15.10", false, date(2015, 10, 22), date(2016, 7, 28)},
+		{"xenial", "ubuntu", "16.04", true, date(2016, 4, 21), date(2021, 4, 30)},
+		{"yakkety", "ubuntu", "16.10", false, date(2016, 10, 13), date(2017, 7, 20)},
+		{"zesty", "ubuntu", "17.04", false, date(2017, 4, 13), date(2018, 1, 13)},
+		{"artful", "ubuntu", "17.10", false, date(2017, 10, 19), date(2018, 7, 19)},
+		{"bionic", "ubuntu", "18.04", true, date(2018, 4, 26), date(2023, 5, 31)},
+		{"cosmic", "ubuntu", "18.10", false, date(2018, 10, 18), date(2019, 7, 18)},
+		{"disco", "ubuntu", "19.04", false, date(2019, 4, 18), date(2020, 1, 23)},
+		{"centos8", "centos", "8", false, date(2019, 9, 24), date(2021, 12, 31)},
+		{"eoan", "ubuntu", "19.10", false, date(2019, 10, 17), date(2020, 7, 17)},
+		{"focal", "ubuntu", "20.04", true, date(2020, 4, 23), date(2025, 5, 29)},
+		{"groovy", "ubuntu", "20.10", false, date(2020, 10, 22), date(2021, 7, 22)},
+		{"hirsute", "ubuntu", "21.04", false, date(2021, 4, 22), date(2022, 1, 20)},
+		{"impish", "ubuntu", "21.10", false, date(2021, 10, 14), date(2022, 7, 14)},
+		{"jammy", "ubuntu", "22.04", true, date(2022, 4, 21), date(2027, 6, 1)},
+		{"kinetic", "ubuntu", "22.10", false, date(2022, 10, 20), date(2023, 7, 20)},
+		{"lunar", "ubuntu", "23.04", false, date(2023, 4, 20), date(2024, 1, 25)},
+		{"mantic", "ubuntu", "23.10", false, date(2023, 10, 12), date(2024, 7, 11)},
+		{"noble", "ubuntu", "24.04", true, date(2024, 4, 25), date(2029, 5, 31)},
+		{"oracular", "ubuntu", "24.10", false, date(2024, 10, 10), date(2025, 7, 10)},
+		{"plucky", "ubuntu", "25.04", false, date(2025, 4, 17), date(2026, 1, 15)},
+	}
+)
+
+// timeNow is used to judge whether releases
+// have reached end of life.
+var timeNow = time.Now
+
+// Releases returns all the known releases,
+// in the order they were released.
+func Releases() []Release {
+	releasesMu.RLock()
+	defer releasesMu.RUnlock()
+	return append([]Release(nil), releases...)
+}
+
+// ReleaseForSeries returns the release denoted by the given
+// series name, and reports whether it is known.
+func ReleaseForSeries(series string) (Release, bool) {
+	releasesMu.RLock()
+	defer releasesMu.RUnlock()
+	for _, r := range releases {
+		if r.Series == series {
+			return r, true
+		}
+	}
+	return Release{}, false
+}
+
+// RegisterRelease adds a release to the known releases, replacing
+// any release with the same series name. It allows releases made
+// after this package was built to be recognised.
+func RegisterRelease(r Release) error {
+	if !IsValidSeries(r.Series) {
+		return fmt.Errorf("invalid series %q", r.Series)
+	}
+	if !validBaseName.MatchString(r.OS) {
+		return fmt.Errorf("invalid OS %q for series %q", r.OS, r.Series)
+	}
+	if r.Version == "" {
+		return fmt.Errorf("no version for series %q", r.Series)
+	}
+	releasesMu.Lock()
+	defer releasesMu.Unlock()
+	rs := make([]Release, 0, len(releases)+1)
+	for _, old := range releases {
+		if old.Series != r.Series {
+			rs = append(rs, old)
+		}
+	}
+	rs = append(rs, r)
+	sort.Stable(byReleased(rs))
+	releases = rs
+	return nil
+}
+
+type byReleased []Release
+
+func (rs byReleased) Len() int           { return len(rs) }
+func (rs byReleased) Swap(i, j int)      { rs[i], rs[j] = rs[j], rs[i] }
+func (rs byReleased) Less(i, j int) bool { return rs[i].Released.Before(rs[j].Released) }
+
+// CheckSeries checks the given series against the known releases.
+// It returns an error satisfying IsUnknownSeriesError if the series
+// is not known, or one satisfying IsEOLSeriesError if its release has
+// reached end of life at the given time.
+func CheckSeries(series string, t time.Time) error {
+	r, ok := ReleaseForSeries(series)
+	if !ok {
+		return &unknownSeriesError{series}
+	}
+	if r.IsEOL(t) {
+		return &eolSeriesError{r}
+	}
+	return nil
+}
+
+// unknownSeriesError represents an error indicating
+// that a series is not in the known releases.
+type unknownSeriesError struct {
+	series string
+}
+
+func (e *unknownSeriesError) Error() string {
+	return fmt.Sprintf("unknown series %q", e.series)
+}
+
+// IsUnknownSeriesError returns true if err is an unknownSeriesError.
+func IsUnknownSeriesError(err error) bool {
+	_, ok := err.(*unknownSeriesError)
+	return ok
+}
+
+// eolSeriesError represents an error indicating that the
+// release denoted by a series has reached end of life.
+type eolSeriesError struct {
+	release Release
+}
+
+func (e *eolSeriesError) Error() string {
+	return fmt.Sprintf("series %q reached end of life on %s", e.release.Series, e.release.EOL.Format("2006-01-02"))
+}
+
+// IsEOLSeriesError returns true if err is an eolSeriesError.
+func IsEOLSeriesError(err error) bool {
+	_, ok := err.(*eolSeriesError)
+	return ok
+}
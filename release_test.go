@@ -0,0 +1,166 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"strings"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type ReleaseSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&ReleaseSuite{})
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *ReleaseSuite) TestReleaseForSeries(c *gc.C) {
+	r, ok := charm.ReleaseForSeries("xenial")
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(r, jc.DeepEquals, charm.Release{
+		Series:   "xenial",
+		OS:       "ubuntu",
+		Version:  "16.04",
+		LTS:      true,
+		Released: date(2016, 4, 21),
+		EOL:      date(2021, 4, 30),
+	})
+	_, ok = charm.ReleaseForSeries("xenail")
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (s *ReleaseSuite) TestReleasesInReleaseOrder(c *gc.C) {
+	releases := charm.Releases()
+	c.Assert(releases, gc.Not(gc.HasLen), 0)
+	for i := 1; i < len(releases); i++ {
+		c.Check(releases[i].Released.Before(releases[i-1].Released), jc.IsFalse, gc.Commentf("release %q", releases[i].Series))
+	}
+	// The result is a copy.
+	releases[0].Series = "changed"
+	c.Assert(charm.Releases()[0].Series, gc.Equals, "precise")
+}
+
+func (s *ReleaseSuite) TestIsEOL(c *gc.C) {
+	r, _ := charm.ReleaseForSeries("xenial")
+	c.Assert(r.IsEOL(date(2021, 4, 29)), jc.IsFalse)
+	c.Assert(r.IsEOL(date(2021, 4, 30)), jc.IsTrue)
+	c.Assert(charm.Release{Series: "future"}.IsEOL(date(2100, 1, 1)), jc.IsFalse)
+}
+
+func (s *ReleaseSuite) TestCheckSeries(c *gc.C) {
+	now := date(2020, 1, 1)
+	c.Assert(charm.CheckSeries("bionic", now), jc.ErrorIsNil)
+
+	err := charm.CheckSeries("trusty", now)
+	c.Assert(err, gc.ErrorMatches, `series "trusty" reached end of life on 2019-04-25`)
+	c.Assert(charm.IsEOLSeriesError(err), jc.IsTrue)
+	c.Assert(charm.IsUnknownSeriesError(err), jc.IsFalse)
+
+	err = charm.CheckSeries("plan9", now)
+	c.Assert(err, gc.ErrorMatches, `unknown series "plan9"`)
+	c.Assert(charm.IsUnknownSeriesError(err), jc.IsTrue)
+	c.Assert(charm.IsEOLSeriesError(err), jc.IsFalse)
+}
+
+func (s *ReleaseSuite) TestRegisterRelease(c *gc.C) {
+	s.PatchValue(charm.KnownReleases, charm.Releases())
+	err := charm.RegisterRelease(charm.Release{
+		Series:   "futuristic",
+		OS:       "ubuntu",
+		Version:  "99.04",
+		LTS:      true,
+		Released: date(2099, 4, 1),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	releases := charm.Releases()
+	c.Assert(releases[len(releases)-1].Series, gc.Equals, "futuristic")
+	_, err = charm.ReadMeta(strings.NewReader(dummyMetadata + "\nseries: [futuristic]\n"))
+	c.Assert(err, jc.ErrorIsNil)
+	b, err := charm.BaseForSeries("futuristic")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(b, jc.DeepEquals, charm.MustParseBase("ubuntu@99.04"))
+
+	// Registering a known series replaces it.
+	err = charm.RegisterRelease(charm.Release{
+		Series:   "precise",
+		OS:       "ubuntu",
+		Version:  "12.04",
+		Released: date(2012, 4, 26),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(charm.Releases(), gc.HasLen, len(releases))
+	r, _ := charm.ReleaseForSeries("precise")
+	c.Assert(r.LTS, jc.IsFalse)
+}
+
+func (s *ReleaseSuite) TestSeriesForCharmPreferringLTS(c *gc.C) {
+	s.PatchValue(charm.TimeNow, func() time.Time {
+		return date(2020, 6, 1)
+	})
+	tests := []struct {
+		series          string
+		supportedSeries []string
+		seriesToUse     string
+		err             string
+	}{{
+		series: "",
+		err:    "series not specified and charm does not define any",
+	}, {
+		series:          "trusty",
+		supportedSeries: []string{"trusty", "xenial"},
+		seriesToUse:     "trusty",
+	}, {
+		series:          "",
+		supportedSeries: []string{"trusty", "eoan", "bionic", "xenial"},
+		seriesToUse:     "bionic",
+	}, {
+		series:          "",
+		supportedSeries: []string{"eoan", "trusty", "centos7"},
+		seriesToUse:     "eoan",
+	}, {
+		series:          "wily",
+		supportedSeries: []string{"xenial"},
+		err:             `series "wily" not supported by charm.*`,
+	}}
+	for i, test := range tests {
+		c.Logf("test %d: %q %v", i, test.series, test.supportedSeries)
+		series, err := charm.SeriesForCharmPreferringLTS(test.series, test.supportedSeries)
+		if test.err != "" {
+			c.Assert(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(series, gc.Equals, test.seriesToUse)
+	}
+}
+
+var registerReleaseErrorTests = []struct {
+	release charm.Release
+	err     string
+}{{
+	release: charm.Release{Series: "Bad", OS: "ubuntu", Version: "1"},
+	err:     `invalid series "Bad"`,
+}, {
+	release: charm.Release{Series: "good", OS: "Ubuntu", Version: "1"},
+	err:     `invalid OS "Ubuntu" for series "good"`,
+}, {
+	release: charm.Release{Series: "good", OS: "ubuntu"},
+	err:     `no version for series "good"`,
+}}
+
+func (s *ReleaseSuite) TestRegisterReleaseErrors(c *gc.C) {
+	for i, test := range registerReleaseErrorTests {
+		c.Logf("test %d: %s", i, test.err)
+		c.Assert(charm.RegisterRelease(test.release), gc.ErrorMatches, test.err)
+	}
+}
@@ -16,7 +16,7 @@ type Meta struct {
 	// Name identifies the resource.
 	Name string
 
-	// Type identifies the type of resource (e.g. "file").
+	// Type identifies the type of resource (e.g. "file" or "oci-image").
 	Type Type
 
 	// TODO(ericsnow) Rename Path to Filename?
@@ -28,6 +28,8 @@ type Meta struct {
 	// path "eggs.tgz", the fully resolved storage path for the resource
 	// would be:
 	//   /var/lib/juju/agent/spam-0/resources/eggs/eggs.tgz
+	//
+	// Path is only required for file resources.
 	Path string
 
 	// Description holds optional user-facing info for the resource.
@@ -49,11 +51,11 @@ func (meta Meta) Validate() error {
 		return errors.NewNotValid(nil, msg)
 	}
 
-	if meta.Path == "" {
-		// TODO(ericsnow) change "filename" to "path"
-		return errors.NewNotValid(nil, "resource missing filename")
-	}
 	if meta.Type == TypeFile {
+		if meta.Path == "" {
+			// TODO(ericsnow) change "filename" to "path"
+			return errors.NewNotValid(nil, "resource missing filename")
+		}
 		if strings.Contains(meta.Path, "/") {
 			msg := fmt.Sprintf(`filename cannot contain "/" (got %q)`, meta.Path)
 			return errors.NewNotValid(nil, msg)
@@ -25,6 +25,7 @@ func (s *TypeSuite) TestParseTypeOkay(c *gc.C) {
 func (s *TypeSuite) TestParseTypeRecognized(c *gc.C) {
 	supported := []resource.Type{
 		resource.TypeFile,
+		resource.TypeContainerImage,
 	}
 	for _, expected := range supported {
 		rt, err := resource.ParseType(expected.String())
@@ -52,7 +53,8 @@ func (s *TypeSuite) TestParseTypeUnsupported(c *gc.C) {
 
 func (s *TypeSuite) TestTypeStringSupported(c *gc.C) {
 	supported := map[resource.Type]string{
-		resource.TypeFile: "file",
+		resource.TypeFile:           "file",
+		resource.TypeContainerImage: "oci-image",
 	}
 	for rt, expected := range supported {
 		str := rt.String()
@@ -71,6 +73,7 @@ func (s *TypeSuite) TestTypeStringUnknown(c *gc.C) {
 func (s *TypeSuite) TestTypeValidateSupported(c *gc.C) {
 	supported := []resource.Type{
 		resource.TypeFile,
+		resource.TypeContainerImage,
 	}
 	for _, rt := range supported {
 		err := rt.Validate()
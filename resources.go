@@ -4,7 +4,7 @@
 package charm
 
 import (
-	"fmt"
+	"sort"
 
 	"github.com/juju/errors"
 	"github.com/juju/schema"
@@ -12,14 +12,15 @@ import (
 	"gopkg.in/juju/charm.v6/resource"
 )
 
-var resourceSchema = schema.FieldMap(
+var resourceSchema = collectingFieldMap(
 	schema.Fields{
-		"type":        schema.String(),
-		"filename":    schema.String(), // TODO(ericsnow) Change to "path"?
-		"description": schema.String(),
+		"type":        describedC{schema.String(), map[string]interface{}{"enum": resourceTypes}},
+		"filename":    stringSchema(), // TODO(ericsnow) Change to "path"?
+		"description": stringSchema(),
 	},
 	schema.Defaults{
 		"type":        resource.TypeFile.String(),
+		"filename":    schema.Omit, // Only required for file resources.
 		"description": "",
 	},
 )
@@ -29,28 +30,40 @@ func parseMetaResources(data interface{}) (map[string]resource.Meta, error) {
 		return nil, nil
 	}
 
+	var errs metaErrors
 	result := make(map[string]resource.Meta)
-	for name, val := range data.(map[string]interface{}) {
-		meta, err := parseResourceMeta(name, val)
+	resources := data.(map[string]interface{})
+	for _, name := range sortedKeys(resources) {
+		meta, err := parseResourceMeta(name, resources[name])
 		if err != nil {
-			return nil, err
+			errs.add("resources."+name, err)
+			continue
 		}
 		result[name] = meta
 	}
 
-	return result, nil
+	return result, errs.err()
 }
 
 func validateMetaResources(resources map[string]resource.Meta) error {
-	for name, res := range resources {
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs metaErrors
+	for _, name := range names {
+		res := resources[name]
 		if res.Name != name {
-			return fmt.Errorf("mismatch on resource name (%q != %q)", res.Name, name)
+			errs.addf("resources."+name, "mismatch on resource name (%q != %q)", res.Name, name)
+			continue
 		}
 		if err := res.Validate(); err != nil {
-			return err
+			errs.add("resources."+name, err)
 		}
 	}
-	return nil
+	return errs.err()
 }
 
 // parseResourceMeta parses the provided data into a Meta, assuming
@@ -64,12 +77,15 @@ func parseResourceMeta(name string, data interface{}) (resource.Meta, error) {
 		return meta, nil
 	}
 	rMap := data.(map[string]interface{})
+	path := "resources." + name
 
 	if val := rMap["type"]; val != nil {
 		var err error
 		meta.Type, err = resource.ParseType(val.(string))
 		if err != nil {
-			return meta, errors.Trace(err)
+			var errs metaErrors
+			errs.add(path+".type", errors.Trace(err))
+			return meta, errs.err()
 		}
 	}
 
@@ -4,6 +4,8 @@
 package charm_test
 
 import (
+	"strings"
+
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 
@@ -66,9 +68,27 @@ func (s *resourceSuite) TestSchemaMissingPath(c *gc.C) {
 		"type":        "file",
 		"description": "One line that is useful when operators need to push it.",
 	}
-	_, err := charm.ResourceSchema.Coerce(raw, nil)
+	v, err := charm.ResourceSchema.Coerce(raw, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The filename is only required for file resources,
+	// which is checked when the resource is validated.
+	c.Check(v, jc.DeepEquals, map[string]interface{}{
+		"type":        "file",
+		"description": "One line that is useful when operators need to push it.",
+	})
+}
 
-	c.Check(err, gc.NotNil)
+func (s *resourceSuite) TestReadMetaFileResourceMissingPath(c *gc.C) {
+	_, err := charm.ReadMeta(strings.NewReader(`
+name: a
+summary: b
+description: c
+resources:
+    blob:
+        type: file
+`))
+	c.Check(err, gc.ErrorMatches, `resources.blob: resource missing filename`)
 }
 
 func (s *resourceSuite) TestSchemaMissingComment(c *gc.C) {
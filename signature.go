@@ -0,0 +1,332 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+)
+
+// SignatureFileName holds the name of the archive entry that holds
+// an embedded signature. As a hidden file, it is never archived
+// from a charm or bundle directory.
+const SignatureFileName = ".signature"
+
+// signaturePrefix is signed along with the content digest of an
+// archive, so that a signature cannot be taken for one of
+// something else made with the same key.
+const signaturePrefix = "juju-charm-archive-signature-v1\n"
+
+// Signature holds an ed25519 signature of the content digest
+// of a charm or bundle archive.
+type Signature struct {
+	// KeyId identifies the key that made the signature,
+	// as returned by KeyId.
+	KeyId string `json:"key-id"`
+
+	// Signature holds the signature itself.
+	Signature []byte `json:"signature"`
+}
+
+// ReadSignature reads a signature in the JSON
+// form that it is marshaled into.
+func ReadSignature(r io.Reader) (*Signature, error) {
+	var sig Signature
+	if err := json.NewDecoder(r).Decode(&sig); err != nil {
+		return nil, fmt.Errorf("cannot read signature: %v", err)
+	}
+	if sig.KeyId == "" || len(sig.Signature) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("cannot read signature: signature is malformed")
+	}
+	return &sig, nil
+}
+
+// KeyId returns the identifier of the given public key,
+// which is derived from the key itself.
+func KeyId(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Keyring holds the public keys that are trusted
+// to sign archives, keyed by their ids.
+type Keyring map[string]ed25519.PublicKey
+
+// NewKeyring returns a keyring that trusts the given keys.
+func NewKeyring(keys ...ed25519.PublicKey) Keyring {
+	k := make(Keyring)
+	for _, key := range keys {
+		k.Add(key)
+	}
+	return k
+}
+
+// Add adds the given key to the keyring.
+func (k Keyring) Add(key ed25519.PublicKey) {
+	k[KeyId(key)] = key
+}
+
+// SignatureError is returned when an archive
+// does not have a valid, trusted signature.
+type SignatureError struct {
+	KeyId  string
+	Reason string
+}
+
+func (e *SignatureError) Error() string {
+	if e.KeyId == "" {
+		return "invalid signature: " + e.Reason
+	}
+	return fmt.Sprintf("invalid signature by key %q: %s", e.KeyId, e.Reason)
+}
+
+// ContentDigest returns a SHA-384 digest of the content of the charm
+// archive. It depends only on the paths, types, permission bits and
+// contents of the directories, files and symlinks in the archive, and
+// not on their order, times or compression, nor on any embedded
+// signature.
+func (a *CharmArchive) ContentDigest() ([]byte, error) {
+	return archiveContentDigest(a.zopen)
+}
+
+// Sign returns a detached signature of the charm archive's
+// content digest, made with the given key.
+func (a *CharmArchive) Sign(key ed25519.PrivateKey) (*Signature, error) {
+	return signArchive(a.zopen, key)
+}
+
+// WriteSignedTo writes a copy of the charm archive to w with a
+// signature made with the given key embedded in it, replacing
+// any signature that is already embedded.
+func (a *CharmArchive) WriteSignedTo(w io.Writer, key ed25519.PrivateKey) error {
+	return writeSignedArchive(a.zopen, w, key)
+}
+
+// Verify checks that sig is a signature of the charm archive's
+// content digest made with a key in the given keyring. If sig is
+// nil, the signature embedded in the archive is checked. The error
+// returned when the check fails is a *SignatureError.
+func (a *CharmArchive) Verify(keyring Keyring, sig *Signature) error {
+	return verifyArchive(a.zopen, keyring, sig)
+}
+
+// ContentDigest returns a SHA-384 digest of the content
+// of the bundle archive, as CharmArchive.ContentDigest does.
+func (a *BundleArchive) ContentDigest() ([]byte, error) {
+	return archiveContentDigest(a.zopen)
+}
+
+// Sign returns a detached signature of the bundle archive's
+// content digest, made with the given key.
+func (a *BundleArchive) Sign(key ed25519.PrivateKey) (*Signature, error) {
+	return signArchive(a.zopen, key)
+}
+
+// WriteSignedTo writes a copy of the bundle archive to w with a
+// signature made with the given key embedded in it, replacing
+// any signature that is already embedded.
+func (a *BundleArchive) WriteSignedTo(w io.Writer, key ed25519.PrivateKey) error {
+	return writeSignedArchive(a.zopen, w, key)
+}
+
+// Verify checks the signature of the bundle archive
+// as CharmArchive.Verify does.
+func (a *BundleArchive) Verify(keyring Keyring, sig *Signature) error {
+	return verifyArchive(a.zopen, keyring, sig)
+}
+
+func archiveContentDigest(zopen zipOpener) ([]byte, error) {
+	zipr, err := zopen.openZip()
+	if err != nil {
+		return nil, err
+	}
+	defer zipr.Close()
+	return contentDigest(zipr.File)
+}
+
+// contentDigest returns the content digest of the given zip entries.
+// Each directory, file and symlink contributes its kind, the
+// permission bits that ExpandTo gives it, its path and its content,
+// with lengths to keep them apart, in path order.
+func contentDigest(files []*zip.File) ([]byte, error) {
+	var entries []*zip.File
+	seen := make(map[string]bool)
+	for _, f := range files {
+		name := path.Clean(f.Name)
+		if name == SignatureFileName {
+			continue
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("archive holds more than one entry for %q", name)
+		}
+		seen[name] = true
+		entries = append(entries, f)
+	}
+	sort.Sort(zipFilesByName(entries))
+	h := sha512.New384()
+	for _, f := range entries {
+		mode := f.Mode()
+		perm := mode & os.ModePerm
+		kind := "file"
+		switch {
+		case mode.IsDir():
+			kind = "dir"
+		case mode&os.ModeSymlink != 0:
+			// The permissions of a symlink are not used.
+			kind, perm = "symlink", 0777
+		case mode&os.ModeType != 0:
+			return nil, fmt.Errorf("cannot digest %q: file has an unknown type", f.Name)
+		}
+		name := path.Clean(f.Name)
+		fmt.Fprintf(h, "%s %04o %d:%s %d\n", kind, perm, len(name), name, f.UncompressedSize64)
+		if kind == "dir" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(h, r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot digest %q: %v", name, err)
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+type zipFilesByName []*zip.File
+
+func (fs zipFilesByName) Len() int      { return len(fs) }
+func (fs zipFilesByName) Swap(i, j int) { fs[i], fs[j] = fs[j], fs[i] }
+func (fs zipFilesByName) Less(i, j int) bool {
+	return path.Clean(fs[i].Name) < path.Clean(fs[j].Name)
+}
+
+func signArchive(zopen zipOpener, key ed25519.PrivateKey) (*Signature, error) {
+	digest, err := archiveContentDigest(zopen)
+	if err != nil {
+		return nil, err
+	}
+	return signDigest(digest, key)
+}
+
+func signDigest(digest []byte, key ed25519.PrivateKey) (*Signature, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid ed25519 private key")
+	}
+	return &Signature{
+		KeyId:     KeyId(key.Public().(ed25519.PublicKey)),
+		Signature: ed25519.Sign(key, signedMessage(digest)),
+	}, nil
+}
+
+func signedMessage(digest []byte) []byte {
+	return append([]byte(signaturePrefix), digest...)
+}
+
+func writeSignedArchive(zopen zipOpener, w io.Writer, key ed25519.PrivateKey) error {
+	zipr, err := zopen.openZip()
+	if err != nil {
+		return err
+	}
+	defer zipr.Close()
+	digest, err := contentDigest(zipr.File)
+	if err != nil {
+		return err
+	}
+	sig, err := signDigest(digest, key)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(sig)
+	if err != nil {
+		return err
+	}
+	zipw := zip.NewWriter(w)
+	for _, f := range zipr.File {
+		if path.Clean(f.Name) == SignatureFileName {
+			continue
+		}
+		// Copy the entry without recompressing it.
+		if err := zipw.Copy(f); err != nil {
+			return err
+		}
+	}
+	h := &zip.FileHeader{
+		Name:   SignatureFileName,
+		Method: zip.Store,
+	}
+	h.SetMode(0644)
+	sigw, err := zipw.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+	if _, err := sigw.Write(data); err != nil {
+		return err
+	}
+	return zipw.Close()
+}
+
+func verifyArchive(zopen zipOpener, keyring Keyring, sig *Signature) error {
+	zipr, err := zopen.openZip()
+	if err != nil {
+		return err
+	}
+	defer zipr.Close()
+	if sig == nil {
+		sig, err = readEmbeddedSignature(zipr.File)
+		if err != nil {
+			return err
+		}
+	}
+	key, ok := keyring[sig.KeyId]
+	if !ok || len(key) != ed25519.PublicKeySize {
+		return &SignatureError{sig.KeyId, "key is not trusted"}
+	}
+	digest, err := contentDigest(zipr.File)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, signedMessage(digest), sig.Signature) {
+		return &SignatureError{sig.KeyId, "signature does not match archive content"}
+	}
+	return nil
+}
+
+// readEmbeddedSignature reads the signature embedded
+// in the archive holding the given files.
+func readEmbeddedSignature(files []*zip.File) (*Signature, error) {
+	for _, f := range files {
+		if path.Clean(f.Name) != SignatureFileName {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		sig, err := ReadSignature(bytes.NewReader(data))
+		if err != nil {
+			return nil, &SignatureError{Reason: err.Error()}
+		}
+		return sig, nil
+	}
+	return nil, &SignatureError{Reason: "archive is not signed"}
+}
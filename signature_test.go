@@ -0,0 +1,221 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type SignatureSuite struct {
+	publicKey  ed25519.PublicKey
+	privateKey ed25519.PrivateKey
+	otherKey   ed25519.PrivateKey
+}
+
+var _ = gc.Suite(&SignatureSuite{})
+
+func (s *SignatureSuite) SetUpSuite(c *gc.C) {
+	var err error
+	s.publicKey, s.privateKey, err = ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, jc.ErrorIsNil)
+	_, s.otherKey, err = ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func readCharmArchive(c *gc.C, data []byte) *charm.CharmArchive {
+	archive, err := charm.ReadCharmArchiveBytes(data)
+	c.Assert(err, jc.ErrorIsNil)
+	return archive
+}
+
+func archiveBytes(c *gc.C, dir *charm.CharmDir, opts charm.ArchiveOptions) []byte {
+	var buf bytes.Buffer
+	err := dir.ArchiveToWithOptions(&buf, opts)
+	c.Assert(err, jc.ErrorIsNil)
+	return buf.Bytes()
+}
+
+func (s *SignatureSuite) TestContentDigest(c *gc.C) {
+	dir := readCharmDir(c, "dummy")
+	digest1, err := readCharmArchive(c, archiveBytes(c, dir, charm.ArchiveOptions{})).ContentDigest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(digest1, gc.HasLen, 48)
+
+	// The digest does not depend on the order or
+	// modification times of the entries.
+	digest2, err := readCharmArchive(c, archiveBytes(c, dir, charm.ArchiveOptions{Deterministic: true})).ContentDigest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(digest2, jc.DeepEquals, digest1)
+
+	// It depends on the content.
+	clone, err := charm.ReadCharmDir(cloneDir(c, dir.Path))
+	c.Assert(err, jc.ErrorIsNil)
+	err = ioutil.WriteFile(filepath.Join(clone.Path, "src", "hello.c"), []byte("changed"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	digest3, err := readCharmArchive(c, archiveBytes(c, clone, charm.ArchiveOptions{})).ContentDigest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(digest3, gc.Not(jc.DeepEquals), digest1)
+}
+
+func (s *SignatureSuite) TestContentDigestModes(c *gc.C) {
+	base := []zipTestEntry{
+		{name: "src/", mode: os.ModeDir | 0755},
+		{name: "src/a", mode: 0644, data: "a"},
+	}
+	archive := readCharmArchive(c, makeZip(c, base...))
+	sig, err := archive.Sign(s.privateKey)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Each of the permission bits and directories that ExpandTo
+	// applies to disk is covered by the signature.
+	for i, entries := range [][]zipTestEntry{{
+		{name: "src/", mode: os.ModeDir | 0755},
+		{name: "src/a", mode: 0600, data: "a"},
+	}, {
+		{name: "src/", mode: os.ModeDir | 0755},
+		{name: "src/a", mode: 0755, data: "a"},
+	}, {
+		{name: "src/", mode: os.ModeDir | 0700},
+		{name: "src/a", mode: 0644, data: "a"},
+	}, {
+		{name: "src/", mode: os.ModeDir | 0755},
+		{name: "src/a", mode: 0644, data: "a"},
+		{name: "empty/", mode: os.ModeDir | 0755},
+	}} {
+		c.Logf("test %d", i)
+		tampered := readCharmArchive(c, makeZip(c, entries...))
+		err := tampered.Verify(charm.NewKeyring(s.publicKey), sig)
+		c.Assert(err, gc.ErrorMatches, `invalid signature by key ".*": signature does not match archive content`)
+	}
+	err = readCharmArchive(c, makeZip(c, base...)).Verify(charm.NewKeyring(s.publicKey), sig)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *SignatureSuite) TestDetachedSignature(c *gc.C) {
+	dir := readCharmDir(c, "dummy")
+	archive := readCharmArchive(c, archiveBytes(c, dir, charm.ArchiveOptions{}))
+	sig, err := archive.Sign(s.privateKey)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(sig.KeyId, gc.Equals, charm.KeyId(s.publicKey))
+
+	// The signature survives being written out and read back.
+	data, err := json.Marshal(sig)
+	c.Assert(err, jc.ErrorIsNil)
+	sig, err = charm.ReadSignature(bytes.NewReader(data))
+	c.Assert(err, jc.ErrorIsNil)
+
+	keyring := charm.NewKeyring(s.publicKey)
+	err = archive.Verify(keyring, sig)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Another archive of the same content verifies too.
+	other := readCharmArchive(c, archiveBytes(c, dir, charm.ArchiveOptions{Deterministic: true}))
+	err = other.Verify(keyring, sig)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The archive is not signed by an untrusted key.
+	err = archive.Verify(charm.NewKeyring(s.otherKey.Public().(ed25519.PublicKey)), sig)
+	c.Assert(err, gc.FitsTypeOf, &charm.SignatureError{})
+	c.Assert(err, gc.ErrorMatches, `invalid signature by key ".*": key is not trusted`)
+
+	// Nor does the signature match different content.
+	clone, err := charm.ReadCharmDir(cloneDir(c, dir.Path))
+	c.Assert(err, jc.ErrorIsNil)
+	err = ioutil.WriteFile(filepath.Join(clone.Path, "empty", "new"), nil, 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	tampered := readCharmArchive(c, archiveBytes(c, clone, charm.ArchiveOptions{}))
+	err = tampered.Verify(keyring, sig)
+	c.Assert(err, gc.ErrorMatches, `invalid signature by key ".*": signature does not match archive content`)
+}
+
+func (s *SignatureSuite) TestEmbeddedSignature(c *gc.C) {
+	dir := readCharmDir(c, "dummy")
+	archive := readCharmArchive(c, archiveBytes(c, dir, charm.ArchiveOptions{}))
+	keyring := charm.NewKeyring(s.publicKey)
+
+	err := archive.Verify(keyring, nil)
+	c.Assert(err, gc.ErrorMatches, `invalid signature: archive is not signed`)
+
+	var buf bytes.Buffer
+	err = archive.WriteSignedTo(&buf, s.otherKey)
+	c.Assert(err, jc.ErrorIsNil)
+	signed := readCharmArchive(c, buf.Bytes())
+	checkDummy(c, signed, "")
+	err = signed.Verify(keyring, nil)
+	c.Assert(err, gc.ErrorMatches, `invalid signature by key ".*": key is not trusted`)
+
+	// Signing again replaces the embedded signature.
+	buf.Reset()
+	err = signed.WriteSignedTo(&buf, s.privateKey)
+	c.Assert(err, jc.ErrorIsNil)
+	signed = readCharmArchive(c, buf.Bytes())
+	err = signed.Verify(keyring, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	zipr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	c.Assert(err, jc.ErrorIsNil)
+	count := 0
+	for _, f := range zipr.File {
+		if f.Name == charm.SignatureFileName {
+			count++
+		}
+	}
+	c.Assert(count, gc.Equals, 1)
+
+	// The embedded signature does not change the content digest.
+	digest1, err := archive.ContentDigest()
+	c.Assert(err, jc.ErrorIsNil)
+	digest2, err := signed.ContentDigest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(digest2, jc.DeepEquals, digest1)
+
+	// A detached signature can be checked against a signed archive.
+	sig, err := archive.Sign(s.privateKey)
+	c.Assert(err, jc.ErrorIsNil)
+	err = signed.Verify(keyring, sig)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *SignatureSuite) TestBundleSignature(c *gc.C) {
+	data, err := ioutil.ReadFile(archivePath(c, readBundleDir(c, "wordpress-simple")))
+	c.Assert(err, jc.ErrorIsNil)
+	archive, err := charm.ReadBundleArchiveBytes(data)
+	c.Assert(err, jc.ErrorIsNil)
+	keyring := charm.NewKeyring(s.publicKey)
+
+	sig, err := archive.Sign(s.privateKey)
+	c.Assert(err, jc.ErrorIsNil)
+	err = archive.Verify(keyring, sig)
+	c.Assert(err, jc.ErrorIsNil)
+
+	var buf bytes.Buffer
+	err = archive.WriteSignedTo(&buf, s.privateKey)
+	c.Assert(err, jc.ErrorIsNil)
+	signed, err := charm.ReadBundleArchiveBytes(buf.Bytes())
+	c.Assert(err, jc.ErrorIsNil)
+	checkWordpressBundle(c, signed, "")
+	err = signed.Verify(keyring, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	err = signed.Verify(charm.NewKeyring(), nil)
+	c.Assert(err, gc.FitsTypeOf, &charm.SignatureError{})
+}
+
+func (s *SignatureSuite) TestReadSignatureMalformed(c *gc.C) {
+	_, err := charm.ReadSignature(bytes.NewReader([]byte(`{"key-id": "x", "signature": "AAAA"}`)))
+	c.Assert(err, gc.ErrorMatches, `cannot read signature: signature is malformed`)
+	_, err = charm.ReadSignature(bytes.NewReader([]byte(`{`)))
+	c.Assert(err, gc.ErrorMatches, `cannot read signature: .*`)
+}
@@ -0,0 +1,159 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Store describes a source of charms and bundles that may be
+// referred to by charm URLs.
+type Store struct {
+	// Schema holds the schema of the charm URLs that refer
+	// to the store, for example "cs".
+	Schema string
+
+	// Hosts holds the names of the hosts that serve the store
+	// over HTTP, for example "jujucharms.com". A fully
+	// qualified http or https URL on one of these hosts is
+	// parsed as a URL with the store's schema.
+	Hosts []string
+
+	// Local holds whether the store refers to charms on the
+	// local machine. URLs for local stores may not include
+	// a user name.
+	Local bool
+}
+
+var validStoreSchema = regexp.MustCompile("^[a-z][a-z0-9+.-]*$")
+
+var (
+	storesMu sync.RWMutex
+
+	// stores holds the known stores, keyed by schema. It may be
+	// extended with RegisterStore.
+	stores = map[string]Store{
+		"cs": {
+			Schema: "cs",
+			Hosts:  []string{"jujucharms.com", "www.jujucharms.com"},
+		},
+		"local": {
+			Schema: "local",
+			Local:  true,
+		},
+	}
+)
+
+// defaultStoreSchema holds the schema of URLs that do not specify
+// one, and of http and https URLs for hosts that no store claims.
+const defaultStoreSchema = "cs"
+
+// RegisterStore registers a store so that ParseURL accepts its schema,
+// and parses http and https URLs on its hosts as URLs with that
+// schema. Registering a store with the schema of a known store
+// replaces it. The "cs" and "local" stores may not be replaced.
+func RegisterStore(store Store) error {
+	if !validStoreSchema.MatchString(store.Schema) || store.Schema == "http" || store.Schema == "https" {
+		return fmt.Errorf("invalid store schema %q", store.Schema)
+	}
+	if store.Schema == "cs" || store.Schema == "local" {
+		return fmt.Errorf("cannot replace the %q store", store.Schema)
+	}
+	if store.Local && len(store.Hosts) > 0 {
+		return fmt.Errorf("local store %q cannot have hosts", store.Schema)
+	}
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	for _, host := range store.Hosts {
+		if host == "" || strings.ContainsAny(host, "/@") {
+			return fmt.Errorf("invalid host %q for store %q", host, store.Schema)
+		}
+		if other, ok := storeForHost(host); ok && other.Schema != store.Schema {
+			return fmt.Errorf("host %q already belongs to store %q", host, other.Schema)
+		}
+	}
+	store.Hosts = append([]string(nil), store.Hosts...)
+	stores[store.Schema] = store
+	return nil
+}
+
+// UnregisterStore removes a store registered with RegisterStore.
+// It does nothing if there is no such store.
+func UnregisterStore(schema string) {
+	if schema == "cs" || schema == "local" {
+		return
+	}
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	delete(stores, schema)
+}
+
+// Stores returns all the known stores, ordered by schema.
+func Stores() []Store {
+	storesMu.RLock()
+	defer storesMu.RUnlock()
+	schemas := make([]string, 0, len(stores))
+	for schema := range stores {
+		schemas = append(schemas, schema)
+	}
+	sort.Strings(schemas)
+	result := make([]Store, len(schemas))
+	for i, schema := range schemas {
+		result[i] = stores[schema]
+		result[i].Hosts = append([]string(nil), result[i].Hosts...)
+	}
+	return result
+}
+
+// StoreForSchema returns the store with the given schema,
+// and reports whether there is one.
+func StoreForSchema(schema string) (Store, bool) {
+	storesMu.RLock()
+	defer storesMu.RUnlock()
+	store, ok := stores[schema]
+	return store, ok
+}
+
+// StoreForHost returns the store served by the given host,
+// and reports whether there is one. The host may include
+// a port, in which case a store that lists the host without
+// the port also matches.
+func StoreForHost(host string) (Store, bool) {
+	storesMu.RLock()
+	defer storesMu.RUnlock()
+	return storeForHost(host)
+}
+
+// storeForHost is like StoreForHost, but must
+// be called with storesMu held.
+func storeForHost(host string) (Store, bool) {
+	hostname := host
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.HasSuffix(host, "]") {
+		hostname = host[:i]
+	}
+	var found Store
+	var ok bool
+	for _, store := range stores {
+		for _, h := range store.Hosts {
+			if strings.EqualFold(h, host) {
+				return store, true
+			}
+			if strings.EqualFold(h, hostname) {
+				found, ok = store, true
+			}
+		}
+	}
+	return found, ok
+}
+
+// isLocalSchema reports whether the given schema
+// belongs to a local store.
+func isLocalSchema(schema string) bool {
+	store, ok := StoreForSchema(schema)
+	return ok && store.Local
+}
@@ -0,0 +1,144 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type StoreSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&StoreSuite{})
+
+func (s *StoreSuite) registerStore(c *gc.C, store charm.Store) {
+	err := charm.RegisterStore(store)
+	c.Assert(err, jc.ErrorIsNil)
+	s.AddCleanup(func(*gc.C) {
+		charm.UnregisterStore(store.Schema)
+	})
+}
+
+func (s *StoreSuite) TestDefaultStores(c *gc.C) {
+	c.Assert(charm.Stores(), jc.DeepEquals, []charm.Store{{
+		Schema: "cs",
+		Hosts:  []string{"jujucharms.com", "www.jujucharms.com"},
+	}, {
+		Schema: "local",
+		Local:  true,
+	}})
+	store, ok := charm.StoreForHost("jujucharms.com")
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(store.Schema, gc.Equals, "cs")
+	_, ok = charm.StoreForHost("example.com")
+	c.Assert(ok, jc.IsFalse)
+}
+
+var storeURLTests = []struct {
+	s     string
+	exact string
+	url   *charm.URL
+}{{
+	s:     "https://charms.internal.example/u/team/app",
+	exact: "internal:~team/app",
+	url:   &charm.URL{Schema: "internal", User: "team", Name: "app", Revision: -1},
+}, {
+	s:     "https://CHARMS.internal.example:8443/edge/app/xenial/3",
+	exact: "internal:edge/xenial/app-3",
+	url:   &charm.URL{Schema: "internal", Name: "app", Revision: 3, Series: "xenial", Channel: charm.Channel{Risk: charm.Edge}},
+}, {
+	s:     "internal:~team/xenial/app-1",
+	exact: "internal:~team/xenial/app-1",
+	url:   &charm.URL{Schema: "internal", User: "team", Name: "app", Revision: 1, Series: "xenial"},
+}, {
+	s:     "https://mirror.internal.example/app",
+	exact: "internal:app",
+	url:   &charm.URL{Schema: "internal", Name: "app", Revision: -1},
+}, {
+	s:     "https://example.com/u/team/app",
+	exact: "cs:~team/app",
+	url:   &charm.URL{Schema: "cs", User: "team", Name: "app", Revision: -1},
+}, {
+	s:     "https://jujucharms.com/app",
+	exact: "cs:app",
+	url:   &charm.URL{Schema: "cs", Name: "app", Revision: -1},
+}}
+
+func (s *StoreSuite) TestParseURLWithRegisteredStore(c *gc.C) {
+	s.registerStore(c, charm.Store{
+		Schema: "internal",
+		Hosts:  []string{"charms.internal.example", "mirror.internal.example"},
+	})
+	for i, test := range storeURLTests {
+		c.Logf("test %d: %s", i, test.s)
+		url, err := charm.ParseURL(test.s)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(url, jc.DeepEquals, test.url)
+		c.Assert(url.String(), gc.Equals, test.exact)
+		url, err = charm.ParseURL(url.String())
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(url, jc.DeepEquals, test.url)
+	}
+	c.Assert(charm.ValidateSchema("internal"), jc.ErrorIsNil)
+}
+
+func (s *StoreSuite) TestUnregisterStore(c *gc.C) {
+	s.registerStore(c, charm.Store{Schema: "internal"})
+	_, err := charm.ParseURL("internal:app")
+	c.Assert(err, jc.ErrorIsNil)
+
+	charm.UnregisterStore("internal")
+	_, err = charm.ParseURL("internal:app")
+	c.Assert(err, gc.ErrorMatches, `cannot parse URL "internal:app": schema "internal" not valid`)
+
+	// The default stores are always known.
+	charm.UnregisterStore("cs")
+	c.Assert(charm.ValidateSchema("cs"), jc.ErrorIsNil)
+}
+
+func (s *StoreSuite) TestLocalStore(c *gc.C) {
+	s.registerStore(c, charm.Store{Schema: "dev", Local: true})
+	url, err := charm.ParseURL("dev:xenial/app")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(url.Schema, gc.Equals, "dev")
+	_, err = charm.ParseURL("dev:~team/app")
+	c.Assert(err, gc.ErrorMatches, `local charm or bundle URL with user name: "dev:~team/app"`)
+}
+
+var registerStoreErrorTests = []struct {
+	store charm.Store
+	err   string
+}{{
+	store: charm.Store{Schema: "Bad"},
+	err:   `invalid store schema "Bad"`,
+}, {
+	store: charm.Store{Schema: "https"},
+	err:   `invalid store schema "https"`,
+}, {
+	store: charm.Store{Schema: "cs", Hosts: []string{"example.com"}},
+	err:   `cannot replace the "cs" store`,
+}, {
+	store: charm.Store{Schema: "internal", Hosts: []string{"jujucharms.com"}},
+	err:   `host "jujucharms.com" already belongs to store "cs"`,
+}, {
+	store: charm.Store{Schema: "internal", Hosts: []string{"example.com/charms"}},
+	err:   `invalid host "example.com/charms" for store "internal"`,
+}, {
+	store: charm.Store{Schema: "internal", Hosts: []string{"example.com"}, Local: true},
+	err:   `local store "internal" cannot have hosts`,
+}}
+
+func (s *StoreSuite) TestRegisterStoreErrors(c *gc.C) {
+	for i, test := range registerStoreErrorTests {
+		c.Logf("test %d: %s", i, test.err)
+		c.Assert(charm.RegisterStore(test.store), gc.ErrorMatches, test.err)
+	}
+	_, ok := charm.StoreForSchema("internal")
+	c.Assert(ok, jc.IsFalse)
+}
@@ -0,0 +1,418 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/juju/utils/set"
+)
+
+// The CharmTarArchive type encapsulates access to data and operations
+// on a charm held in a gzip-compressed tarball, as created by
+// ArchiveToWithOptions with the TarGzFormat format.
+type CharmTarArchive struct {
+	topen tarOpener
+
+	Path     string // May be empty if CharmTarArchive wasn't read from a file
+	meta     *Meta
+	config   *Config
+	metrics  *Metrics
+	actions  *Actions
+	revision int
+}
+
+// Trick to ensure *CharmTarArchive implements the Charm interface.
+var _ Charm = (*CharmTarArchive)(nil)
+
+// ReadCharmTarArchive returns a CharmTarArchive for the charm in path.
+func ReadCharmTarArchive(path string) (*CharmTarArchive, error) {
+	a, err := readCharmTarArchive(tarPathOpener(path))
+	if err != nil {
+		return nil, err
+	}
+	a.Path = path
+	return a, nil
+}
+
+// ReadCharmTarArchiveBytes returns a CharmTarArchive read from the given data.
+// Make sure the archive fits in memory before using this.
+func ReadCharmTarArchiveBytes(data []byte) (*CharmTarArchive, error) {
+	return readCharmTarArchive(tarBytesOpener(data))
+}
+
+func readCharmTarArchive(topen tarOpener) (*CharmTarArchive, error) {
+	a := &CharmTarArchive{
+		topen: topen,
+	}
+	files, err := readTarFiles(topen, "metadata.yaml", "config.yaml", "metrics.yaml", "actions.yaml", "revision")
+	if err != nil {
+		return nil, err
+	}
+	err = readCharmArchiveFiles(files.open, ReadMeta, &a.meta, &a.config, &a.metrics, &a.actions, &a.revision)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Meta returns the Meta representing the metadata.yaml file from archive.
+func (a *CharmTarArchive) Meta() *Meta {
+	return a.meta
+}
+
+// Config returns the Config representing the config.yaml file
+// for the charm archive.
+func (a *CharmTarArchive) Config() *Config {
+	return a.config
+}
+
+// Metrics returns the Metrics representing the metrics.yaml file
+// for the charm archive.
+func (a *CharmTarArchive) Metrics() *Metrics {
+	return a.metrics
+}
+
+// Actions returns the Actions map for the actions.yaml file for the charm
+// archive.
+func (a *CharmTarArchive) Actions() *Actions {
+	return a.actions
+}
+
+// Revision returns the revision number for the charm
+// held in the archive.
+func (a *CharmTarArchive) Revision() int {
+	return a.revision
+}
+
+// SetRevision changes the charm revision number. This affects the
+// revision reported by Revision and the revision of the charm
+// directory created by ExpandTo.
+func (a *CharmTarArchive) SetRevision(revision int) {
+	a.revision = revision
+}
+
+// Manifest returns a set of the charm's contents.
+func (a *CharmTarArchive) Manifest() (set.Strings, error) {
+	var paths []string
+	err := walkTar(a.topen, DefaultExpandLimits, func(h *tar.Header, r io.Reader) error {
+		paths = append(paths, path.Clean(h.Name))
+		return nil
+	})
+	if err != nil {
+		return set.NewStrings(), err
+	}
+	return archiveManifest(paths), nil
+}
+
+// ExpandTo expands the charm archive into dir, creating it if necessary.
+// If any errors occur during the expansion procedure, the process will
+// abort. An archive that is unsafe to expand, or that exceeds
+// DefaultExpandLimits, is refused.
+func (a *CharmTarArchive) ExpandTo(dir string) error {
+	return a.ExpandToWithOptions(dir, ExpandOptions{})
+}
+
+// ExpandToWithOptions is like ExpandTo, but
+// expands the archive according to the given options.
+func (a *CharmTarArchive) ExpandToWithOptions(dir string, opts ExpandOptions) error {
+	if opts.Atomic {
+		return expandAtomically(dir, func(dir string) error {
+			return a.expand(dir, opts)
+		}, func(dir string) error {
+			return verifyExpandedCharm(dir, a.meta.Hooks(), a.revision)
+		})
+	}
+	return a.expand(dir, opts)
+}
+
+func (a *CharmTarArchive) expand(dir string, opts ExpandOptions) error {
+	if err := expandTar(a.topen, dir, opts.limits()); err != nil {
+		return err
+	}
+	return finishExpandedCharm(dir, a.meta.Hooks(), a.revision)
+}
+
+// BundleTarArchive holds a bundle in a gzip-compressed tarball, as
+// created by ArchiveToWithOptions with the TarGzFormat format.
+type BundleTarArchive struct {
+	topen tarOpener
+
+	Path   string
+	data   *BundleData
+	readMe string
+}
+
+// Trick to ensure *BundleTarArchive implements the Bundle interface.
+var _ Bundle = (*BundleTarArchive)(nil)
+
+// ReadBundleTarArchive reads a bundle from the given tarball.
+func ReadBundleTarArchive(path string) (*BundleTarArchive, error) {
+	a, err := readBundleTarArchive(tarPathOpener(path))
+	if err != nil {
+		return nil, err
+	}
+	a.Path = path
+	return a, nil
+}
+
+// ReadBundleTarArchiveBytes reads a bundle from the given byte slice.
+func ReadBundleTarArchiveBytes(data []byte) (*BundleTarArchive, error) {
+	return readBundleTarArchive(tarBytesOpener(data))
+}
+
+func readBundleTarArchive(topen tarOpener) (*BundleTarArchive, error) {
+	a := &BundleTarArchive{
+		topen: topen,
+	}
+	files, err := readTarFiles(topen, "bundle.yaml", "README.md")
+	if err != nil {
+		return nil, err
+	}
+	a.data, a.readMe, err = readBundleArchiveFiles(files.open)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Data implements Bundle.Data.
+func (a *BundleTarArchive) Data() *BundleData {
+	return a.data
+}
+
+// ReadMe implements Bundle.ReadMe.
+func (a *BundleTarArchive) ReadMe() string {
+	return a.readMe
+}
+
+// ExpandTo expands the bundle archive into dir, creating it if necessary.
+// If any errors occur during the expansion procedure, the process will
+// abort. An archive that is unsafe to expand, or that exceeds
+// DefaultExpandLimits, is refused.
+func (a *BundleTarArchive) ExpandTo(dir string) error {
+	return a.ExpandToWithOptions(dir, ExpandOptions{})
+}
+
+// ExpandToWithOptions is like ExpandTo, but
+// expands the archive according to the given options.
+func (a *BundleTarArchive) ExpandToWithOptions(dir string, opts ExpandOptions) error {
+	if opts.Atomic {
+		return expandAtomically(dir, func(dir string) error {
+			return expandTar(a.topen, dir, opts.limits())
+		}, func(dir string) error {
+			_, err := ReadBundleDir(dir)
+			return err
+		})
+	}
+	return expandTar(a.topen, dir, opts.limits())
+}
+
+// isTarGzFile reports whether the file at path
+// starts with the magic number of a gzip stream.
+func isTarGzFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	var magic [2]byte
+	if _, err := io.ReadFull(f, magic[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return magic == [2]byte{0x1f, 0x8b}, nil
+}
+
+// tarOpener opens the gzip-compressed stream that holds a tarball.
+type tarOpener interface {
+	openTar() (io.ReadCloser, error)
+}
+
+type tarPathOpener string
+
+func (p tarPathOpener) openTar() (io.ReadCloser, error) {
+	return os.Open(string(p))
+}
+
+type tarBytesOpener []byte
+
+func (b tarBytesOpener) openTar() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+// tarFiles holds the contents of some of the regular
+// files in a tarball, keyed by their cleaned paths.
+type tarFiles map[string][]byte
+
+// open opens the file at the given path, returning a
+// *noCharmArchiveFile error if it was not found.
+func (files tarFiles) open(path string) (io.ReadCloser, error) {
+	data, ok := files[path]
+	if !ok {
+		return nil, &noCharmArchiveFile{path}
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// readTarFiles reads the regular files at the given paths from
+// a tarball, checking the whole tarball against DefaultExpandLimits.
+func readTarFiles(topen tarOpener, paths ...string) (tarFiles, error) {
+	files := make(tarFiles)
+	wanted := set.NewStrings(paths...)
+	err := walkTar(topen, DefaultExpandLimits, func(h *tar.Header, r io.Reader) error {
+		name := path.Clean(h.Name)
+		if !wanted.Contains(name) || h.FileInfo().Mode()&os.ModeType != 0 {
+			return nil
+		}
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		files[name] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// walkTar calls f for each entry in a tarball, with a reader of the
+// entry's content, checking the paths of the entries and checking
+// them against the given limits. As the entries in a tarball are not
+// compressed individually, ExpandLimits.MaxRatio is checked against
+// the compression ratio of the tarball as a whole, as the content of
+// each entry is read, so that f never sees content past the limit.
+// The tar package refuses to read more data than the recorded size
+// of an entry, so the recorded sizes can be relied on.
+func walkTar(topen tarOpener, limits ExpandLimits, f func(h *tar.Header, r io.Reader) error) error {
+	rc, err := topen.openTar()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	compressed := &countingReader{r: rc}
+	gzr, err := gzip.NewReader(compressed)
+	if err != nil {
+		return fmt.Errorf("cannot read tarball: %v", err)
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+	var total, expanded int64
+	count := 0
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read tarball: %v", err)
+		}
+		if h.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		count++
+		if limits.MaxFiles > 0 && count > limits.MaxFiles {
+			return &FileLimitError{limits.MaxFiles}
+		}
+		if err := checkArchivePath(h.Name); err != nil {
+			return err
+		}
+		total += h.Size
+		if limits.MaxSize > 0 && total > limits.MaxSize {
+			return &SizeLimitError{limits.MaxSize}
+		}
+		r := io.Reader(tr)
+		if limits.MaxRatio > 0 {
+			r = &ratioReader{
+				r:          tr,
+				name:       h.Name,
+				maxRatio:   limits.MaxRatio,
+				expanded:   &expanded,
+				compressed: compressed,
+			}
+		}
+		if err := f(h, r); err != nil {
+			return err
+		}
+	}
+}
+
+// expandTar expands the entries in a tarball into dir, overwriting
+// existing files and directories only where necessary. It refuses
+// to expand an unsafe tarball.
+func expandTar(topen tarOpener, dir string, limits ExpandLimits) error {
+	var symlinks []string
+	err := walkTar(topen, limits, func(h *tar.Header, r io.Reader) error {
+		name := path.Clean(h.Name)
+		mode := h.FileInfo().Mode()
+		open := func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(r), nil
+		}
+		switch h.Typeflag {
+		case tar.TypeLink:
+			return fmt.Errorf("cannot extract %q: file is a hard link", name)
+		case tar.TypeSymlink:
+			open = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(strings.NewReader(h.Linkname)), nil
+			}
+			symlinks = append(symlinks, name)
+		}
+		if err := expandEntry(dir, h.Name, mode, open); err != nil {
+			if isExpandError(err) {
+				return err
+			}
+			return fmt.Errorf("cannot extract %q: %v", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return checkExpandedSymlinks(dir, symlinks)
+}
+
+// ratioReader reads the content of a tarball entry, failing with a
+// *RatioLimitError as soon as the content read from the tarball so
+// far is more than maxRatio times the size of the compressed data
+// read to produce it.
+type ratioReader struct {
+	r          io.Reader
+	name       string
+	maxRatio   float64
+	expanded   *int64
+	compressed *countingReader
+}
+
+func (r *ratioReader) Read(buf []byte) (int, error) {
+	n, err := r.r.Read(buf)
+	*r.expanded += int64(n)
+	if *r.expanded > ratioMinSize && float64(*r.expanded) > r.maxRatio*float64(r.compressed.n) {
+		return 0, &RatioLimitError{r.name, r.maxRatio}
+	}
+	return n, err
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(buf []byte) (int, error) {
+	n, err := r.r.Read(buf)
+	r.n += int64(n)
+	return n, err
+}
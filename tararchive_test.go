@@ -0,0 +1,295 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/utils/set"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type TarArchiveSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&TarArchiveSuite{})
+
+// tarArchivePath archives the given charm or bundle directory
+// as a gzip-compressed tarball to a newly created file and
+// returns the path to the file.
+func tarArchivePath(c *gc.C, dir interface{}) string {
+	var buf bytes.Buffer
+	opts := charm.ArchiveOptions{Format: charm.TarGzFormat}
+	var err error
+	switch dir := dir.(type) {
+	case *charm.CharmDir:
+		err = dir.ArchiveToWithOptions(&buf, opts)
+	case *charm.BundleDir:
+		err = dir.ArchiveToWithOptions(&buf, opts)
+	}
+	c.Assert(err, jc.ErrorIsNil)
+	path := filepath.Join(c.MkDir(), "archive")
+	err = ioutil.WriteFile(path, buf.Bytes(), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	return path
+}
+
+type tarTestEntry struct {
+	name     string
+	typeflag byte
+	mode     int64
+	data     string
+	linkname string
+}
+
+// makeTarGz returns a gzip-compressed tarball holding the given
+// entries, preceded by the metadata of a minimal charm.
+func makeTarGz(c *gc.C, entries ...tarTestEntry) []byte {
+	entries = append([]tarTestEntry{{
+		name: "metadata.yaml",
+		mode: 0644,
+		data: "name: dummy\nsummary: s\ndescription: d\n",
+	}}, entries...)
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tarw := tar.NewWriter(gzw)
+	for _, e := range entries {
+		typeflag := e.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		err := tarw.WriteHeader(&tar.Header{
+			Name:     e.name,
+			Typeflag: typeflag,
+			Mode:     e.mode,
+			Size:     int64(len(e.data)),
+			Linkname: e.linkname,
+		})
+		c.Assert(err, jc.ErrorIsNil)
+		_, err = tarw.Write([]byte(e.data))
+		c.Assert(err, jc.ErrorIsNil)
+	}
+	c.Assert(tarw.Close(), jc.ErrorIsNil)
+	c.Assert(gzw.Close(), jc.ErrorIsNil)
+	return buf.Bytes()
+}
+
+func (s *TarArchiveSuite) TestReadCharmTarArchive(c *gc.C) {
+	path := tarArchivePath(c, readCharmDir(c, "dummy"))
+	archive, err := charm.ReadCharmTarArchive(path)
+	c.Assert(err, jc.ErrorIsNil)
+	checkDummy(c, archive, path)
+
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, jc.ErrorIsNil)
+	archive, err = charm.ReadCharmTarArchiveBytes(data)
+	c.Assert(err, jc.ErrorIsNil)
+	checkDummy(c, archive, "")
+}
+
+func (s *TarArchiveSuite) TestReadCharmTarArchiveWithoutMetadata(c *gc.C) {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	c.Assert(tar.NewWriter(gzw).Close(), jc.ErrorIsNil)
+	c.Assert(gzw.Close(), jc.ErrorIsNil)
+	_, err := charm.ReadCharmTarArchiveBytes(buf.Bytes())
+	c.Assert(err, gc.ErrorMatches, `archive file "metadata.yaml" not found`)
+
+	_, err = charm.ReadCharmTarArchiveBytes([]byte("foo"))
+	c.Assert(err, gc.ErrorMatches, `cannot read tarball: .*`)
+}
+
+func (s *TarArchiveSuite) TestManifest(c *gc.C) {
+	archive, err := charm.ReadCharmTarArchive(tarArchivePath(c, readCharmDir(c, "dummy")))
+	c.Assert(err, jc.ErrorIsNil)
+	manifest, err := archive.Manifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(manifest, jc.DeepEquals, set.NewStrings(dummyManifest...))
+}
+
+func (s *TarArchiveSuite) TestExpandTo(c *gc.C) {
+	archive, err := charm.ReadCharmTarArchive(tarArchivePath(c, readCharmDir(c, "dummy")))
+	c.Assert(err, jc.ErrorIsNil)
+
+	path := filepath.Join(c.MkDir(), "charm")
+	err = archive.ExpandTo(path)
+	c.Assert(err, jc.ErrorIsNil)
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+	checkDummy(c, dir, path)
+
+	info, err := os.Stat(filepath.Join(path, "hooks", "install"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.Mode()&0100, gc.Not(gc.Equals), os.FileMode(0))
+}
+
+func (s *TarArchiveSuite) TestExpandToSetsHooksExecutableAndRevision(c *gc.C) {
+	archive, err := charm.ReadCharmTarArchiveBytes(makeTarGz(c,
+		tarTestEntry{name: "hooks/", typeflag: tar.TypeDir, mode: 0755},
+		tarTestEntry{name: "hooks/install", mode: 0644, data: "#!/bin/sh\n"},
+		tarTestEntry{name: "hooks/helper", mode: 0644, data: "x"},
+		tarTestEntry{name: "revision", mode: 0644, data: "3"},
+	))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(archive.Revision(), gc.Equals, 3)
+	archive.SetRevision(7)
+
+	path := filepath.Join(c.MkDir(), "charm")
+	err = archive.ExpandToWithOptions(path, charm.ExpandOptions{Atomic: true})
+	c.Assert(err, jc.ErrorIsNil)
+
+	info, err := os.Stat(filepath.Join(path, "hooks", "install"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.Mode()&0777, gc.Equals, os.FileMode(0744))
+	info, err = os.Stat(filepath.Join(path, "hooks", "helper"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.Mode()&0777, gc.Equals, os.FileMode(0644))
+
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(dir.Revision(), gc.Equals, 7)
+}
+
+var unsafeTarTests = []struct {
+	about   string
+	entries []tarTestEntry
+	// expand holds whether the error is only
+	// found when the archive is expanded.
+	expand bool
+	err    string
+}{{
+	about:   "path out of archive",
+	entries: []tarTestEntry{{name: "../evil", mode: 0644}},
+	err:     `unsafe path "../evil" in archive: path leads out of archive`,
+}, {
+	about:   "absolute path",
+	entries: []tarTestEntry{{name: "/evil", mode: 0644}},
+	err:     `unsafe path "/evil" in archive: path is absolute`,
+}, {
+	about:   "symlink out of charm",
+	entries: []tarTestEntry{{name: "link", typeflag: tar.TypeSymlink, mode: 0777, linkname: "../.."}},
+	expand:  true,
+	err:     `symlink "link" links out of charm: "../.."`,
+}, {
+	about:   "hard link",
+	entries: []tarTestEntry{{name: "link", typeflag: tar.TypeLink, linkname: "metadata.yaml"}},
+	expand:  true,
+	err:     `cannot extract "link": file is a hard link`,
+}, {
+	about:   "named pipe",
+	entries: []tarTestEntry{{name: "fifo", typeflag: tar.TypeFifo, mode: 0644}},
+	expand:  true,
+	err:     `cannot extract "fifo": file is a named pipe: "fifo"`,
+}}
+
+func (s *TarArchiveSuite) TestExpandUnsafe(c *gc.C) {
+	for i, test := range unsafeTarTests {
+		c.Logf("test %d: %s", i, test.about)
+		data := makeTarGz(c, test.entries...)
+		archive, err := charm.ReadCharmTarArchiveBytes(data)
+		if test.expand {
+			c.Assert(err, jc.ErrorIsNil)
+			err = archive.ExpandTo(c.MkDir())
+		}
+		c.Assert(err, gc.ErrorMatches, test.err)
+	}
+}
+
+func (s *TarArchiveSuite) TestExpandLimits(c *gc.C) {
+	archive, err := charm.ReadCharmTarArchiveBytes(makeTarGz(c,
+		tarTestEntry{name: "a", mode: 0644, data: "a"},
+		tarTestEntry{name: "b", mode: 0644, data: "b"},
+	))
+	c.Assert(err, jc.ErrorIsNil)
+	err = archive.ExpandToWithOptions(c.MkDir(), charm.ExpandOptions{
+		Limits: &charm.ExpandLimits{MaxFiles: 2},
+	})
+	c.Assert(err, gc.FitsTypeOf, &charm.FileLimitError{})
+
+	archive, err = charm.ReadCharmTarArchiveBytes(makeTarGz(c,
+		tarTestEntry{name: "big", mode: 0644, data: string(make([]byte, 2<<20))},
+	))
+	c.Assert(err, jc.ErrorIsNil)
+	err = archive.ExpandToWithOptions(c.MkDir(), charm.ExpandOptions{
+		Limits: &charm.ExpandLimits{MaxSize: 1 << 20},
+	})
+	c.Assert(err, gc.FitsTypeOf, &charm.SizeLimitError{})
+	dir := c.MkDir()
+	err = archive.ExpandToWithOptions(dir, charm.ExpandOptions{
+		Limits: &charm.ExpandLimits{MaxRatio: 100},
+	})
+	c.Assert(err, gc.FitsTypeOf, &charm.RatioLimitError{})
+	c.Assert(err.(*charm.RatioLimitError).Path, gc.Equals, "big")
+	// Nothing past the limit is written.
+	c.Assert(dirNames(c, dir), jc.DeepEquals, []string{"metadata.yaml"})
+}
+
+func (s *TarArchiveSuite) TestDeterministic(c *gc.C) {
+	dir := readCharmDir(c, "dummy")
+	opts := charm.ArchiveOptions{
+		Format:        charm.TarGzFormat,
+		Deterministic: true,
+	}
+	var buf1, buf2 bytes.Buffer
+	err := dir.ArchiveToWithOptions(&buf1, opts)
+	c.Assert(err, jc.ErrorIsNil)
+
+	clone, err := charm.ReadCharmDir(cloneDir(c, dir.Path))
+	c.Assert(err, jc.ErrorIsNil)
+	err = clone.ArchiveToWithOptions(&buf2, opts)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(buf1.Bytes(), jc.DeepEquals, buf2.Bytes())
+}
+
+func (s *TarArchiveSuite) TestUnknownFormat(c *gc.C) {
+	var buf bytes.Buffer
+	err := readCharmDir(c, "dummy").ArchiveToWithOptions(&buf, charm.ArchiveOptions{Format: "rar"})
+	c.Assert(err, gc.ErrorMatches, `unknown archive format "rar"`)
+}
+
+func (s *TarArchiveSuite) TestReadCharmDetectsFormat(c *gc.C) {
+	path := tarArchivePath(c, readCharmDir(c, "dummy"))
+	ch, err := charm.ReadCharm(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ch, gc.FitsTypeOf, &charm.CharmTarArchive{})
+	checkDummy(c, ch, path)
+
+	path = archivePath(c, readCharmDir(c, "dummy"))
+	ch, err = charm.ReadCharm(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ch, gc.FitsTypeOf, &charm.CharmArchive{})
+}
+
+func (s *TarArchiveSuite) TestBundleTarArchive(c *gc.C) {
+	path := tarArchivePath(c, readBundleDir(c, "wordpress-simple"))
+	b, err := charm.ReadBundle(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(b, gc.FitsTypeOf, &charm.BundleTarArchive{})
+	checkWordpressBundle(c, b, path)
+
+	archive := b.(*charm.BundleTarArchive)
+	dir := filepath.Join(c.MkDir(), "bundle")
+	err = archive.ExpandToWithOptions(dir, charm.ExpandOptions{Atomic: true})
+	c.Assert(err, jc.ErrorIsNil)
+	bdir, err := charm.ReadBundleDir(dir)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bdir.ReadMe(), gc.Equals, archive.ReadMe())
+	c.Assert(bdir.Data(), jc.DeepEquals, archive.Data())
+
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, jc.ErrorIsNil)
+	archive, err = charm.ReadBundleTarArchiveBytes(data)
+	c.Assert(err, jc.ErrorIsNil)
+	checkWordpressBundle(c, archive, "")
+}
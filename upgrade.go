@@ -0,0 +1,301 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// UpgradeSeverity classifies the changes reported by CheckUpgrade.
+type UpgradeSeverity string
+
+const (
+	// UpgradeBreaking is the severity of changes that are likely
+	// to break a deployed application when it is upgraded.
+	UpgradeBreaking UpgradeSeverity = "breaking"
+
+	// UpgradeWarning is the severity of changes that an operator
+	// should know about but that need not break an upgrade.
+	UpgradeWarning UpgradeSeverity = "warning"
+)
+
+// UpgradeChange describes a single difference between two
+// revisions of a charm that may affect an upgrade between them.
+type UpgradeChange struct {
+	Severity UpgradeSeverity
+
+	// Path holds the YAML path, within the old charm's metadata
+	// or config, of the key that changed, for example
+	// "provides.db.interface" or "options.title.type".
+	Path string
+
+	// Message describes the change.
+	Message string
+}
+
+// String returns the change in a form suitable for reporting
+// to an operator.
+func (c UpgradeChange) String() string {
+	return fmt.Sprintf("%s: %s", c.Severity, c.Message)
+}
+
+// UpgradeChanges holds the changes found by CheckUpgrade.
+type UpgradeChanges []UpgradeChange
+
+// Breaking returns the breaking changes only.
+func (cs UpgradeChanges) Breaking() UpgradeChanges {
+	return cs.withSeverity(UpgradeBreaking)
+}
+
+// Warnings returns the warnings only.
+func (cs UpgradeChanges) Warnings() UpgradeChanges {
+	return cs.withSeverity(UpgradeWarning)
+}
+
+func (cs UpgradeChanges) withSeverity(severity UpgradeSeverity) UpgradeChanges {
+	var result UpgradeChanges
+	for _, c := range cs {
+		if c.Severity == severity {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// CheckUpgrade compares two revisions of a charm and reports the
+// changes between them that may affect an application upgraded from
+// oldCharm to newCharm. It checks the relations, storage, config and
+// subordinate flag of the charms. Changes are reported in a
+// predictable order, grouped by the section of the charm they are
+// found in.
+func CheckUpgrade(oldCharm, newCharm Charm) UpgradeChanges {
+	u := &upgradeChecker{}
+	oldMeta, newMeta := oldCharm.Meta(), newCharm.Meta()
+	if oldMeta.Name != newMeta.Name {
+		u.warnf("name", "charm name changed from %q to %q", oldMeta.Name, newMeta.Name)
+	}
+	if oldMeta.Subordinate != newMeta.Subordinate {
+		if newMeta.Subordinate {
+			u.breakf("subordinate", "charm changed from principal to subordinate")
+		} else {
+			u.breakf("subordinate", "charm changed from subordinate to principal")
+		}
+	}
+	u.checkSeries(oldMeta, newMeta)
+	u.checkRelations(oldMeta, newMeta)
+	u.checkStorage(oldMeta, newMeta)
+	u.checkConfig(oldCharm.Config(), newCharm.Config())
+	return u.changes
+}
+
+type upgradeChecker struct {
+	changes UpgradeChanges
+}
+
+func (u *upgradeChecker) addf(severity UpgradeSeverity, path, f string, a ...interface{}) {
+	u.changes = append(u.changes, UpgradeChange{
+		Severity: severity,
+		Path:     path,
+		Message:  fmt.Sprintf(f, a...),
+	})
+}
+
+func (u *upgradeChecker) breakf(path, f string, a ...interface{}) {
+	u.addf(UpgradeBreaking, path, f, a...)
+}
+
+func (u *upgradeChecker) warnf(path, f string, a ...interface{}) {
+	u.addf(UpgradeWarning, path, f, a...)
+}
+
+func (u *upgradeChecker) checkSeries(oldMeta, newMeta *Meta) {
+	if len(newMeta.Series) == 0 {
+		// The new charm makes no claims about the
+		// series it supports.
+		return
+	}
+	supported := make(map[string]bool)
+	for _, series := range newMeta.Series {
+		supported[series] = true
+	}
+	for i, series := range oldMeta.Series {
+		if !supported[series] {
+			u.warnf(fmt.Sprintf("series[%d]", i), "series %q is no longer supported", series)
+		}
+	}
+}
+
+// relationSections holds the names of the metadata sections
+// that hold relations with each role.
+var relationSections = map[RelationRole]string{
+	RoleProvider: "provides",
+	RoleRequirer: "requires",
+	RolePeer:     "peers",
+}
+
+func (u *upgradeChecker) checkRelations(oldMeta, newMeta *Meta) {
+	oldRels, newRels := oldMeta.CombinedRelations(), newMeta.CombinedRelations()
+
+	// Relations that appear in the new charm only may be
+	// old relations that have been renamed.
+	var added []string
+	for _, name := range sortedRelationNames(newRels) {
+		if _, ok := oldRels[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	renamed := make(map[string]bool)
+
+	for _, name := range sortedRelationNames(oldRels) {
+		oldRel := oldRels[name]
+		path := relationSections[oldRel.Role] + "." + name
+		newRel, ok := newRels[name]
+		if !ok {
+			newName := ""
+			for _, a := range added {
+				candidate := newRels[a]
+				if !renamed[a] && candidate.Role == oldRel.Role && candidate.Interface == oldRel.Interface {
+					newName = a
+					break
+				}
+			}
+			if newName != "" {
+				renamed[newName] = true
+				u.breakf(path, "relation %q was renamed to %q", name, newName)
+			} else {
+				u.breakf(path, "relation %q was removed", name)
+			}
+			continue
+		}
+		if newRel.Role != oldRel.Role {
+			u.breakf(path, "relation %q changed role from %q to %q", name, oldRel.Role, newRel.Role)
+			continue
+		}
+		if newRel.Interface != oldRel.Interface {
+			u.breakf(path+".interface", "relation %q changed interface from %q to %q", name, oldRel.Interface, newRel.Interface)
+		}
+		if newRel.Scope != oldRel.Scope {
+			u.breakf(path+".scope", "relation %q changed scope from %q to %q", name, oldRel.Scope, newRel.Scope)
+		}
+		if newRel.Limit != 0 && (oldRel.Limit == 0 || newRel.Limit < oldRel.Limit) {
+			u.warnf(path+".limit", "relation %q limit reduced from %s to %d", name, relationLimit(oldRel.Limit), newRel.Limit)
+		}
+		if oldRel.Optional && !newRel.Optional {
+			u.warnf(path+".optional", "relation %q is no longer optional", name)
+		}
+	}
+}
+
+func sortedRelationNames(rels map[string]Relation) []string {
+	names := make([]string, 0, len(rels))
+	for name := range rels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func relationLimit(limit int) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprint(limit)
+}
+
+func (u *upgradeChecker) checkStorage(oldMeta, newMeta *Meta) {
+	names := make([]string, 0, len(oldMeta.Storage)+len(newMeta.Storage))
+	for name := range oldMeta.Storage {
+		names = append(names, name)
+	}
+	for name := range newMeta.Storage {
+		if _, ok := oldMeta.Storage[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := "storage." + name
+		oldStore, inOld := oldMeta.Storage[name]
+		newStore, inNew := newMeta.Storage[name]
+		switch {
+		case !inNew:
+			u.breakf(path, "storage %q was removed", name)
+			continue
+		case !inOld:
+			if newStore.CountMin > 0 {
+				u.warnf(path, "new storage %q must be provisioned", name)
+			}
+			continue
+		}
+		if newStore.Type != oldStore.Type {
+			u.breakf(path+".type", "storage %q changed type from %q to %q", name, oldStore.Type, newStore.Type)
+		}
+		if newStore.Shared != oldStore.Shared {
+			u.warnf(path+".shared", "storage %q changed shared from %v to %v", name, oldStore.Shared, newStore.Shared)
+		}
+		if newStore.ReadOnly != oldStore.ReadOnly {
+			u.warnf(path+".read-only", "storage %q changed read-only from %v to %v", name, oldStore.ReadOnly, newStore.ReadOnly)
+		}
+		if newStore.CountMin > oldStore.CountMin || narrowerMax(oldStore.CountMax, newStore.CountMax) {
+			u.warnf(path+".multiple.range", "storage %q count range narrowed from %s to %s", name, storageRange(oldStore), storageRange(newStore))
+		}
+		if newStore.MinimumSize > oldStore.MinimumSize {
+			u.warnf(path+".minimum-size", "storage %q minimum size increased from %dM to %dM", name, oldStore.MinimumSize, newStore.MinimumSize)
+		}
+		if newStore.Location != oldStore.Location {
+			u.warnf(path+".location", "storage %q changed location from %q to %q", name, oldStore.Location, newStore.Location)
+		}
+	}
+}
+
+// narrowerMax reports whether newMax allows fewer
+// instances than oldMax, where -1 means no limit.
+func narrowerMax(oldMax, newMax int) bool {
+	if newMax == -1 {
+		return false
+	}
+	return oldMax == -1 || newMax < oldMax
+}
+
+func storageRange(s Storage) string {
+	if s.CountMax == -1 {
+		return fmt.Sprintf("%d+", s.CountMin)
+	}
+	return fmt.Sprintf("%d-%d", s.CountMin, s.CountMax)
+}
+
+func (u *upgradeChecker) checkConfig(oldConfig, newConfig *Config) {
+	if oldConfig == nil {
+		return
+	}
+	var newOptions map[string]Option
+	if newConfig != nil {
+		newOptions = newConfig.Options
+	}
+	names := make([]string, 0, len(oldConfig.Options))
+	for name := range oldConfig.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		oldOption := oldConfig.Options[name]
+		path := "options." + name
+		newOption, ok := newOptions[name]
+		if !ok {
+			u.breakf(path, "config option %q was removed", name)
+			continue
+		}
+		if newOption.Type != oldOption.Type {
+			u.breakf(path+".type", "config option %q changed type from %q to %q", name, oldOption.Type, newOption.Type)
+			continue
+		}
+		if !reflect.DeepEqual(newOption.Default, oldOption.Default) {
+			u.warnf(path+".default", "config option %q changed default from %#v to %#v", name, oldOption.Default, newOption.Default)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type UpgradeSuite struct{}
+
+var _ = gc.Suite(&UpgradeSuite{})
+
+func (s *UpgradeSuite) TestCheckUpgradeFixtures(c *gc.C) {
+	oldCharm := readCharmDir(c, "upgrade1")
+	newCharm := readCharmDir(c, "upgrade2")
+	changes := charm.CheckUpgrade(oldCharm, newCharm)
+	c.Assert(changes, jc.DeepEquals, charm.UpgradeChanges{{
+		Severity: charm.UpgradeBreaking,
+		Path:     "requires.cache.interface",
+		Message:  `relation "cache" changed interface from "memcache" to "redis"`,
+	}, {
+		Severity: charm.UpgradeWarning,
+		Path:     "requires.cache.limit",
+		Message:  `relation "cache" limit reduced from 2 to 1`,
+	}, {
+		Severity: charm.UpgradeBreaking,
+		Path:     "peers.cluster",
+		Message:  `relation "cluster" was removed`,
+	}, {
+		Severity: charm.UpgradeBreaking,
+		Path:     "provides.db-admin",
+		Message:  `relation "db-admin" was renamed to "db-root"`,
+	}, {
+		Severity: charm.UpgradeWarning,
+		Path:     "storage.data.location",
+		Message:  `storage "data" changed location from "/srv/data" to "/var/lib/data"`,
+	}, {
+		Severity: charm.UpgradeBreaking,
+		Path:     "storage.scratch.type",
+		Message:  `storage "scratch" changed type from "block" to "filesystem"`,
+	}, {
+		Severity: charm.UpgradeBreaking,
+		Path:     "options.debug",
+		Message:  `config option "debug" was removed`,
+	}, {
+		Severity: charm.UpgradeBreaking,
+		Path:     "options.port.type",
+		Message:  `config option "port" changed type from "int" to "string"`,
+	}, {
+		Severity: charm.UpgradeWarning,
+		Path:     "options.title.default",
+		Message:  `config option "title" changed default from "My Title" to "Our Title"`,
+	}})
+	c.Assert(changes.Breaking(), gc.HasLen, 6)
+	c.Assert(changes.Warnings(), gc.HasLen, 3)
+	c.Assert(changes[0].String(), gc.Equals, `breaking: relation "cache" changed interface from "memcache" to "redis"`)
+}
+
+func (s *UpgradeSuite) TestCheckUpgradeSameCharm(c *gc.C) {
+	ch := readCharmDir(c, "upgrade1")
+	c.Assert(charm.CheckUpgrade(ch, ch), gc.HasLen, 0)
+}
+
+var checkUpgradeTests = []struct {
+	about   string
+	old     charm.Meta
+	new     charm.Meta
+	changes charm.UpgradeChanges
+}{{
+	about: "subordinate flag flipped",
+	old:   charm.Meta{Name: "a"},
+	new:   charm.Meta{Name: "a", Subordinate: true},
+	changes: charm.UpgradeChanges{{
+		Severity: charm.UpgradeBreaking,
+		Path:     "subordinate",
+		Message:  "charm changed from principal to subordinate",
+	}},
+}, {
+	about: "name changed and series dropped",
+	old:   charm.Meta{Name: "a", Series: []string{"precise", "trusty"}},
+	new:   charm.Meta{Name: "b", Series: []string{"trusty", "xenial"}},
+	changes: charm.UpgradeChanges{{
+		Severity: charm.UpgradeWarning,
+		Path:     "name",
+		Message:  `charm name changed from "a" to "b"`,
+	}, {
+		Severity: charm.UpgradeWarning,
+		Path:     "series[0]",
+		Message:  `series "precise" is no longer supported`,
+	}},
+}, {
+	about: "relation role and scope changed",
+	old: charm.Meta{
+		Name: "a",
+		Provides: map[string]charm.Relation{
+			"db": {Name: "db", Role: charm.RoleProvider, Interface: "mysql", Scope: charm.ScopeGlobal},
+		},
+		Requires: map[string]charm.Relation{
+			"info": {Name: "info", Role: charm.RoleRequirer, Interface: "juju-info", Limit: 1, Scope: charm.ScopeGlobal, Optional: true},
+		},
+	},
+	new: charm.Meta{
+		Name: "a",
+		Requires: map[string]charm.Relation{
+			"db":   {Name: "db", Role: charm.RoleRequirer, Interface: "mysql", Limit: 1, Scope: charm.ScopeGlobal},
+			"info": {Name: "info", Role: charm.RoleRequirer, Interface: "juju-info", Limit: 1, Scope: charm.ScopeContainer},
+		},
+	},
+	changes: charm.UpgradeChanges{{
+		Severity: charm.UpgradeBreaking,
+		Path:     "provides.db",
+		Message:  `relation "db" changed role from "provider" to "requirer"`,
+	}, {
+		Severity: charm.UpgradeBreaking,
+		Path:     "requires.info.scope",
+		Message:  `relation "info" changed scope from "global" to "container"`,
+	}, {
+		Severity: charm.UpgradeWarning,
+		Path:     "requires.info.optional",
+		Message:  `relation "info" is no longer optional`,
+	}},
+}, {
+	about: "storage changes",
+	old: charm.Meta{
+		Name: "a",
+		Storage: map[string]charm.Storage{
+			"logs":  {Name: "logs", Type: charm.StorageFilesystem, CountMin: 0, CountMax: -1},
+			"cache": {Name: "cache", Type: charm.StorageBlock, CountMin: 1, CountMax: 1},
+		},
+	},
+	new: charm.Meta{
+		Name: "a",
+		Storage: map[string]charm.Storage{
+			"logs": {Name: "logs", Type: charm.StorageFilesystem, CountMin: 1, CountMax: 3, MinimumSize: 1024, ReadOnly: true},
+			"data": {Name: "data", Type: charm.StorageFilesystem, CountMin: 1, CountMax: 1},
+		},
+	},
+	changes: charm.UpgradeChanges{{
+		Severity: charm.UpgradeBreaking,
+		Path:     "storage.cache",
+		Message:  `storage "cache" was removed`,
+	}, {
+		Severity: charm.UpgradeWarning,
+		Path:     "storage.data",
+		Message:  `new storage "data" must be provisioned`,
+	}, {
+		Severity: charm.UpgradeWarning,
+		Path:     "storage.logs.read-only",
+		Message:  `storage "logs" changed read-only from false to true`,
+	}, {
+		Severity: charm.UpgradeWarning,
+		Path:     "storage.logs.multiple.range",
+		Message:  `storage "logs" count range narrowed from 0+ to 1-3`,
+	}, {
+		Severity: charm.UpgradeWarning,
+		Path:     "storage.logs.minimum-size",
+		Message:  `storage "logs" minimum size increased from 0M to 1024M`,
+	}},
+}}
+
+// upgradeTestCharm implements charm.Charm for upgrade tests.
+type upgradeTestCharm struct {
+	charm.Charm
+	meta *charm.Meta
+}
+
+func (ch upgradeTestCharm) Meta() *charm.Meta {
+	return ch.meta
+}
+
+func (ch upgradeTestCharm) Config() *charm.Config {
+	return charm.NewConfig()
+}
+
+func (s *UpgradeSuite) TestCheckUpgrade(c *gc.C) {
+	for i, test := range checkUpgradeTests {
+		c.Logf("test %d: %s", i, test.about)
+		old, new := test.old, test.new
+		changes := charm.CheckUpgrade(upgradeTestCharm{meta: &old}, upgradeTestCharm{meta: &new})
+		c.Assert(changes, jc.DeepEquals, test.changes)
+	}
+}
@@ -32,15 +32,22 @@ type Location interface {
 //     cs:~joe/wordpress
 //     cs:wordpress
 //     cs:precise/wordpress-20
-//     cs:development/precise/wordpress-20
-//     cs:~joe/development/wordpress
+//     cs:edge/precise/wordpress-20
+//     cs:~joe/2.0/candidate/wordpress
+//     cs:~joe/2.0/edge/fix-123/xenial/wordpress
 //
+// A channel is recognised by its risk, so it must have one, and
+// its track may not be named after a risk. A channel branch is
+// only recognised in a URL that also has a series. Local URLs do
+// not hold a channel. The marshaling methods return an error for
+// a URL that cannot be written so that ParseURL reads it back.
 type URL struct {
-	Schema   string // "cs" or "local".
-	User     string // "joe".
-	Name     string // "wordpress".
-	Revision int    // -1 if unset, N otherwise.
-	Series   string // "precise" or "" if unset; "bundle" if it's a bundle.
+	Schema   string  // "cs", "local" or the schema of a registered Store.
+	User     string  // "joe".
+	Name     string  // "wordpress".
+	Revision int     // -1 if unset, N otherwise.
+	Series   string  // "precise" or "" if unset; "bundle" if it's a bundle.
+	Channel  Channel // "edge" or "2.0/candidate"; the zero Channel if unset.
 }
 
 var (
@@ -49,9 +56,10 @@ var (
 	validName              = regexp.MustCompile("^[a-z][a-z0-9]*(-[a-z0-9]*[a-z][a-z0-9]*)*$")
 )
 
-// ValidateSchema returns an error if the schema is invalid.
+// ValidateSchema returns an error if the schema is invalid,
+// that is if it is not the schema of a known Store.
 func ValidateSchema(schema string) error {
-	if schema != "cs" && schema != "local" {
+	if _, ok := StoreForSchema(schema); !ok {
 		return errors.NotValidf("schema %q", schema)
 	}
 	return nil
@@ -92,6 +100,14 @@ func (url *URL) WithRevision(revision int) *URL {
 	return &urlCopy
 }
 
+// WithChannel returns a URL equivalent to url but with Channel set
+// to channel.
+func (url *URL) WithChannel(channel Channel) *URL {
+	urlCopy := *url
+	urlCopy.Channel = channel
+	return &urlCopy
+}
+
 // MustParseURL works like ParseURL, but panics in case of errors.
 func MustParseURL(url string) *URL {
 	u, err := ParseURL(url)
@@ -125,6 +141,14 @@ func MustParseURL(url string) *URL {
 //    https://jujucharms.com/u/user/channel/name/revision
 //    https://jujucharms.com/u/user/channel/name/series/revision
 //
+// Fully-qualified URLs on the hosts of a Store registered with
+// RegisterStore map to that store's schema instead.
+//
+// A channel is a risk, such as "edge", optionally preceded by
+// a track, as in "2.0/edge". In URLs of the "cs:" form, the
+// risk may also be followed by a branch, as in
+// "cs:~joe/2.0/edge/fix-123/xenial/wordpress".
+//
 // A missing schema is assumed to be 'cs'.
 func ParseURL(url string) (*URL, error) {
 	// Check if we're dealing with a v1 or v2 URL.
@@ -143,7 +167,11 @@ func ParseURL(url string) (*URL, error) {
 		curl, err = parseV1URL(u, url)
 	case u.Scheme == "http" || u.Scheme == "https":
 		// Shortcut new-style URLs.
-		curl, err = parseV2URL(u)
+		schema := defaultStoreSchema
+		if store, ok := StoreForHost(u.Host); ok {
+			schema = store.Schema
+		}
+		curl, err = parseV2URL(u, schema)
 	default:
 		// TODO: for now, fall through to parsing v1 references; this will be
 		// expanded to be more robust in the future.
@@ -153,7 +181,7 @@ func ParseURL(url string) (*URL, error) {
 		return nil, errors.Trace(err)
 	}
 	if curl.Schema == "" {
-		curl.Schema = "cs"
+		curl.Schema = defaultStoreSchema
 	}
 	return curl, nil
 }
@@ -168,18 +196,27 @@ func parseV1URL(url *gourl.URL, originalURL string) (*URL, error) {
 	}
 	i := 0
 	parts := strings.Split(url.Path[i:], "/")
-	if len(parts) < 1 || len(parts) > 4 {
+	if len(parts) < 1 || len(parts) > 6 {
 		return nil, errors.Errorf("charm or bundle URL has invalid form: %q", originalURL)
 	}
 
 	// ~<username>
 	if strings.HasPrefix(parts[0], "~") {
-		if r.Schema == "local" {
+		if isLocalSchema(r.Schema) {
 			return nil, errors.Errorf("local charm or bundle URL with user name: %q", originalURL)
 		}
 		r.User, parts = parts[0][1:], parts[1:]
 	}
 
+	// [<track>/]<risk>[/<branch>]
+	r.Channel, parts = parseURLChannel(parts)
+	if r.Channel.Risk != "" && len(parts) == 3 {
+		r.Channel.Branch, parts = parts[0], parts[1:]
+	}
+	if r.Channel != (Channel{}) && isLocalSchema(r.Schema) {
+		return nil, errors.Errorf("local charm or bundle URL with channel: %q", originalURL)
+	}
+
 	if len(parts) > 2 {
 		return nil, errors.Errorf("charm or bundle URL has invalid form: %q", originalURL)
 	}
@@ -224,9 +261,9 @@ func parseV1URL(url *gourl.URL, originalURL string) (*URL, error) {
 	return &r, nil
 }
 
-func parseV2URL(url *gourl.URL) (*URL, error) {
+func parseV2URL(url *gourl.URL, schema string) (*URL, error) {
 	var r URL
-	r.Schema = "cs"
+	r.Schema = schema
 	parts := strings.Split(strings.Trim(url.Path, "/"), "/")
 	if parts[0] == "u" {
 		if len(parts) < 3 {
@@ -234,6 +271,7 @@ func parseV2URL(url *gourl.URL) (*URL, error) {
 		}
 		r.User, parts = parts[1], parts[2:]
 	}
+	r.Channel, parts = parseURLChannel(parts)
 	r.Name, parts = parts[0], parts[1:]
 	r.Revision = -1
 	if len(parts) > 0 {
@@ -269,11 +307,60 @@ func parseV2URL(url *gourl.URL) (*URL, error) {
 	return &r, nil
 }
 
+// parseURLChannel parses the channel at the start of the given
+// URL path parts, returning it and the parts that follow it. The
+// channel is a risk, optionally preceded by a track; it is only
+// recognised when followed by at least one more part.
+func parseURLChannel(parts []string) (Channel, []string) {
+	switch {
+	case len(parts) > 1 && Risk(parts[0]).IsValid():
+		return Channel{Risk: Risk(parts[0])}, parts[1:]
+	case len(parts) > 2 && isURLTrack(parts[0]) && Risk(parts[1]).IsValid():
+		return Channel{Track: parts[0], Risk: Risk(parts[1])}, parts[2:]
+	}
+	return Channel{}, parts
+}
+
+// isURLTrack reports whether the given URL path part can
+// be parsed as a channel track. A part that names a risk
+// is always taken to be the risk.
+func isURLTrack(part string) bool {
+	return IsValidTrack(part) && !Risk(part).IsValid()
+}
+
+// validatePath returns an error if the URL cannot be written
+// in its string form so that ParseURL reads back the same URL.
+func (u *URL) validatePath() error {
+	ch := u.Channel
+	if ch == (Channel{}) {
+		if Risk(u.Series).IsValid() {
+			return errors.Errorf("series %q without a channel would be read as a channel risk", u.Series)
+		}
+		return nil
+	}
+	switch {
+	case isLocalSchema(u.Schema):
+		return errors.Errorf("local charm or bundle URL cannot hold a channel")
+	case !ch.Risk.IsValid():
+		return errors.Errorf("channel %q does not have a known risk", ch)
+	case ch.Track != "" && !isURLTrack(ch.Track):
+		return errors.Errorf("channel %q has invalid track %q", ch, ch.Track)
+	case ch.Branch != "" && u.Series == "":
+		return errors.Errorf("channel %q has a branch but the URL has no series", ch)
+	case strings.Contains(ch.Branch, "/"):
+		return errors.Errorf("channel %q has invalid branch %q", ch, ch.Branch)
+	}
+	return nil
+}
+
 func (r *URL) path() string {
 	var parts []string
 	if r.User != "" {
 		parts = append(parts, fmt.Sprintf("~%s", r.User))
 	}
+	if ch := r.Channel.String(); ch != "" {
+		parts = append(parts, ch)
+	}
 	if r.Series != "" {
 		parts = append(parts, r.Series)
 	}
@@ -317,6 +404,9 @@ func (u *URL) GetBSON() (interface{}, error) {
 	if u == nil {
 		return nil, nil
 	}
+	if err := u.validatePath(); err != nil {
+		return nil, errors.Annotatef(err, "cannot marshal %q", u)
+	}
 	return u.String(), nil
 }
 
@@ -343,6 +433,9 @@ func (u *URL) MarshalJSON() ([]byte, error) {
 	if u == nil {
 		panic("cannot marshal nil *charm.URL")
 	}
+	if err := u.validatePath(); err != nil {
+		return nil, errors.Annotatef(err, "cannot marshal %q", u)
+	}
 	return json.Marshal(u.String())
 }
 
@@ -365,6 +458,9 @@ func (u *URL) MarshalText() ([]byte, error) {
 	if u == nil {
 		return nil, nil
 	}
+	if err := u.validatePath(); err != nil {
+		return nil, errors.Annotatef(err, "cannot marshal %q", u)
+	}
 	return []byte(u.String()), nil
 }
 
@@ -26,109 +26,109 @@ var urlTests = []struct {
 	url    *charm.URL
 }{{
 	s:   "cs:~user/series/name",
-	url: &charm.URL{"cs", "user", "name", -1, "series"},
+	url: &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: -1, Series: "series"},
 }, {
 	s:   "cs:~user/series/name-0",
-	url: &charm.URL{"cs", "user", "name", 0, "series"},
+	url: &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: 0, Series: "series"},
 }, {
 	s:   "cs:series/name",
-	url: &charm.URL{"cs", "", "name", -1, "series"},
+	url: &charm.URL{Schema: "cs", Name: "name", Revision: -1, Series: "series"},
 }, {
 	s:   "cs:series/name-42",
-	url: &charm.URL{"cs", "", "name", 42, "series"},
+	url: &charm.URL{Schema: "cs", Name: "name", Revision: 42, Series: "series"},
 }, {
 	s:   "local:series/name-1",
-	url: &charm.URL{"local", "", "name", 1, "series"},
+	url: &charm.URL{Schema: "local", Name: "name", Revision: 1, Series: "series"},
 }, {
 	s:   "local:series/name",
-	url: &charm.URL{"local", "", "name", -1, "series"},
+	url: &charm.URL{Schema: "local", Name: "name", Revision: -1, Series: "series"},
 }, {
 	s:   "local:series/n0-0n-n0",
-	url: &charm.URL{"local", "", "n0-0n-n0", -1, "series"},
+	url: &charm.URL{Schema: "local", Name: "n0-0n-n0", Revision: -1, Series: "series"},
 }, {
 	s:   "cs:~user/name",
-	url: &charm.URL{"cs", "user", "name", -1, ""},
+	url: &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: -1},
 }, {
 	s:   "cs:name",
-	url: &charm.URL{"cs", "", "name", -1, ""},
+	url: &charm.URL{Schema: "cs", Name: "name", Revision: -1},
 }, {
 	s:   "local:name",
-	url: &charm.URL{"local", "", "name", -1, ""},
+	url: &charm.URL{Schema: "local", Name: "name", Revision: -1},
 }, {
 	s:     "http://jujucharms.com/u/user/name/series/1",
-	url:   &charm.URL{"cs", "user", "name", 1, "series"},
+	url:   &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: 1, Series: "series"},
 	exact: "cs:~user/series/name-1",
 }, {
 	s:     "http://www.jujucharms.com/u/user/name/series/1",
-	url:   &charm.URL{"cs", "user", "name", 1, "series"},
+	url:   &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: 1, Series: "series"},
 	exact: "cs:~user/series/name-1",
 }, {
 	s:     "https://www.jujucharms.com/u/user/name/series/1",
-	url:   &charm.URL{"cs", "user", "name", 1, "series"},
+	url:   &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: 1, Series: "series"},
 	exact: "cs:~user/series/name-1",
 }, {
 	s:     "https://jujucharms.com/u/user/name/series/1",
-	url:   &charm.URL{"cs", "user", "name", 1, "series"},
+	url:   &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: 1, Series: "series"},
 	exact: "cs:~user/series/name-1",
 }, {
 	s:     "https://jujucharms.com/u/user/name/series",
-	url:   &charm.URL{"cs", "user", "name", -1, "series"},
+	url:   &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: -1, Series: "series"},
 	exact: "cs:~user/series/name",
 }, {
 	s:     "https://jujucharms.com/u/user/name/1",
-	url:   &charm.URL{"cs", "user", "name", 1, ""},
+	url:   &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: 1},
 	exact: "cs:~user/name-1",
 }, {
 	s:     "https://jujucharms.com/u/user/name",
-	url:   &charm.URL{"cs", "user", "name", -1, ""},
+	url:   &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: -1},
 	exact: "cs:~user/name",
 }, {
 	s:     "https://jujucharms.com/name",
-	url:   &charm.URL{"cs", "", "name", -1, ""},
+	url:   &charm.URL{Schema: "cs", Name: "name", Revision: -1},
 	exact: "cs:name",
 }, {
 	s:     "https://jujucharms.com/name/series",
-	url:   &charm.URL{"cs", "", "name", -1, "series"},
+	url:   &charm.URL{Schema: "cs", Name: "name", Revision: -1, Series: "series"},
 	exact: "cs:series/name",
 }, {
 	s:     "https://jujucharms.com/name/1",
-	url:   &charm.URL{"cs", "", "name", 1, ""},
+	url:   &charm.URL{Schema: "cs", Name: "name", Revision: 1},
 	exact: "cs:name-1",
 }, {
 	s:     "https://jujucharms.com/name/series/1",
-	url:   &charm.URL{"cs", "", "name", 1, "series"},
+	url:   &charm.URL{Schema: "cs", Name: "name", Revision: 1, Series: "series"},
 	exact: "cs:series/name-1",
 }, {
 	s:     "https://jujucharms.com/u/user/name/series/1/",
-	url:   &charm.URL{"cs", "user", "name", 1, "series"},
+	url:   &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: 1, Series: "series"},
 	exact: "cs:~user/series/name-1",
 }, {
 	s:     "https://jujucharms.com/u/user/name/series/",
-	url:   &charm.URL{"cs", "user", "name", -1, "series"},
+	url:   &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: -1, Series: "series"},
 	exact: "cs:~user/series/name",
 }, {
 	s:     "https://jujucharms.com/u/user/name/1/",
-	url:   &charm.URL{"cs", "user", "name", 1, ""},
+	url:   &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: 1},
 	exact: "cs:~user/name-1",
 }, {
 	s:     "https://jujucharms.com/u/user/name/",
-	url:   &charm.URL{"cs", "user", "name", -1, ""},
+	url:   &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: -1},
 	exact: "cs:~user/name",
 }, {
 	s:     "https://jujucharms.com/name/",
-	url:   &charm.URL{"cs", "", "name", -1, ""},
+	url:   &charm.URL{Schema: "cs", Name: "name", Revision: -1},
 	exact: "cs:name",
 }, {
 	s:     "https://jujucharms.com/name/series/",
-	url:   &charm.URL{"cs", "", "name", -1, "series"},
+	url:   &charm.URL{Schema: "cs", Name: "name", Revision: -1, Series: "series"},
 	exact: "cs:series/name",
 }, {
 	s:     "https://jujucharms.com/name/1/",
-	url:   &charm.URL{"cs", "", "name", 1, ""},
+	url:   &charm.URL{Schema: "cs", Name: "name", Revision: 1},
 	exact: "cs:name-1",
 }, {
 	s:     "https://jujucharms.com/name/series/1/",
-	url:   &charm.URL{"cs", "", "name", 1, "series"},
+	url:   &charm.URL{Schema: "cs", Name: "name", Revision: 1, Series: "series"},
 	exact: "cs:series/name-1",
 }, {
 	s:   "https://jujucharms.com/",
@@ -202,37 +202,90 @@ var urlTests = []struct {
 }, {
 	s:     "precise/wordpress",
 	exact: "cs:precise/wordpress",
-	url:   &charm.URL{"cs", "", "wordpress", -1, "precise"},
+	url:   &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Series: "precise"},
 }, {
 	s:     "foo",
 	exact: "cs:foo",
-	url:   &charm.URL{"cs", "", "foo", -1, ""},
+	url:   &charm.URL{Schema: "cs", Name: "foo", Revision: -1},
 }, {
 	s:     "foo-1",
 	exact: "cs:foo-1",
-	url:   &charm.URL{"cs", "", "foo", 1, ""},
+	url:   &charm.URL{Schema: "cs", Name: "foo", Revision: 1},
 }, {
 	s:     "n0-n0-n0",
 	exact: "cs:n0-n0-n0",
-	url:   &charm.URL{"cs", "", "n0-n0-n0", -1, ""},
+	url:   &charm.URL{Schema: "cs", Name: "n0-n0-n0", Revision: -1},
 }, {
 	s:     "cs:foo",
 	exact: "cs:foo",
-	url:   &charm.URL{"cs", "", "foo", -1, ""},
+	url:   &charm.URL{Schema: "cs", Name: "foo", Revision: -1},
 }, {
 	s:     "local:foo",
 	exact: "local:foo",
-	url:   &charm.URL{"local", "", "foo", -1, ""},
+	url:   &charm.URL{Schema: "local", Name: "foo", Revision: -1},
 }, {
 	s:     "series/foo",
 	exact: "cs:series/foo",
-	url:   &charm.URL{"cs", "", "foo", -1, "series"},
+	url:   &charm.URL{Schema: "cs", Name: "foo", Revision: -1, Series: "series"},
 }, {
 	s:   "series/foo/bar",
 	err: `charm or bundle URL has invalid form: "series/foo/bar"`,
 }, {
 	s:   "cs:foo/~blah",
 	err: `cannot parse URL $URL: name "~blah" not valid`,
+}, {
+	s:   "cs:edge/precise/wordpress-20",
+	url: &charm.URL{Schema: "cs", Name: "wordpress", Revision: 20, Series: "precise", Channel: charm.Channel{Risk: charm.Edge}},
+}, {
+	s:   "cs:stable/wordpress",
+	url: &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Channel: charm.Channel{Risk: charm.Stable}},
+}, {
+	s:   "cs:~joe/2.0/candidate/wordpress",
+	url: &charm.URL{Schema: "cs", User: "joe", Name: "wordpress", Revision: -1, Channel: charm.Channel{Track: "2.0", Risk: charm.Candidate}},
+}, {
+	s:   "cs:~joe/2.0/edge/fix-123/xenial/wordpress-3",
+	url: &charm.URL{Schema: "cs", User: "joe", Name: "wordpress", Revision: 3, Series: "xenial", Channel: charm.Channel{Track: "2.0", Risk: charm.Edge, Branch: "fix-123"}},
+}, {
+	s:   "cs:beta/fix-123/xenial/wordpress",
+	url: &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Series: "xenial", Channel: charm.Channel{Risk: charm.Beta, Branch: "fix-123"}},
+}, {
+	s:   "cs:edge",
+	url: &charm.URL{Schema: "cs", Name: "edge", Revision: -1},
+}, {
+	s:   "cs:xenial/edge",
+	url: &charm.URL{Schema: "cs", Name: "edge", Revision: -1, Series: "xenial"},
+}, {
+	s:   "cs:~joe/2.0/edge/fix/xenial/wordpress/extra",
+	err: `charm or bundle URL has invalid form: $URL`,
+}, {
+	s:   "cs:/edge/wordpress",
+	err: `charm or bundle URL has invalid form: $URL`,
+}, {
+	s:   "cs:latest/edge/xenial/wordpress",
+	url: &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Series: "xenial", Channel: charm.Channel{Track: "latest", Risk: charm.Edge}},
+}, {
+	s:   "cs:precise/edge/wordpress",
+	url: &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Channel: charm.Channel{Track: "precise", Risk: charm.Edge}},
+}, {
+	s:   "cs:edge/stable/wordpress",
+	url: &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Series: "stable", Channel: charm.Channel{Risk: charm.Edge}},
+}, {
+	s:   "cs:~joe/Track/edge/wordpress",
+	err: `charm or bundle URL has invalid form: $URL`,
+}, {
+	s:   "local:edge/xenial/foo",
+	err: `local charm or bundle URL with channel: $URL`,
+}, {
+	s:   "local:2.0/edge/xenial/foo",
+	err: `local charm or bundle URL with channel: $URL`,
+}, {
+	s:     "https://jujucharms.com/edge/name",
+	exact: "cs:edge/name",
+	url:   &charm.URL{Schema: "cs", Name: "name", Revision: -1, Channel: charm.Channel{Risk: charm.Edge}},
+}, {
+	s:     "https://jujucharms.com/u/user/2.0/beta/name/series/1",
+	exact: "cs:~user/2.0/beta/series/name-1",
+	url:   &charm.URL{Schema: "cs", User: "user", Name: "name", Revision: 1, Series: "series", Channel: charm.Channel{Track: "2.0", Risk: charm.Beta}},
 }}
 
 func (s *URLSuite) TestParseURL(c *gc.C) {
@@ -375,7 +428,7 @@ func (s *URLSuite) TestValidCheckers(c *gc.C) {
 
 func (s *URLSuite) TestMustParseURL(c *gc.C) {
 	url := charm.MustParseURL("cs:series/name")
-	c.Assert(url, gc.DeepEquals, &charm.URL{"cs", "", "name", -1, "series"})
+	c.Assert(url, gc.DeepEquals, &charm.URL{Schema: "cs", Name: "name", Revision: -1, Series: "series"})
 	f := func() { charm.MustParseURL("local:@@/name") }
 	c.Assert(f, gc.PanicMatches, "cannot parse URL \"local:@@/name\": series name \"@@\" not valid")
 	f = func() { charm.MustParseURL("cs:~user") }
@@ -387,8 +440,8 @@ func (s *URLSuite) TestMustParseURL(c *gc.C) {
 func (s *URLSuite) TestWithRevision(c *gc.C) {
 	url := charm.MustParseURL("cs:series/name")
 	other := url.WithRevision(1)
-	c.Assert(url, gc.DeepEquals, &charm.URL{"cs", "", "name", -1, "series"})
-	c.Assert(other, gc.DeepEquals, &charm.URL{"cs", "", "name", 1, "series"})
+	c.Assert(url, gc.DeepEquals, &charm.URL{Schema: "cs", Name: "name", Revision: -1, Series: "series"})
+	c.Assert(other, gc.DeepEquals, &charm.URL{Schema: "cs", Name: "name", Revision: 1, Series: "series"})
 
 	// Should always copy. The opposite behavior is error prone.
 	c.Assert(other.WithRevision(1), gc.Not(gc.Equals), other)
@@ -446,6 +499,131 @@ func (s *URLSuite) TestURLCodecs(c *gc.C) {
 	}
 }
 
+func (s *URLSuite) TestURLCodecsWithChannel(c *gc.C) {
+	url := charm.MustParseURL("cs:~user/2.0/edge/series/name-1")
+	for i, codec := range codecs {
+		c.Logf("codec %d: %v", i, codec.Name)
+		type doc struct {
+			URL *charm.URL
+		}
+		data, err := codec.Marshal(doc{url})
+		c.Assert(err, gc.IsNil)
+		var v doc
+		err = codec.Unmarshal(data, &v)
+		c.Assert(err, gc.IsNil)
+		c.Assert(v.URL, gc.DeepEquals, url)
+	}
+	data, err := url.MarshalText()
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, "cs:~user/2.0/edge/series/name-1")
+}
+
+var urlChannelRoundTripTests = []struct {
+	about string
+	url   *charm.URL
+	err   string
+}{{
+	about: "risk",
+	url:   &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Channel: charm.Channel{Risk: charm.Edge}},
+}, {
+	about: "risk with series",
+	url:   &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Series: "xenial", Channel: charm.Channel{Risk: charm.Edge}},
+}, {
+	about: "track and risk",
+	url:   &charm.URL{Schema: "cs", Name: "wordpress", Revision: 2, Channel: charm.Channel{Track: "2.0", Risk: charm.Beta}},
+}, {
+	about: "track and risk with series",
+	url:   &charm.URL{Schema: "cs", User: "joe", Name: "wordpress", Revision: -1, Series: "xenial", Channel: charm.Channel{Track: "2.0", Risk: charm.Beta}},
+}, {
+	about: "series-like track",
+	url:   &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Channel: charm.Channel{Track: "latest", Risk: charm.Edge}},
+}, {
+	about: "series-like track with series",
+	url:   &charm.URL{Schema: "cs", User: "joe", Name: "wordpress", Revision: 1, Series: "xenial", Channel: charm.Channel{Track: "latest", Risk: charm.Edge}},
+}, {
+	about: "risk and branch with series",
+	url:   &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Series: "xenial", Channel: charm.Channel{Risk: charm.Edge, Branch: "fix"}},
+}, {
+	about: "track, risk and branch with series",
+	url:   &charm.URL{Schema: "cs", User: "joe", Name: "wordpress", Revision: 3, Series: "xenial", Channel: charm.Channel{Track: "latest", Risk: charm.Edge, Branch: "fix"}},
+}, {
+	about: "series named after a risk with a channel",
+	url:   &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Series: "stable", Channel: charm.Channel{Risk: charm.Edge}},
+}, {
+	about: "risk and branch without series",
+	url:   &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Channel: charm.Channel{Risk: charm.Edge, Branch: "fix"}},
+	err:   `cannot marshal "cs:edge/fix/wordpress": channel "edge/fix" has a branch but the URL has no series`,
+}, {
+	about: "track, risk and branch without series",
+	url:   &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Channel: charm.Channel{Track: "2.0", Risk: charm.Edge, Branch: "fix"}},
+	err:   `cannot marshal "cs:2.0/edge/fix/wordpress": channel "2.0/edge/fix" has a branch but the URL has no series`,
+}, {
+	about: "track without risk",
+	url:   &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Channel: charm.Channel{Track: "2.0"}},
+	err:   `cannot marshal "cs:2.0/wordpress": channel "2.0" does not have a known risk`,
+}, {
+	about: "track without risk with series",
+	url:   &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Series: "xenial", Channel: charm.Channel{Track: "2.0"}},
+	err:   `cannot marshal "cs:2.0/xenial/wordpress": channel "2.0" does not have a known risk`,
+}, {
+	about: "track named after a risk",
+	url:   &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Series: "xenial", Channel: charm.Channel{Track: "edge", Risk: charm.Stable}},
+	err:   `cannot marshal "cs:edge/stable/xenial/wordpress": channel "edge/stable" has invalid track "edge"`,
+}, {
+	about: "series named after a risk without a channel",
+	url:   &charm.URL{Schema: "cs", Name: "wordpress", Revision: -1, Series: "edge"},
+	err:   `cannot marshal "cs:edge/wordpress": series "edge" without a channel would be read as a channel risk`,
+}, {
+	about: "local URL with channel",
+	url:   &charm.URL{Schema: "local", Name: "wordpress", Revision: -1, Series: "xenial", Channel: charm.Channel{Risk: charm.Edge}},
+	err:   `cannot marshal "local:edge/xenial/wordpress": local charm or bundle URL cannot hold a channel`,
+}}
+
+func (s *URLSuite) TestURLChannelRoundTrip(c *gc.C) {
+	type doc struct {
+		URL *charm.URL
+	}
+	for i, test := range urlChannelRoundTripTests {
+		c.Logf("test %d: %s", i, test.about)
+		if test.err == "" {
+			url, err := charm.ParseURL(test.url.String())
+			c.Assert(err, gc.IsNil)
+			c.Assert(url, gc.DeepEquals, test.url)
+		}
+		for _, codec := range codecs {
+			c.Logf("codec %v", codec.Name)
+			data, err := codec.Marshal(doc{test.url})
+			if test.err != "" {
+				c.Assert(err, gc.ErrorMatches, ".*"+regexp.QuoteMeta(test.err))
+				continue
+			}
+			c.Assert(err, gc.IsNil)
+			var v doc
+			err = codec.Unmarshal(data, &v)
+			c.Assert(err, gc.IsNil)
+			c.Assert(v.URL, gc.DeepEquals, test.url)
+		}
+		data, err := test.url.MarshalText()
+		if test.err != "" {
+			c.Assert(err, gc.ErrorMatches, regexp.QuoteMeta(test.err))
+			continue
+		}
+		c.Assert(err, gc.IsNil)
+		var url charm.URL
+		err = url.UnmarshalText(data)
+		c.Assert(err, gc.IsNil)
+		c.Assert(&url, gc.DeepEquals, test.url)
+	}
+}
+
+func (s *URLSuite) TestWithChannel(c *gc.C) {
+	url := charm.MustParseURL("cs:~user/series/name-1")
+	other := url.WithChannel(charm.MustParseChannel("2.0/candidate"))
+	c.Assert(url.Channel, gc.Equals, charm.Channel{})
+	c.Assert(other.String(), gc.Equals, "cs:~user/2.0/candidate/series/name-1")
+	c.Assert(other.Path(), gc.Equals, "~user/2.0/candidate/series/name-1")
+}
+
 func (s *URLSuite) TestJSONGarbage(c *gc.C) {
 	// unmarshalling json gibberish
 	for _, value := range []string{":{", `"cs:{}+<"`, `"cs:~_~/f00^^&^/baaaar$%-?"`} {